@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ============================================================================
+// Safe Output Writing
+// ============================================================================
+
+// defaultOutputMode is used for new output files when no mode is configured
+const defaultOutputMode os.FileMode = 0644
+
+// lockTimeout is how long to wait for another process writing the same output
+const lockTimeout = 10 * time.Second
+
+var errLocked = errors.New("file is locked")
+
+// parseFileMode parses an octal permission string such as "0600"
+func parseFileMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid file mode %q (use octal, e.g. 0600)", s)
+	}
+	if mode&0600 != 0600 {
+		return 0, fmt.Errorf("file mode %s must allow the owner to read and write", s)
+	}
+	return os.FileMode(mode), nil
+}
+
+// outputFileMode returns the mode of the existing file at path, or mode
+// (defaultOutputMode when zero) for a new file
+func outputFileMode(path string, mode os.FileMode) os.FileMode {
+	if info, err := os.Stat(path); err == nil {
+		return info.Mode().Perm()
+	}
+	if mode == 0 {
+		return defaultOutputMode
+	}
+	return mode
+}
+
+// writeFileAtomic writes data to a temp file next to path, syncs it and
+// renames it into place, so path never contains a partially written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmpName, err := stageFile(path, data, perm)
+	if err != nil {
+		return err
+	}
+	if err := commitFile(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
+
+// stageFile writes data to a synced temp file next to path and returns its
+// name, for commitFile to move into place. The caller removes the temp file
+// if it is not committed.
+func stageFile(path string, data []byte, perm os.FileMode) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	tmpName := tmp.Name()
+
+	err = func() error {
+		if _, err := tmp.Write(data); err != nil {
+			return err
+		}
+		if err := tmp.Chmod(perm); err != nil {
+			return err
+		}
+		return tmp.Sync()
+	}()
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return "", err
+	}
+	return tmpName, nil
+}
+
+// commitFile renames a temp file written by stageFile to path
+func commitFile(tmpName, path string) error {
+	if err := os.Rename(tmpName, path); err != nil {
+		return err
+	}
+
+	// Persist the rename itself; not supported on every platform
+	if d, err := os.Open(filepath.Dir(path)); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// lockOutput takes an advisory lock for writing path, waiting up to
+// lockTimeout for other hours-signer processes. The lock file lives in the
+// config directory so no stray files end up next to the output, and is
+// removed again on unlock. The lock is held by the operating system rather
+// than by the file, so one left behind by a crashed process does not block.
+func lockOutput(path string) (unlock func(), err error) {
+	lockFile, err := outputLockFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(lockFile), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		unlock, err := tryLockFile(lockFile)
+		if err == nil {
+			return unlock, nil
+		}
+		if !errors.Is(err, errLocked) {
+			return nil, fmt.Errorf("failed to lock output: %w", err)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("output %s is being written by another process", path)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// outputLockFile returns the lock file for writing path
+func outputLockFile(path string) (string, error) {
+	configPath := ConfigPath()
+	if configPath == "" {
+		return "", fmt.Errorf("could not determine lock directory: no config directory")
+	}
+	return filepath.Join(filepath.Dir(configPath), "locks", sha256Hex([]byte(absPath(path)))[:16]+".lock"), nil
+}
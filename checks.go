@@ -0,0 +1,300 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// ============================================================================
+// Content Checks
+// ============================================================================
+
+// ErrContentMismatch is returned when a PDF does not look like the timesheet
+// of the employee for the period being signed
+var ErrContentMismatch = errors.New("the PDF does not look like your timesheet")
+
+// Values of the content_checks config key
+const (
+	checkOn  = "on"
+	checkOff = "off"
+)
+
+func validateContentChecks(value string) error {
+	if value != checkOn && value != checkOff {
+		return fmt.Errorf("must be %q or %q", checkOn, checkOff)
+	}
+	return nil
+}
+
+// maxPeriodAge is the number of months a timesheet can be behind the current
+// month before its period is implausible
+const maxPeriodAge = 3
+
+// nameParticles are the lowercase parts of surnames that are not matched on
+// their own, as in "van der Berg"
+var nameParticles = map[string]bool{
+	"van": true, "der": true, "den": true, "de": true, "het": true, "ter": true, "ten": true, "te": true,
+	"t": true, "op": true, "in": true, "von": true, "zu": true, "di": true, "da": true, "du": true, "le": true, "la": true,
+}
+
+// foldDiacritics maps accented lowercase letters to their base letters
+var foldDiacritics = strings.NewReplacer(
+	"à", "a", "á", "a", "â", "a", "ã", "a", "ä", "a", "å", "a", "æ", "ae",
+	"ç", "c", "č", "c", "ć", "c", "ď", "d", "đ", "d",
+	"è", "e", "é", "e", "ê", "e", "ë", "e", "ě", "e", "ę", "e",
+	"ì", "i", "í", "i", "î", "i", "ï", "i", "ı", "i", "ğ", "g",
+	"ł", "l", "ñ", "n", "ń", "n", "ň", "n",
+	"ò", "o", "ó", "o", "ô", "o", "õ", "o", "ö", "o", "ø", "o", "ő", "o", "œ", "oe",
+	"ř", "r", "š", "s", "ś", "s", "ş", "s", "ș", "s", "ß", "ss", "ť", "t", "ț", "t",
+	"ù", "u", "ú", "u", "û", "u", "ü", "u", "ů", "u", "ű", "u",
+	"ý", "y", "ÿ", "y", "ž", "z", "ź", "z", "ż", "z",
+)
+
+// nameTokens splits s into lowercase words without diacritics; initials such
+// as "J.W." become one word per letter
+func nameTokens(s string) []string {
+	s = foldDiacritics.Replace(strings.ToLower(s))
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < '0' || r > '9')
+	})
+}
+
+// givenNameMatches reports whether a word of the document stands for the
+// given name: the name itself, or an initial of either
+func givenNameMatches(given, word string) bool {
+	switch {
+	case word == given:
+		return true
+	case len(given) == 1:
+		return strings.HasPrefix(word, given)
+	case len(word) == 1:
+		return strings.HasPrefix(given, word)
+	}
+	return false
+}
+
+// nameInLines reports whether name appears in one of the lines. The surname,
+// the last word of the name apart from particles, must appear as is; the
+// first name may be written as an initial. Case and diacritics are ignored.
+func nameInLines(name string, lines []string) bool {
+	var words []string
+	for _, w := range nameTokens(name) {
+		if !nameParticles[w] {
+			words = append(words, w)
+		}
+	}
+	if len(words) == 0 {
+		return true
+	}
+	given, surname := words[0], words[len(words)-1]
+	for _, line := range lines {
+		tokens := nameTokens(line)
+		surnames := 0
+		for _, t := range tokens {
+			if t == surname {
+				surnames++
+			}
+		}
+		// The surname itself cannot stand in for the given name, as
+		// "J. Jansen" would otherwise match a line with just "Jansen"
+		hasGiven := len(words) == 1
+		for _, t := range tokens {
+			if givenNameMatches(given, t) && (t != surname || surnames > 1) {
+				hasGiven = true
+			}
+		}
+		if surnames > 0 && hasGiven {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// textDatePattern finds dates written in running text
+	textDatePattern = regexp.MustCompile(`\b(\d{4}-\d{2}-\d{2}|\d{1,2}[-/.]\d{1,2}[-/.]\d{4})\b`)
+
+	// monthYearPattern finds a month written out, followed by a year
+	monthYearPattern = regexp.MustCompile(`(?i)\b(januari|january|februari|february|maart|march|april|mei|may|juni|june|juli|july|augustus|august|september|oktober|october|november|december|jan|feb|mrt|mar|apr|jun|jul|aug|sep|sept|okt|oct|nov|dec)\.?\s+(\d{4})\b`)
+)
+
+// monthNumbers are the months by their Dutch and English names and
+// abbreviations
+var monthNumbers = map[string]time.Month{
+	"januari": 1, "january": 1, "jan": 1,
+	"februari": 2, "february": 2, "feb": 2,
+	"maart": 3, "march": 3, "mrt": 3, "mar": 3,
+	"april": 4, "apr": 4,
+	"mei": 5, "may": 5,
+	"juni": 6, "june": 6, "jun": 6,
+	"juli": 7, "july": 7, "jul": 7,
+	"augustus": 8, "august": 8, "aug": 8,
+	"september": 9, "sep": 9, "sept": 9,
+	"oktober": 10, "october": 10, "okt": 10, "oct": 10,
+	"november": 11, "nov": 11,
+	"december": 12, "dec": 12,
+}
+
+// textPeriod returns the month mentioned most in the lines, by the months
+// written out with a year and by the dates, or "" if there are none
+func textPeriod(lines []string) string {
+	counts := map[string]int{}
+	best := ""
+	count := func(month string) {
+		counts[month]++
+		if counts[month] > counts[best] || (counts[month] == counts[best] && month < best) {
+			best = month
+		}
+	}
+	for _, line := range lines {
+		for _, m := range monthYearPattern.FindAllStringSubmatch(line, -1) {
+			count(fmt.Sprintf("%s-%02d", m[2], monthNumbers[strings.ToLower(m[1])]))
+		}
+		for _, m := range textDatePattern.FindAllString(line, -1) {
+			if d, ok := parseTableDate(m); ok {
+				count(d[:7])
+			}
+		}
+	}
+	return best
+}
+
+// documentLines returns the text lines of all pages of a PDF, with the title,
+// subject and author of its document info
+func documentLines(ctx *pdfmodel.Context) ([]string, error) {
+	lines := []string{ctx.Title, ctx.Subject, ctx.Author}
+	for nr := 1; nr <= ctx.PageCount; nr++ {
+		page, err := analyzePageContext(ctx, nr)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range page.textLines() {
+			lines = append(lines, line.Text)
+		}
+	}
+	return lines, nil
+}
+
+// documentPeriod returns the month a timesheet covers: the month of most rows
+// of its hours table, or else the month mentioned most in its text
+func documentPeriod(ctx *pdfmodel.Context, lines []string) string {
+	if table, err := extractTable(ctx); err == nil {
+		if period := tablePeriod(table); period != "" {
+			return period
+		}
+	}
+	return textPeriod(lines)
+}
+
+// contextPeriod returns the month a PDF covers by its content, or "" if it
+// cannot be told
+func contextPeriod(ctx *pdfmodel.Context) string {
+	lines, err := documentLines(ctx)
+	if err != nil {
+		return ""
+	}
+	return documentPeriod(ctx, lines)
+}
+
+// pdfPeriod returns the month the PDF at path covers, or "" if it cannot be
+// told
+func pdfPeriod(path string) string {
+	ctx, err := api.ReadContextFile(path)
+	if err != nil {
+		return ""
+	}
+	return contextPeriod(ctx)
+}
+
+// checkContent checks that a PDF is the timesheet of the employee in opts for
+// a plausible period, and returns what does not match. The period must be
+// opts.Period if set, and otherwise at most maxPeriodAge months old and not in
+// the future. Documents without a recognizable period only get the name
+// checked.
+func checkContent(ctx *pdfmodel.Context, opts SignOptions, now time.Time) ([]string, error) {
+	lines, err := documentLines(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var mismatches []string
+	if opts.EmployeeName != "" && !nameInLines(opts.EmployeeName, lines) {
+		mismatches = append(mismatches, fmt.Sprintf("%q does not appear in the PDF", opts.EmployeeName))
+	}
+
+	period := documentPeriod(ctx, lines)
+	switch {
+	case period == "":
+	case opts.Period != "":
+		if period != opts.Period {
+			mismatches = append(mismatches, fmt.Sprintf("the PDF is for %s, not %s", period, opts.Period))
+		}
+	default:
+		month, err := time.Parse("2006-01", period)
+		if err != nil {
+			break
+		}
+		current := civilDate(now.Year(), now.Month(), 1)
+		if month.After(current) {
+			mismatches = append(mismatches, fmt.Sprintf("the PDF is for %s, which has not started yet", period))
+		} else if age := (current.Year()-month.Year())*12 + int(current.Month()-month.Month()); age > maxPeriodAge {
+			mismatches = append(mismatches, fmt.Sprintf("the PDF is for %s, %d months ago", period, age))
+		}
+	}
+	return mismatches, nil
+}
+
+// contentError is the error of a failed content check, listing the mismatches
+func contentError(mismatches []string) error {
+	return fmt.Errorf("%w: %s (use -force to sign anyway)", ErrContentMismatch, strings.Join(mismatches, "; "))
+}
+
+// checkDocument checks that the selected file is the employee's timesheet and
+// asks for confirmation if it does not look like it, or goes on to checking
+// the hours
+func (m model) checkDocument() model {
+	opts := signOptionsFromConfig(m.config)
+	opts.Period = m.signPeriod
+	m.mismatches = nil
+	if ctx, err := api.ReadContextFile(m.selectedFile); err == nil && opts.CheckContent {
+		m.mismatches, _ = checkContent(ctx, opts, time.Now())
+	}
+	if len(m.mismatches) == 0 {
+		return m.checkHours()
+	}
+	m.screen = screenMismatch
+	return m
+}
+
+func (m model) updateMismatch(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.String() {
+		case "y":
+			return m.checkHours(), nil
+		case "n", "esc":
+			m.screen = m.signFrom
+			return m, nil
+		}
+	}
+	return m, nil
+}
+
+func (m model) viewMismatch() string {
+	s := titleStyle.Render("⚠ Is This Your Timesheet?") + "\n\n"
+	s += fmt.Sprintf("%s does not look like your timesheet:\n\n", filepath.Base(m.selectedFile))
+	for _, mismatch := range m.mismatches {
+		s += errorStyle.Render("  • "+mismatch) + "\n"
+	}
+	s += "\nOnly sign it if you are sure it is yours.\n\n"
+	s += "  [y] Yes, this is my timesheet\n"
+	s += "  [n] Cancel\n\n"
+	s += helpStyle.Render("Press y to sign it • n/Esc to cancel")
+	return s
+}
@@ -0,0 +1,704 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ============================================================================
+// CLI
+// ============================================================================
+
+// Exit codes shared by all subcommands
+const (
+	exitOK             = 0
+	exitError          = 1 // other failures, or a check that did not pass
+	exitUsage          = 2
+	exitConfig         = 3
+	exitSignatureImage = 4
+	exitInputPDF       = 5
+	exitOutputWrite    = 6
+	exitAlreadySigned  = 7
+	exitOutputExists   = 8
+	exitEmail          = 9
+	exitUpload         = 10
+	exitHoursData      = 11
+	exitValidation     = 12
+	exitContent        = 13
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+// commands is initialized in init to allow runHelp to refer to it
+var commands []command
+
+func init() {
+	commands = []command{
+		{"sign", "Add signature blocks to a PDF", runSign},
+		{"preview", "Check the signature block placement without signing", runPreview},
+		{"verify", "Check whether PDFs have been signed", runVerify},
+		{"extract", "Export the hours table of a timesheet as CSV or JSON", runExtract},
+		{"generate", "Build a monthly timesheet PDF from hours data", runGenerate},
+		{"import", "Convert a Toggl, Clockify or Harvest export to hours data", runImport},
+		{"compare", "Check the hours of a timesheet against your own hours", runCompare},
+		{"calendar", "Show the working days and expected hours of a month", runCalendar},
+		{"invoice", "Create an invoice for the hours of a signed timesheet", runInvoice},
+		{"status", "Show configuration and PDFs in the current directory", runStatus},
+		{"config", "Show or change configuration values", runConfigCmd},
+		{"email", "Create the email to the manager for a signed PDF", runEmail},
+		{"upload", "Upload a signed PDF to the configured HTTP endpoint", runUpload},
+		{"approve", "Stamp an approval on a signed PDF", runApprove},
+		{"reject", "Stamp a rejection with a reason on a signed PDF", runReject},
+		{"history", "List past signings", runHistory},
+		{"layouts", "Manage layout templates for signature placement", runLayouts},
+		{"fields", "Manage extra fields of the signature block", runFields},
+		{"version", "Show version information", runVersion},
+		{"tui", "Start the interactive interface (default)", runTUI},
+		{"help", "Show help for a command", runHelp},
+	}
+}
+
+func lookupCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// run dispatches args (without the program name) and returns the exit code
+func run(args []string) int {
+	if len(args) == 0 {
+		return runTUI(nil)
+	}
+
+	// Flags before any command: the original flat CLI
+	if strings.HasPrefix(args[0], "-") {
+		switch args[0] {
+		case "-h", "-help", "--help":
+			usage(os.Stdout)
+			return exitOK
+		}
+		return runLegacy(args)
+	}
+
+	cmd, ok := lookupCommand(args[0])
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown command %q\n\n", args[0])
+		usage(os.Stderr)
+		return exitUsage
+	}
+	return cmd.run(args[1:])
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: hours-signer [command] [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run without a command to start the interactive interface.")
+	fmt.Fprintln(w, "Use \"hours-signer help <command>\" for more information about a command.")
+}
+
+// newFlagSet creates a flag set with the shared usage format for a subcommand
+func newFlagSet(name, args, summary string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage: hours-signer %s", name)
+		if args != "" {
+			fmt.Fprintf(out, " %s", args)
+		}
+		fmt.Fprintf(out, "\n\n%s\n", summary)
+		hasFlags := false
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintln(out, "\nFlags:")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+// keyValueFlag collects repeated key=value flags
+type keyValueFlag map[string]string
+
+func (f keyValueFlag) String() string {
+	pairs := make([]string, 0, len(f))
+	for k, v := range f {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ", ")
+}
+
+func (f keyValueFlag) Set(value string) error {
+	k, v, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(k) == "" {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	f[strings.TrimSpace(k)] = v
+	return nil
+}
+
+// parseFlags parses args allowing flags and positional arguments to be mixed.
+// It returns the positional arguments and the exit code to use when parsing
+// stopped early (help requested or invalid flags).
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, int, bool) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, exitOK, false
+			}
+			return nil, exitUsage, false
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, exitOK, true
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// defaultOutputName returns the name of the signed timesheet of the PDF at
+// input, by the month it covers or else the current month
+func defaultOutputName(input string) string {
+	return signedOutputName(SignOptions{DocumentPeriod: pdfPeriod(input)}.period())
+}
+
+// signedOutputName returns the name of the signed timesheet of a period
+func signedOutputName(period string) string {
+	return fmt.Sprintf("Urenstaat-%s-signed.pdf", period)
+}
+
+// ============================================================================
+// Commands
+// ============================================================================
+
+func runSign(args []string) int {
+	cfg := LoadConfig()
+
+	fs := newFlagSet("sign", "[flags] <input.pdf>", "Add employee and manager signature blocks to the last page of a PDF.")
+	inputFile := fs.String("input", "", "Input PDF file (alternative to the positional argument)")
+	outputFile := fs.String("output", "", "Output PDF file (default: Urenstaat-<period>-signed.pdf)")
+	period := fs.String("period", "", "Month the timesheet covers as yyyy-mm (default: read from the PDF, else the current month)")
+	date := fs.String("date", "", "Signing date as dd-mm-yyyy (default: the last working day of the period, or today if earlier)")
+	employeeName := fs.String("employee", cfg.EmployeeName, "Employee name")
+	managerName := fs.String("manager", cfg.ManagerName, "Manager name")
+	signaturePath := fs.String("signature", cfg.SignaturePath, "Path to signature image (PNG/JPG)")
+	force := fs.Bool("force", false, "Sign already signed PDFs and PDFs that fail the content checks, and overwrite an existing output file")
+	noClobber := fs.Bool("no-clobber", false, "Add a numeric suffix to the output name instead of overwriting an existing file")
+	outputMode := fs.String("mode", "", "Permissions for a new output file, e.g. 0600 (default: from config or 0644)")
+	fields := keyValueFlag{}
+	fs.Var(fields, "field", "Value of an extra field, as `key=value` (repeatable; see hours-signer fields)")
+	lang := fs.String("lang", cfg.language(), "Language of the signature block labels: nl or en")
+	total := fs.String("total", "", "Total hours, instead of the total read from the PDF")
+	totalMode := fs.String("total-mode", cfg.totalMode(), "What to do with the total hours: off, record in the metadata, or stamp")
+	attachTable := fs.String("attach-table", cfg.AttachTable, "Embed the hours table in the signed PDF as csv or json")
+	validation := fs.String("validation", cfg.validationRules().Mode, "Checking the hours before signing: off, warn, or strict to refuse signing when a rule is broken")
+	dryRun := fs.Bool("dry-run", false, "Report where the signature block would go and what it covers, without writing output")
+	email := fs.Bool("email", false, "Create the email to the manager after signing (see the email_* config keys)")
+	send := fs.Bool("send", false, "Send the email to the manager over SMTP after signing (see the smtp_* config keys)")
+	upload := fs.Bool("upload", false, "Upload the signed PDF after signing (see the upload_* config keys)")
+	jsonOut := fs.Bool("json", false, "Print the result as JSON")
+	positional, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+
+	input := *inputFile
+	if input == "" && len(positional) > 0 {
+		input = positional[0]
+		positional = positional[1:]
+	}
+	if input == "" || len(positional) > 0 {
+		fmt.Fprintln(os.Stderr, "Error: exactly one input PDF is required")
+		fs.Usage()
+		return exitUsage
+	}
+
+	opts := signOptionsFromConfig(cfg)
+	opts.EmployeeName = *employeeName
+	opts.ManagerName = *managerName
+	opts.SignaturePath = *signaturePath
+	opts.Force = *force
+	opts.Fields = fields
+	if *period != "" {
+		if err := validatePeriod(*period); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -period %v\n", err)
+			return exitUsage
+		}
+	}
+	opts.Period = *period
+	if *date != "" {
+		d, err := parseSigningDate(*date)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -date %v\n", err)
+			return exitUsage
+		}
+		opts.Date = d
+	}
+	if err := validateLanguage(*lang); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -lang %v\n", err)
+		return exitUsage
+	}
+	opts.Language = *lang
+	if code := applyTotal(&opts, *total, *totalMode); code != exitOK {
+		return code
+	}
+	if *attachTable != "" {
+		if err := validateTableFormat(*attachTable); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -attach-table %v\n", err)
+			return exitUsage
+		}
+	}
+	opts.AttachTable = *attachTable
+	if err := validateValidationMode(*validation); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -validation %v\n", err)
+		return exitUsage
+	}
+	opts.Validation.Mode = *validation
+	if code := applyOutputMode(&opts, *outputMode); code != exitOK {
+		return code
+	}
+	if *dryRun {
+		return preview(input, opts, false, *jsonOut)
+	}
+	var emailCfg *EmailConfig
+	if *email || *send {
+		emailCfg = cfg.email()
+	}
+	if *send {
+		sendCfg := *emailCfg
+		sendCfg.Deliver = deliverSMTP
+		emailCfg = &sendCfg
+	}
+	var uploadCfg *UploadConfig
+	if *upload {
+		uploadCfg = cfg.upload()
+	}
+	return sign(input, *outputFile, opts, emailCfg, uploadCfg, *noClobber, *jsonOut)
+}
+
+// applyOutputMode overrides the configured output mode with a -mode flag value
+func applyOutputMode(opts *SignOptions, mode string) int {
+	if mode == "" {
+		return exitOK
+	}
+	parsed, err := parseFileMode(mode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	opts.OutputMode = parsed
+	return exitOK
+}
+
+// applyTotal sets how the total hours are handled from the -total and
+// -total-mode flag values
+func applyTotal(opts *SignOptions, total, mode string) int {
+	if err := validateTotalMode(mode); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -total-mode %v\n", err)
+		return exitUsage
+	}
+	opts.Total = mode
+	if total == "" {
+		return exitOK
+	}
+	t, err := manualTotal(total)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -total %v\n", err)
+		return exitUsage
+	}
+	if mode == totalOff {
+		opts.Total = totalRecord
+	}
+	opts.TotalHours = t
+	return exitOK
+}
+
+// sign signs input and, if email or upload is set, creates the email to the
+// manager or uploads the signed PDF
+func sign(input, output string, opts SignOptions, email *EmailConfig, upload *UploadConfig, noClobber, jsonOut bool) int {
+	if opts.Period == "" {
+		opts.DocumentPeriod = pdfPeriod(input)
+	}
+	if output == "" {
+		output = signedOutputName(opts.period())
+	}
+	if noClobber {
+		output = nonClobberingPath(output)
+	}
+
+	result, err := signPDF(input, output, opts)
+	if err != nil {
+		return fail(jsonOut, err)
+	}
+
+	// The PDF is signed even if the email or upload fails; report all.
+	// The first failure determines the exit code.
+	var emailErr, uploadErr error
+	if email != nil {
+		result.Email, emailErr = emailSignedPDF(*email, result.HistoryEntry, opts.OutputMode)
+	}
+	if upload != nil {
+		result.Upload, uploadErr = uploadSignedPDF(*upload, result.HistoryEntry)
+	}
+	afterErr := errors.Join(emailErr, uploadErr)
+
+	if jsonOut {
+		out := struct {
+			OK bool `json:"ok"`
+			*SignResult
+			Error    string    `json:"error,omitempty"`
+			Kind     ErrorKind `json:"error_kind,omitempty"`
+			ExitCode int       `json:"exit_code,omitempty"`
+		}{OK: afterErr == nil, SignResult: result}
+		if afterErr != nil {
+			out.Error, out.Kind, out.ExitCode = afterErr.Error(), errorKind(afterErr), exitCodeFor(afterErr)
+		}
+		writeJSON(out)
+		return exitCodeFor(afterErr)
+	}
+	fmt.Printf("✓ Created signed PDF: %s\n", output)
+	fmt.Printf("  Employee: %s\n", opts.EmployeeName)
+	fmt.Printf("  Manager: %s\n", opts.ManagerName)
+	fmt.Printf("  Date: %s\n", result.Date)
+	if result.LayoutTemplate != "" {
+		fmt.Printf("  Layout: %s\n", result.LayoutTemplate)
+	}
+	if result.TotalHours != nil {
+		fmt.Printf("  Total hours: %s (%s)\n", formatHours(*result.TotalHours, opts.Language), result.TotalSource)
+	}
+	if result.Attachment != "" {
+		fmt.Printf("  Attached: %s\n", result.Attachment)
+	}
+	for _, w := range result.Warnings {
+		fmt.Printf("⚠ %s\n", w)
+	}
+	for _, issue := range result.Validation {
+		fmt.Printf("⚠ %s\n", issue.Message)
+	}
+	if emailErr != nil {
+		fail(false, emailErr)
+	} else if result.Email != nil {
+		printEmailResult(result.Email)
+	}
+	if uploadErr != nil {
+		fail(false, uploadErr)
+	} else if result.Upload != nil {
+		printUploadResult(result.Upload)
+	}
+	return exitCodeFor(afterErr)
+}
+
+func runVerify(args []string) int {
+	fs := newFlagSet("verify", "<file.pdf>...", "Check whether PDFs carry the signed marker. Exits with 1 if any file is not signed.")
+	jsonOut := fs.Bool("json", false, "Print the results as JSON")
+	files, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: at least one PDF is required")
+		fs.Usage()
+		return exitUsage
+	}
+
+	type verifyResult struct {
+		Path       string `json:"path"`
+		Signed     bool   `json:"signed"`
+		SignedAt   string `json:"signed_at,omitempty"`
+		TotalHours string `json:"total_hours,omitempty"`
+		State      string `json:"state,omitempty"`
+		ReviewedAt string `json:"reviewed_at,omitempty"`
+		ReviewedBy string `json:"reviewed_by,omitempty"`
+		Reason     string `json:"reason,omitempty"`
+		Error      string `json:"error,omitempty"`
+	}
+	var results []verifyResult
+
+	code = exitOK
+	for _, file := range files {
+		r := verifyResult{Path: file}
+		props, err := readPDFProperties(file)
+		switch {
+		case err != nil:
+			r.Error = err.Error()
+			// A file that cannot be read outweighs one that is not signed
+			code = exitInputPDF
+		case props[propSigned] != "":
+			r.Signed, r.SignedAt, r.TotalHours = true, props[propSigned], props[propTotal]
+		default:
+			if code == exitOK {
+				code = exitError
+			}
+		}
+		if err == nil {
+			state := stateFromProperties(props)
+			r.State = state.String()
+			switch state {
+			case stateApproved:
+				r.ReviewedAt, r.ReviewedBy = props[propApproved], props[propReviewer]
+			case stateRejected:
+				r.ReviewedAt, r.ReviewedBy, r.Reason = props[propRejected], props[propReviewer], props[propRejectReason]
+			}
+		}
+		results = append(results, r)
+	}
+
+	if *jsonOut {
+		writeJSON(struct {
+			OK    bool           `json:"ok"`
+			Files []verifyResult `json:"files"`
+		}{code == exitOK, results})
+		return code
+	}
+	for _, r := range results {
+		review := ""
+		if r.TotalHours != "" {
+			review = fmt.Sprintf(", %s hours", r.TotalHours)
+		}
+		if r.ReviewedAt != "" {
+			review += fmt.Sprintf(", %s %s by %s", r.State, r.ReviewedAt, r.ReviewedBy)
+		}
+		if r.Reason != "" {
+			review += fmt.Sprintf(": %s", r.Reason)
+		}
+		switch {
+		case r.Error != "":
+			fmt.Printf("✗ %s: %s\n", r.Path, r.Error)
+		case r.Signed:
+			fmt.Printf("✓ %s: signed %s%s\n", r.Path, r.SignedAt, review)
+		default:
+			fmt.Printf("✗ %s: not signed%s\n", r.Path, review)
+		}
+	}
+	return code
+}
+
+func runStatus(args []string) int {
+	fs := newFlagSet("status", "", "Show the current configuration and the signed state of PDFs in the current directory.")
+	jsonOut := fs.Bool("json", false, "Print the status as JSON")
+	positional, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+	if len(positional) > 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	cfg := LoadConfig()
+	if *jsonOut {
+		type pdfStatus struct {
+			Name   string `json:"name"`
+			Signed bool   `json:"signed"`
+			State  string `json:"state"`
+		}
+		out := struct {
+			OK             bool        `json:"ok"`
+			ConfigPath     string      `json:"config_path"`
+			ConfigExists   bool        `json:"config_exists"`
+			EmployeeName   string      `json:"employee_name"`
+			ManagerName    string      `json:"manager_name"`
+			SignaturePath  string      `json:"signature_path"`
+			SignatureError string      `json:"signature_error,omitempty"`
+			PDFs           []pdfStatus `json:"pdfs"`
+		}{
+			OK:            true,
+			ConfigPath:    ConfigPath(),
+			ConfigExists:  ConfigExists(),
+			EmployeeName:  cfg.EmployeeName,
+			ManagerName:   cfg.ManagerName,
+			SignaturePath: cfg.SignaturePath,
+			PDFs:          []pdfStatus{},
+		}
+		if cfg.SignaturePath == "" {
+			out.SignatureError = "not configured"
+		} else if err := validateSignaturePath(cfg.SignaturePath); err != nil {
+			out.SignatureError = err.Error()
+		}
+		for _, pdf := range scanPDFs() {
+			out.PDFs = append(out.PDFs, pdfStatus{pdf.name, pdf.state != stateUnsigned, pdf.state.String()})
+		}
+		writeJSON(out)
+		return exitOK
+	}
+	if ConfigExists() {
+		fmt.Printf("Config file: %s\n", ConfigPath())
+	} else {
+		fmt.Printf("Config file: %s (not created yet)\n", ConfigPath())
+	}
+	fmt.Printf("Employee name: %s\n", cfg.EmployeeName)
+	fmt.Printf("Manager name: %s\n", cfg.ManagerName)
+	if cfg.SignaturePath == "" {
+		fmt.Println("Signature: (not configured)")
+	} else if err := validateSignaturePath(cfg.SignaturePath); err != nil {
+		fmt.Printf("Signature path: %s (%v)\n", cfg.SignaturePath, err)
+	} else {
+		fmt.Printf("Signature path: %s\n", cfg.SignaturePath)
+	}
+
+	pdfs := scanPDFs()
+	fmt.Println()
+	if len(pdfs) == 0 {
+		fmt.Println("No PDF files found in current directory")
+		return exitOK
+	}
+	fmt.Println("PDF files:")
+	for _, pdf := range pdfs {
+		fmt.Printf("  %-40s %s\n", pdf.name, pdf.state)
+	}
+	return exitOK
+}
+
+func runVersion(args []string) int {
+	fs := newFlagSet("version", "", "Show version information and check for updates.")
+	jsonOut := fs.Bool("json", false, "Print the version information as JSON")
+	positional, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+	if len(positional) > 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	if *jsonOut {
+		out := struct {
+			OK              bool   `json:"ok"`
+			Version         string `json:"version"`
+			Latest          string `json:"latest,omitempty"`
+			UpdateAvailable bool   `json:"update_available"`
+		}{OK: true, Version: version}
+		if latest, err := checkLatestVersion(); err == nil {
+			out.Latest = latest
+			out.UpdateAvailable = latest != version
+		}
+		writeJSON(out)
+		return exitOK
+	}
+	fmt.Printf("hours-signer v%s\n", version)
+	if latest, err := checkLatestVersion(); err == nil {
+		if latest != version {
+			fmt.Printf("Update available: v%s\n", latest)
+			fmt.Println("Run: brew upgrade hours-signer")
+		} else {
+			fmt.Println("You are running the latest version")
+		}
+	}
+	return exitOK
+}
+
+func runTUI(args []string) int {
+	fs := newFlagSet("tui", "", "Start the interactive interface.")
+	positional, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+	if len(positional) > 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	p := tea.NewProgram(initialModel())
+	if _, err := p.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+func runHelp(args []string) int {
+	if len(args) == 0 {
+		usage(os.Stdout)
+		return exitOK
+	}
+	cmd, ok := lookupCommand(args[0])
+	if !ok || cmd.name == "help" {
+		fmt.Fprintf(os.Stderr, "Error: unknown command %q\n\n", args[0])
+		usage(os.Stderr)
+		return exitUsage
+	}
+	// Every command prints its own help for -h
+	return cmd.run([]string{"-h"})
+}
+
+// runLegacy handles the original flat flag set (hours-signer -input x.pdf,
+// -init, -show-config, -version) so existing scripts keep working.
+func runLegacy(args []string) int {
+	cfg := LoadConfig()
+
+	fs := newFlagSet("", "", "")
+	inputFile := fs.String("input", "", "Input PDF file (required)")
+	outputFile := fs.String("output", "", "Output PDF file (default: Urenstaat-<year>-<month>-signed.pdf)")
+	employeeName := fs.String("employee", cfg.EmployeeName, "Employee name")
+	managerName := fs.String("manager", cfg.ManagerName, "Manager name")
+	signaturePath := fs.String("signature", cfg.SignaturePath, "Path to signature image (PNG/JPG)")
+	initConfig := fs.Bool("init", false, "Initialize config file with defaults")
+	showConfig := fs.Bool("show-config", false, "Show current configuration")
+	showVersion := fs.Bool("version", false, "Show version information")
+	force := fs.Bool("force", false, "Sign already signed PDFs and overwrite an existing output file")
+	noClobber := fs.Bool("no-clobber", false, "Add a numeric suffix to the output name instead of overwriting an existing file")
+	outputMode := fs.String("mode", "", "Permissions for a new output file, e.g. 0600 (default: from config or 0644)")
+	dryRun := fs.Bool("dry-run", false, "Report where the signature block would go and what it covers, without writing output")
+	jsonOut := fs.Bool("json", false, "Print the result as JSON")
+	fs.Usage = func() { usage(fs.Output()) }
+	positional, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+	if len(positional) > 0 {
+		fmt.Fprintf(os.Stderr, "Error: unexpected argument %q\n\n", positional[0])
+		usage(os.Stderr)
+		return exitUsage
+	}
+
+	var jsonArgs []string
+	if *jsonOut {
+		jsonArgs = []string{"-json"}
+	}
+	switch {
+	case *showVersion:
+		return runVersion(jsonArgs)
+	case *initConfig:
+		return runConfigCmd(append([]string{"init"}, jsonArgs...))
+	case *showConfig:
+		return runConfigCmd(append([]string{"show"}, jsonArgs...))
+	}
+
+	if *inputFile == "" {
+		fmt.Fprintln(os.Stderr, "Error: -input is required")
+		usage(os.Stderr)
+		return exitUsage
+	}
+	opts := signOptionsFromConfig(cfg)
+	opts.EmployeeName = *employeeName
+	opts.ManagerName = *managerName
+	opts.SignaturePath = *signaturePath
+	opts.Force = *force
+	if code := applyOutputMode(&opts, *outputMode); code != exitOK {
+		return code
+	}
+	if *dryRun {
+		return preview(*inputFile, opts, false, *jsonOut)
+	}
+	return sign(*inputFile, *outputFile, opts, nil, nil, *noClobber, *jsonOut)
+}
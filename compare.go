@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// ============================================================================
+// Hours Comparison
+// ============================================================================
+
+// dayDifference is a day on which a timesheet and the hours data disagree
+type dayDifference struct {
+	Date  string  `json:"date"` // yyyy-mm-dd
+	PDF   float64 `json:"pdf_hours"`
+	Hours float64 `json:"hours"`
+}
+
+// tableDays returns the hours per day of the rows of a timesheet table
+func tableDays(t *hoursTable) map[string]float64 {
+	days := map[string]float64{}
+	for _, r := range t.Rows {
+		if r.Date != "" && r.Hours != nil {
+			days[r.Date] += *r.Hours
+		}
+	}
+	return days
+}
+
+func entryDays(entries []Entry) map[string]float64 {
+	days := map[string]float64{}
+	for _, e := range entries {
+		days[e.Date] += e.Hours
+	}
+	return days
+}
+
+// tablePeriod returns the month most dated rows of a table fall in
+func tablePeriod(t *hoursTable) string {
+	counts := map[string]int{}
+	best := ""
+	for _, r := range t.Rows {
+		if len(r.Date) < 7 {
+			continue
+		}
+		month := r.Date[:7]
+		counts[month]++
+		if counts[month] > counts[best] || (counts[month] == counts[best] && month < best) {
+			best = month
+		}
+	}
+	return best
+}
+
+// compareDays returns the days of period on which pdf and hours differ, in
+// order. Values rounded to two decimals are the same.
+func compareDays(pdf, hours map[string]float64, period string) []dayDifference {
+	dates := map[string]bool{}
+	for d := range pdf {
+		dates[d] = true
+	}
+	for d := range hours {
+		dates[d] = true
+	}
+	var diffs []dayDifference
+	for d := range dates {
+		if d[:7] != period || math.Abs(pdf[d]-hours[d]) < 0.01 {
+			continue
+		}
+		diffs = append(diffs, dayDifference{Date: d, PDF: pdf[d], Hours: hours[d]})
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Date < diffs[j].Date })
+	return diffs
+}
+
+func sumHours(days map[string]float64, period string) float64 {
+	sum := 0.0
+	for d, h := range days {
+		if d[:7] == period {
+			sum += h
+		}
+	}
+	return math.Round(sum*100) / 100
+}
+
+func runCompare(args []string) int {
+	cfg := LoadConfig()
+
+	fs := newFlagSet("compare", "[flags] <timesheet.pdf> <hours file>", "Check the hours table of a timesheet against your own hours: an hours file, a\nToggl Track, Clockify or Harvest export or a calendar. Exits with 1 if any\nday differs.")
+	source := addHoursSourceFlags(fs, cfg)
+	period := fs.String("period", "", "Month to compare as yyyy-mm (default: the month of the timesheet)")
+	jsonOut := fs.Bool("json", false, "Print the result as JSON")
+	positional, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+	if len(positional) != 2 {
+		fmt.Fprintln(os.Stderr, "Error: a timesheet PDF and an hours file are required")
+		fs.Usage()
+		return exitUsage
+	}
+	if *period != "" {
+		if err := validatePeriod(*period); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -period %v\n", err)
+			return exitUsage
+		}
+	}
+	input, hoursFile := positional[0], positional[1]
+
+	ctx, err := api.ReadContextFile(input)
+	if err != nil {
+		return fail(*jsonOut, signError(KindInputPDF, input, fmt.Errorf("failed to read PDF context: %w", err)))
+	}
+	table, err := extractTable(ctx)
+	if err != nil {
+		return fail(*jsonOut, signError(KindInputPDF, input, err))
+	}
+	if *period == "" {
+		if *period = tablePeriod(table); *period == "" {
+			return fail(*jsonOut, signError(KindInputPDF, input, fmt.Errorf("no dated rows in the hours table (use -period)")))
+		}
+	}
+
+	opts, code, ok := source.options(cfg, *period, *jsonOut)
+	if !ok {
+		return code
+	}
+	entries, _, err := loadHours(hoursFile, *source.from, opts)
+	if err != nil {
+		return fail(*jsonOut, err)
+	}
+
+	pdfDays, hoursDays := tableDays(table), entryDays(entries)
+	diffs := compareDays(pdfDays, hoursDays, *period)
+	pdfTotal, hoursTotal := sumHours(pdfDays, *period), sumHours(hoursDays, *period)
+	code = exitOK
+	if len(diffs) > 0 {
+		code = exitError
+	}
+
+	if *jsonOut {
+		if diffs == nil {
+			diffs = []dayDifference{}
+		}
+		writeJSON(struct {
+			OK          bool            `json:"ok"`
+			Input       string          `json:"input"`
+			Hours       string          `json:"hours"`
+			Period      string          `json:"period"`
+			PDFTotal    float64         `json:"pdf_total"`
+			HoursTotal  float64         `json:"hours_total"`
+			Differences []dayDifference `json:"differences"`
+		}{code == exitOK, input, hoursFile, *period, pdfTotal, hoursTotal, diffs})
+		return code
+	}
+	lang := cfg.language()
+	for _, d := range diffs {
+		fmt.Printf("✗ %s: %s in the timesheet, %s in %s\n", d.Date, formatHours(d.PDF, lang), formatHours(d.Hours, lang), hoursFile)
+	}
+	if len(diffs) == 0 {
+		fmt.Printf("✓ %s matches %s for %s: %s hours\n", input, hoursFile, *period, formatHours(pdfTotal, lang))
+	} else {
+		fmt.Printf("\n%d days differ; total %s in the timesheet, %s in %s\n", len(diffs), formatHours(pdfTotal, lang), formatHours(hoursTotal, lang), hoursFile)
+	}
+	return code
+}
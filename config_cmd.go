@@ -0,0 +1,789 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ============================================================================
+// Config Subcommand
+// ============================================================================
+
+// configKey describes a single settable config option
+type configKey struct {
+	name        string
+	description string
+	field       func(cfg *Config) *string
+	validate    func(value string) error
+}
+
+var configKeys = []configKey{
+	{
+		name:        "signature_path",
+		description: "Path to signature image (PNG/JPG)",
+		field:       func(cfg *Config) *string { return &cfg.SignaturePath },
+		validate:    validateSignaturePath,
+	},
+	{
+		name:        "employee_name",
+		description: "Your name for the signature block",
+		field:       func(cfg *Config) *string { return &cfg.EmployeeName },
+		validate:    validateName,
+	},
+	{
+		name:        "manager_name",
+		description: "Manager's name for the signature block",
+		field:       func(cfg *Config) *string { return &cfg.ManagerName },
+		validate:    validateName,
+	},
+	{
+		name:        "output_mode",
+		description: "Permissions for new signed PDFs, e.g. 0600 (default 0644)",
+		field:       func(cfg *Config) *string { return &cfg.OutputMode },
+		validate: func(value string) error {
+			_, err := parseFileMode(value)
+			return err
+		},
+	},
+	{
+		name:        "language",
+		description: "Language of the signature block labels: nl (default) or en",
+		field:       func(cfg *Config) *string { return &cfg.Language },
+		validate:    validateLanguage,
+	},
+	{
+		name:        "total_hours",
+		description: "Total hours of the timesheet: off, record (default) in the metadata, or stamp",
+		field:       func(cfg *Config) *string { return &cfg.TotalHours },
+		validate:    validateTotalMode,
+	},
+	{
+		name:        "company_name",
+		description: "Company name on generated timesheets",
+		field:       func(cfg *Config) *string { return &cfg.CompanyName },
+		validate:    validateName,
+	},
+	{
+		name:        "logo_path",
+		description: "Logo (PNG/JPG) on generated timesheets",
+		field:       func(cfg *Config) *string { return &cfg.LogoPath },
+		validate:    func(path string) error { return validateImagePath("logo", path) },
+	},
+	{
+		name:        "contract_hours",
+		description: "Contract hours per week, to check the hours of a month against",
+		field:       func(cfg *Config) *string { return &cfg.ContractHours },
+		validate:    validateContractHours,
+	},
+	{
+		name:        "holiday_country",
+		description: "Public holidays that are not working days: nl, be, de, gb or none (default: nl)",
+		field:       func(cfg *Config) *string { return &cfg.HolidayCountry },
+		validate:    validateHolidayCountry,
+	},
+	{
+		name:        "days_off",
+		description: "Extra days off, e.g. 2026-05-15, 2026-07-20..2026-08-07",
+		field:       func(cfg *Config) *string { return &cfg.DaysOff },
+		validate:    validateDaysOff,
+	},
+	{
+		name:        "validation",
+		description: "Checking the hours before signing: off, warn, or strict to refuse signing (default: warn)",
+		field:       func(cfg *Config) *string { return &cfg.Validation },
+		validate:    validateValidationMode,
+	},
+	{
+		name:        "max_day_hours",
+		description: "Most hours in a day before validation warns (default: 12)",
+		field:       func(cfg *Config) *string { return &cfg.MaxDayHours },
+		validate:    validateMaxDayHours,
+	},
+	{
+		name:        "max_deviation",
+		description: "Most the month may deviate from contract_hours, in percent; 0 for no check (default: 10)",
+		field:       func(cfg *Config) *string { return &cfg.MaxDeviation },
+		validate:    validateMaxDeviation,
+	},
+	{
+		name:        "weekend_hours",
+		description: "Hours on weekends, holidays and days off: warn or allow (default: warn)",
+		field:       func(cfg *Config) *string { return &cfg.WeekendHours },
+		validate:    validateCheck,
+	},
+	{
+		name:        "missing_days",
+		description: "Working days without hours: warn or allow (default: warn)",
+		field:       func(cfg *Config) *string { return &cfg.MissingDays },
+		validate:    validateCheck,
+	},
+	{
+		name:        "content_checks",
+		description: "Check before signing that a PDF carries your name and a plausible period: on or off (default: on)",
+		field:       func(cfg *Config) *string { return &cfg.ContentChecks },
+		validate:    validateContentChecks,
+	},
+	{
+		name:        "rounding",
+		description: "Rounding of imported hours per day and project, e.g. 15m, up:15m or down:6m (default: off)",
+		field:       func(cfg *Config) *string { return &cfg.Rounding },
+		validate:    validateRounding,
+	},
+	{
+		name:        "project_clients",
+		description: "Clients of time tracker projects, e.g. \"Website=Acme, API=Acme\"",
+		field:       func(cfg *Config) *string { return &cfg.ProjectClients },
+		validate:    validateProjectClients,
+	},
+	{
+		name:        "attach_table",
+		description: "Embed the hours table in signed PDFs as csv or json (default: none)",
+		field:       func(cfg *Config) *string { return &cfg.AttachTable },
+		validate:    validateTableFormat,
+	},
+	{
+		name:        "email_to",
+		description: "Recipients of the email after signing, comma separated",
+		field:       func(cfg *Config) *string { return &cfg.email().To },
+		validate:    validateAddressList,
+	},
+	{
+		name:        "email_cc",
+		description: "Cc recipients of the email, comma separated",
+		field:       func(cfg *Config) *string { return &cfg.email().Cc },
+		validate:    validateAddressList,
+	},
+	{
+		name:        "email_from",
+		description: "Sender address of the email",
+		field:       func(cfg *Config) *string { return &cfg.email().From },
+		validate:    validateAddress,
+	},
+	{
+		name:        "email_subject",
+		description: "Subject template, e.g. \"Urenstaat {period} - {employee}\"",
+		field:       func(cfg *Config) *string { return &cfg.email().Subject },
+		validate:    func(string) error { return nil },
+	},
+	{
+		name:        "email_body",
+		description: "Body template; {period}, {employee}, {manager} and {file} are replaced",
+		field:       func(cfg *Config) *string { return &cfg.email().Body },
+		validate:    func(string) error { return nil },
+	},
+	{
+		name:        "email_deliver",
+		description: "What to do with the email: file (write .eml next to the PDF), sendmail or smtp",
+		field:       func(cfg *Config) *string { return &cfg.email().Deliver },
+		validate:    validateDeliver,
+	},
+	{
+		name:        "email_sendmail",
+		description: "Command used for sendmail delivery (default \"sendmail -t -i\")",
+		field:       func(cfg *Config) *string { return &cfg.email().Sendmail },
+		validate:    func(string) error { return nil },
+	},
+	{
+		name:        "smtp_host",
+		description: "Mail server for smtp delivery and sign -send",
+		field:       func(cfg *Config) *string { return &cfg.email().smtp().Host },
+		validate:    validateHost,
+	},
+	{
+		name:        "smtp_port",
+		description: "Mail server port (default 587, or 465 for tls)",
+		field:       func(cfg *Config) *string { return &cfg.email().smtp().Port },
+		validate:    validatePort,
+	},
+	{
+		name:        "smtp_security",
+		description: "Connection security: starttls (default), tls or none",
+		field:       func(cfg *Config) *string { return &cfg.email().smtp().Security },
+		validate:    validateSMTPSecurity,
+	},
+	{
+		name:        "smtp_username",
+		description: "SMTP login; leave empty for servers without authentication",
+		field:       func(cfg *Config) *string { return &cfg.email().smtp().Username },
+		validate:    func(string) error { return nil },
+	},
+	{
+		name:        "smtp_password_env",
+		description: "Environment variable holding the SMTP password (default HOURS_SIGNER_SMTP_PASSWORD)",
+		field:       func(cfg *Config) *string { return &cfg.email().smtp().PasswordEnv },
+		validate:    validateName,
+	},
+	{
+		name:        "smtp_password_command",
+		description: "Command printing the SMTP password, e.g. \"pass show mail/smtp\"",
+		field:       func(cfg *Config) *string { return &cfg.email().smtp().PasswordCommand },
+		validate:    func(string) error { return nil },
+	},
+	{
+		name:        "upload_url",
+		description: "HTTP endpoint signed PDFs are uploaded to; may contain {period}, {employee}",
+		field:       func(cfg *Config) *string { return &cfg.upload().URL },
+		validate:    validateUploadURL,
+	},
+	{
+		name:        "upload_method",
+		description: "HTTP method of the upload: POST (default), PUT or PATCH",
+		field:       func(cfg *Config) *string { return &cfg.upload().Method },
+		validate:    validateUploadMethod,
+	},
+	{
+		name:        "upload_file_field",
+		description: "Form field the PDF is sent in (default \"file\")",
+		field:       func(cfg *Config) *string { return &cfg.upload().FileField },
+		validate:    validateName,
+	},
+	{
+		name:        "upload_auth",
+		description: "Upload authentication: none (default), bearer or basic",
+		field:       func(cfg *Config) *string { return &cfg.upload().Auth },
+		validate:    validateUploadAuth,
+	},
+	{
+		name:        "upload_username",
+		description: "User name for basic authentication",
+		field:       func(cfg *Config) *string { return &cfg.upload().Username },
+		validate:    validateName,
+	},
+	{
+		name:        "upload_token_env",
+		description: "Environment variable holding the token or password (default HOURS_SIGNER_UPLOAD_TOKEN)",
+		field:       func(cfg *Config) *string { return &cfg.upload().TokenEnv },
+		validate:    validateName,
+	},
+	{
+		name:        "upload_token_command",
+		description: "Command printing the upload token or password",
+		field:       func(cfg *Config) *string { return &cfg.upload().TokenCommand },
+		validate:    func(string) error { return nil },
+	},
+	{
+		name:        "upload_retries",
+		description: "Retries after a network error or 5xx response (default 2)",
+		field:       func(cfg *Config) *string { return &cfg.upload().Retries },
+		validate:    validateRetries,
+	},
+	{
+		name:        "invoice_rate",
+		description: "Hourly rate in euros on invoices, excluding VAT",
+		field:       func(cfg *Config) *string { return &cfg.invoice().Rate },
+		validate:    validateRate,
+	},
+	{
+		name:        "invoice_vat",
+		description: "VAT percentage on invoices (default 21)",
+		field:       func(cfg *Config) *string { return &cfg.invoice().VAT },
+		validate:    validateVAT,
+	},
+	{
+		name:        "invoice_terms",
+		description: "Payment terms of invoices in days (default 30)",
+		field:       func(cfg *Config) *string { return &cfg.invoice().Terms },
+		validate:    validateTerms,
+	},
+	{
+		name:        "invoice_prefix",
+		description: "Prefix of invoice numbers; {year} is replaced (default \"{year}-\")",
+		field:       func(cfg *Config) *string { return &cfg.invoice().Prefix },
+		validate:    func(string) error { return nil },
+	},
+	{
+		name:        "invoice_company",
+		description: "Name of your business on invoices (default: company_name or employee_name)",
+		field:       func(cfg *Config) *string { return &cfg.invoice().Company },
+		validate:    func(string) error { return nil },
+	},
+	{
+		name:        "invoice_address",
+		description: "Address of your business, lines separated by commas",
+		field:       func(cfg *Config) *string { return &cfg.invoice().Address },
+		validate:    func(string) error { return nil },
+	},
+	{
+		name:        "invoice_kvk",
+		description: "Chamber of Commerce (KvK) number of your business",
+		field:       func(cfg *Config) *string { return &cfg.invoice().KvK },
+		validate:    validateKvK,
+	},
+	{
+		name:        "invoice_btw",
+		description: "VAT (BTW) number of your business, e.g. NL123456789B01",
+		field:       func(cfg *Config) *string { return &cfg.invoice().BTW },
+		validate:    validateBTW,
+	},
+	{
+		name:        "invoice_iban",
+		description: "IBAN invoices are paid to",
+		field:       func(cfg *Config) *string { return &cfg.invoice().IBAN },
+		validate:    validateIBAN,
+	},
+	{
+		name:        "invoice_client",
+		description: "Name of the client invoices are for",
+		field:       func(cfg *Config) *string { return &cfg.invoice().Client },
+		validate:    func(string) error { return nil },
+	},
+	{
+		name:        "invoice_client_address",
+		description: "Address of the client, lines separated by commas",
+		field:       func(cfg *Config) *string { return &cfg.invoice().ClientAddress },
+		validate:    func(string) error { return nil },
+	},
+	{
+		name:        "invoice_client_kvk",
+		description: "KvK number of the client, for UBL invoices",
+		field:       func(cfg *Config) *string { return &cfg.invoice().ClientKvK },
+		validate:    validateKvK,
+	},
+	{
+		name:        "invoice_client_btw",
+		description: "VAT (BTW) number of the client",
+		field:       func(cfg *Config) *string { return &cfg.invoice().ClientBTW },
+		validate:    validateBTW,
+	},
+	{
+		name:        "invoice_reference",
+		description: "The client's reference on invoices, such as an order number",
+		field:       func(cfg *Config) *string { return &cfg.invoice().Reference },
+		validate:    func(string) error { return nil },
+	},
+	{
+		name:        "invoice_ubl",
+		description: "Also write a UBL e-invoice (NLCIUS) with every invoice: on or off (default off)",
+		field:       func(cfg *Config) *string { return &cfg.invoice().UBL },
+		validate:    validateUBL,
+	},
+}
+
+func lookupConfigKey(name string) (configKey, error) {
+	for _, k := range configKeys {
+		if k.name == name {
+			return k, nil
+		}
+	}
+	var names []string
+	for _, k := range configKeys {
+		names = append(names, k.name)
+	}
+	return configKey{}, fmt.Errorf("unknown config key %q (valid keys: %s)", name, strings.Join(names, ", "))
+}
+
+// expandHome expands a leading ~ to the user's home directory
+func expandHome(path string) string {
+	if path == "" || path[0] != '~' {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// readSecret returns a secret from the environment variable env or, if that
+// is empty, the first line printed by command. Secrets are never stored in the
+// config file; commandKey names the config key of command in error messages.
+func readSecret(what, env, command, commandKey string) (string, error) {
+	if secret := os.Getenv(env); secret != "" {
+		return secret, nil
+	}
+	if command == "" {
+		return "", fmt.Errorf("no %s: set $%s or %s", what, env, commandKey)
+	}
+	parts := strings.Fields(command)
+	cmd := exec.Command(parts[0], parts[1:]...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %w", commandKey, err)
+	}
+	// Only the first line counts, like pass and most keychain tools print it
+	secret, _, _ := strings.Cut(string(out), "\n")
+	return strings.TrimRight(secret, "\r"), nil
+}
+
+func validateSignaturePath(path string) error {
+	return validateImagePath("signature", path)
+}
+
+// validateImagePath checks that path is an existing PNG or JPG file; what
+// names the image in errors
+func validateImagePath(what, path string) error {
+	if strings.TrimSpace(path) == "" {
+		return fmt.Errorf("%s path must not be empty", what)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".jpg", ".jpeg":
+	default:
+		return fmt.Errorf("%s must be a PNG or JPG image: %s", what, path)
+	}
+	info, err := os.Stat(expandHome(path))
+	if err != nil {
+		return fmt.Errorf("%s file not accessible: %w", what, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s path is a directory: %s", what, path)
+	}
+	return nil
+}
+
+func validateName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("name must not be empty")
+	}
+	if strings.ContainsAny(name, "\r\n") {
+		return fmt.Errorf("name must be a single line")
+	}
+	return nil
+}
+
+// validateConfig checks every non-empty value in cfg
+func validateConfig(cfg Config) error {
+	for _, k := range configKeys {
+		value := *k.field(&cfg)
+		if value == "" {
+			continue
+		}
+		if err := k.validate(value); err != nil {
+			return fmt.Errorf("%s: %w", k.name, err)
+		}
+	}
+	if err := validateUpload(cfg.Upload); err != nil {
+		return err
+	}
+	if err := validateFields(cfg.Fields); err != nil {
+		return err
+	}
+	return validateLayouts(cfg.Layouts)
+}
+
+func configUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: hours-signer config <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	fmt.Fprintln(w, "  show               Show the current configuration")
+	fmt.Fprintln(w, "  init               Create a config file with default values")
+	fmt.Fprintln(w, "  get [key]          Print a config value (or all values)")
+	fmt.Fprintln(w, "  set <key> <value>  Validate and store a config value")
+	fmt.Fprintln(w, "  unset <key>        Reset a config value to its default")
+	fmt.Fprintln(w, "  path               Print the config file location")
+	fmt.Fprintln(w, "  edit               Open the config file in $EDITOR")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Add -json to any command to print the result as JSON.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Keys:")
+	for _, k := range configKeys {
+		fmt.Fprintf(w, "  %-16s %s\n", k.name, k.description)
+	}
+}
+
+func runConfigCmd(args []string) int {
+	// -json may appear anywhere; values never start with a dash
+	jsonOut := false
+	var rest []string
+	for _, arg := range args {
+		if arg == "-json" || arg == "--json" {
+			jsonOut = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	args = rest
+	if len(args) == 0 {
+		configUsage(os.Stderr)
+		return exitUsage
+	}
+
+	var err error
+	switch args[0] {
+	case "show":
+		configShow(jsonOut)
+	case "init":
+		err = configInit(jsonOut)
+	case "get":
+		err = configGet(args[1:], jsonOut)
+	case "set":
+		err = configSet(args[1:], jsonOut)
+	case "unset":
+		err = configUnset(args[1:], jsonOut)
+	case "path":
+		if jsonOut {
+			writeJSON(configPathJSON{true, ConfigPath()})
+		} else {
+			fmt.Println(ConfigPath())
+		}
+	case "edit":
+		err = configEdit()
+		if err == nil && jsonOut {
+			writeJSON(configPathJSON{true, ConfigPath()})
+		}
+	case "help", "-h", "-help", "--help":
+		configUsage(os.Stdout)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown config command %q\n\n", args[0])
+		configUsage(os.Stderr)
+		return exitUsage
+	}
+
+	if err != nil {
+		return fail(jsonOut, signError(KindConfig, ConfigPath(), err))
+	}
+	return exitOK
+}
+
+type configPathJSON struct {
+	OK         bool   `json:"ok"`
+	ConfigPath string `json:"config_path"`
+}
+
+// configValueJSON reports a single value read or written
+type configValueJSON struct {
+	OK    bool   `json:"ok"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func configShow(jsonOut bool) {
+	cfg := LoadConfig()
+	if jsonOut {
+		writeJSON(struct {
+			OK         bool   `json:"ok"`
+			ConfigPath string `json:"config_path"`
+			Config     Config `json:"config"`
+		}{true, ConfigPath(), cfg})
+		return
+	}
+	fmt.Printf("Config file: %s\n", ConfigPath())
+	fmt.Printf("Employee name: %s\n", cfg.EmployeeName)
+	fmt.Printf("Manager name: %s\n", cfg.ManagerName)
+	if cfg.SignaturePath != "" {
+		fmt.Printf("Signature path: %s\n", cfg.SignaturePath)
+	} else {
+		fmt.Println("Signature: (not configured)")
+	}
+	if cfg.OutputMode != "" {
+		fmt.Printf("Output mode: %s\n", cfg.OutputMode)
+	}
+	if cfg.Language != "" {
+		fmt.Printf("Language: %s\n", cfg.Language)
+	}
+	if cfg.TotalHours != "" {
+		fmt.Printf("Total hours: %s\n", cfg.TotalHours)
+	}
+	if cfg.CompanyName != "" {
+		fmt.Printf("Company name: %s\n", cfg.CompanyName)
+	}
+	if cfg.LogoPath != "" {
+		fmt.Printf("Logo: %s\n", cfg.LogoPath)
+	}
+	if cfg.ContractHours != "" {
+		fmt.Printf("Contract hours: %s per week\n", cfg.ContractHours)
+	}
+	if cfg.HolidayCountry != "" {
+		fmt.Printf("Holiday country: %s\n", cfg.HolidayCountry)
+	}
+	if cfg.DaysOff != "" {
+		fmt.Printf("Days off: %s\n", cfg.DaysOff)
+	}
+	if cfg.Validation != "" {
+		fmt.Printf("Validation: %s\n", cfg.Validation)
+	}
+	if cfg.MaxDayHours != "" {
+		fmt.Printf("Max hours per day: %s\n", cfg.MaxDayHours)
+	}
+	if cfg.MaxDeviation != "" {
+		fmt.Printf("Max deviation: %s%%\n", strings.TrimSuffix(cfg.MaxDeviation, "%"))
+	}
+	if cfg.WeekendHours != "" {
+		fmt.Printf("Weekend hours: %s\n", cfg.WeekendHours)
+	}
+	if cfg.MissingDays != "" {
+		fmt.Printf("Missing days: %s\n", cfg.MissingDays)
+	}
+	if cfg.ContentChecks != "" {
+		fmt.Printf("Content checks: %s\n", cfg.ContentChecks)
+	}
+	if cfg.Rounding != "" {
+		fmt.Printf("Rounding: %s\n", cfg.Rounding)
+	}
+	if cfg.ProjectClients != "" {
+		fmt.Printf("Project clients: %s\n", cfg.ProjectClients)
+	}
+	if cfg.AttachTable != "" {
+		fmt.Printf("Attach table: %s\n", cfg.AttachTable)
+	}
+	if len(cfg.Fields) > 0 {
+		fmt.Printf("Extra fields: %d (see hours-signer fields list)\n", len(cfg.Fields))
+	}
+	if len(cfg.Layouts) > 0 {
+		fmt.Printf("Layout templates: %d (see hours-signer layouts list)\n", len(cfg.Layouts))
+	}
+	if cfg.Email != nil && cfg.Email.To != "" {
+		fmt.Printf("Email: to %s (%s)\n", cfg.Email.To, cfg.Email.deliver())
+	}
+	if cfg.Email != nil && cfg.Email.SMTP != nil && cfg.Email.SMTP.Host != "" {
+		s := cfg.Email.SMTP
+		fmt.Printf("SMTP: %s:%s (%s)\n", s.Host, s.port(), s.security())
+	}
+	if cfg.Upload != nil && cfg.Upload.URL != "" {
+		fmt.Printf("Upload: %s %s\n", cfg.Upload.method(), cfg.Upload.URL)
+	}
+	if cfg.Invoice != nil && cfg.Invoice.Rate != "" {
+		rate, _ := parseMoney(cfg.Invoice.Rate)
+		fmt.Printf("Invoice: %s an hour to %s, %g%% VAT, %d days\n", rate.format("en"), cfg.Invoice.Client, cfg.Invoice.vat(), cfg.Invoice.terms())
+	}
+}
+
+func configInit(jsonOut bool) error {
+	if err := SaveConfig(DefaultConfig()); err != nil {
+		return err
+	}
+	if jsonOut {
+		writeJSON(configPathJSON{true, ConfigPath()})
+		return nil
+	}
+	fmt.Printf("Config file created at: %s\n", ConfigPath())
+	return nil
+}
+
+func configGet(args []string, jsonOut bool) error {
+	cfg := LoadConfig()
+	if len(args) == 0 {
+		if jsonOut {
+			values := map[string]string{}
+			for _, k := range configKeys {
+				values[k.name] = *k.field(&cfg)
+			}
+			writeJSON(struct {
+				OK     bool              `json:"ok"`
+				Values map[string]string `json:"values"`
+			}{true, values})
+			return nil
+		}
+		for _, k := range configKeys {
+			fmt.Printf("%s=%s\n", k.name, *k.field(&cfg))
+		}
+		return nil
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: hours-signer config get [key]")
+	}
+	k, err := lookupConfigKey(args[0])
+	if err != nil {
+		return err
+	}
+	if jsonOut {
+		writeJSON(configValueJSON{true, k.name, *k.field(&cfg)})
+		return nil
+	}
+	fmt.Println(*k.field(&cfg))
+	return nil
+}
+
+func configSet(args []string, jsonOut bool) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: hours-signer config set <key> <value>")
+	}
+	k, err := lookupConfigKey(args[0])
+	if err != nil {
+		return err
+	}
+	cfg := LoadConfig()
+	if err := k.validate(args[1]); err != nil {
+		return fmt.Errorf("invalid value for %s: %w", k.name, err)
+	}
+	*k.field(&cfg) = args[1]
+	if err := SaveConfig(cfg); err != nil {
+		return err
+	}
+	if jsonOut {
+		writeJSON(configValueJSON{true, k.name, args[1]})
+		return nil
+	}
+	fmt.Printf("%s=%s\n", k.name, args[1])
+	return nil
+}
+
+func configUnset(args []string, jsonOut bool) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: hours-signer config unset <key>")
+	}
+	k, err := lookupConfigKey(args[0])
+	if err != nil {
+		return err
+	}
+	cfg := LoadConfig()
+	defaults := DefaultConfig()
+	*k.field(&cfg) = *k.field(&defaults)
+	if err := SaveConfig(cfg); err != nil {
+		return err
+	}
+	if jsonOut {
+		writeJSON(configValueJSON{true, k.name, *k.field(&cfg)})
+		return nil
+	}
+	fmt.Printf("%s unset\n", k.name)
+	return nil
+}
+
+func configEdit() error {
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = os.Getenv("VISUAL")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// Edit a temporary copy so an invalid edit never replaces a working config
+	data, err := json.MarshalIndent(LoadConfig(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	tmp, err := os.CreateTemp("", "hours-signer-config-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	tmp.Close()
+
+	// EDITOR may contain arguments, e.g. "code --wait"
+	parts := strings.Fields(editor)
+	cmd := exec.Command(parts[0], append(parts[1:], tmp.Name())...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor failed: %w", err)
+	}
+
+	edited, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return fmt.Errorf("failed to read edited config: %w", err)
+	}
+	cfg := DefaultConfig()
+	if err := json.Unmarshal(edited, &cfg); err != nil {
+		return fmt.Errorf("config not saved, invalid JSON: %w", err)
+	}
+	if err := validateConfig(cfg); err != nil {
+		return fmt.Errorf("config not saved: %w", err)
+	}
+	if err := SaveConfig(cfg); err != nil {
+		return err
+	}
+	fmt.Printf("Config saved to: %s\n", ConfigPath())
+	return nil
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// Email
+// ============================================================================
+
+const (
+	defaultEmailSubject = "Urenstaat {period} - {employee}"
+	defaultEmailBody    = "Hoi {manager},\n\nIn de bijlage mijn getekende urenstaat voor {period}.\n\nGroet,\n{employee}\n"
+	defaultSendmail     = "sendmail -t -i"
+)
+
+// Email delivery methods
+const (
+	deliverFile     = "file"
+	deliverSendmail = "sendmail"
+	deliverSMTP     = "smtp"
+)
+
+// EmailConfig describes the message sent to the manager after signing.
+// Subject and body may contain {period}, {employee}, {manager} and {file}.
+type EmailConfig struct {
+	To       string `json:"to,omitempty"` // comma separated addresses
+	Cc       string `json:"cc,omitempty"`
+	From     string `json:"from,omitempty"`
+	Subject  string `json:"subject,omitempty"`
+	Body     string `json:"body,omitempty"`
+	Deliver  string `json:"deliver,omitempty"`  // "file" (default), "sendmail" or "smtp"
+	Sendmail string `json:"sendmail,omitempty"` // command for "sendmail" delivery
+
+	SMTP *SMTPConfig `json:"smtp,omitempty"`
+}
+
+// email returns the email settings of cfg, creating them if necessary
+func (cfg *Config) email() *EmailConfig {
+	if cfg.Email == nil {
+		cfg.Email = &EmailConfig{}
+	}
+	return cfg.Email
+}
+
+func (e EmailConfig) deliver() string {
+	if e.Deliver == "" {
+		return deliverFile
+	}
+	return e.Deliver
+}
+
+func validateDeliver(value string) error {
+	switch value {
+	case deliverFile, deliverSendmail, deliverSMTP:
+		return nil
+	}
+	return fmt.Errorf("must be %q, %q or %q", deliverFile, deliverSendmail, deliverSMTP)
+}
+
+func validateAddressList(value string) error {
+	if _, err := mail.ParseAddressList(value); err != nil {
+		return fmt.Errorf("invalid address list: %w", err)
+	}
+	return nil
+}
+
+func validateAddress(value string) error {
+	if _, err := mail.ParseAddress(value); err != nil {
+		return fmt.Errorf("invalid address: %w", err)
+	}
+	return nil
+}
+
+// emailVars are the values substituted in subject and body templates
+type emailVars struct {
+	Period   string
+	Employee string
+	Manager  string
+	File     string
+}
+
+func (v emailVars) expand(template string) string {
+	return strings.NewReplacer(
+		"{period}", v.Period,
+		"{employee}", v.Employee,
+		"{manager}", v.Manager,
+		"{file}", v.File,
+	).Replace(template)
+}
+
+// emailVarsFor returns the template values for a signing
+func emailVarsFor(entry HistoryEntry) emailVars {
+	return emailVars{
+		Period:   entry.Period,
+		Employee: entry.Employee,
+		Manager:  entry.Manager,
+		File:     filepath.Base(entry.OutputPath),
+	}
+}
+
+// formatAddressList parses a comma separated list and formats it for a header
+func formatAddressList(value string) (string, error) {
+	addrs, err := mail.ParseAddressList(value)
+	if err != nil {
+		return "", err
+	}
+	formatted := make([]string, len(addrs))
+	for i, a := range addrs {
+		formatted[i] = a.String()
+	}
+	return strings.Join(formatted, ", "), nil
+}
+
+// buildEmail returns an RFC 5322 message with the signed PDF attached
+func buildEmail(cfg EmailConfig, vars emailVars, pdfName string, pdfData []byte, now time.Time) ([]byte, error) {
+	if cfg.To == "" {
+		return nil, fmt.Errorf("no email recipient configured (set email_to)")
+	}
+	to, err := formatAddressList(cfg.To)
+	if err != nil {
+		return nil, fmt.Errorf("invalid email_to: %w", err)
+	}
+
+	subject := cfg.Subject
+	if subject == "" {
+		subject = defaultEmailSubject
+	}
+	body := cfg.Body
+	if body == "" {
+		body = defaultEmailBody
+	}
+
+	var msg bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&msg, "%s: %s\r\n", name, value)
+	}
+
+	header("Date", now.Format(time.RFC1123Z))
+	if cfg.From != "" {
+		from, err := mail.ParseAddress(cfg.From)
+		if err != nil {
+			return nil, fmt.Errorf("invalid email_from: %w", err)
+		}
+		header("From", from.String())
+	}
+	header("To", to)
+	if cfg.Cc != "" {
+		cc, err := formatAddressList(cfg.Cc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid email_cc: %w", err)
+		}
+		header("Cc", cc)
+	}
+	header("Subject", mime.QEncoding.Encode("utf-8", vars.expand(subject)))
+	header("Message-ID", messageID(cfg.From, now))
+	header("MIME-Version", "1.0")
+
+	mw := multipart.NewWriter(&msg)
+	header("Content-Type", fmt.Sprintf("multipart/mixed; boundary=%q", mw.Boundary()))
+	msg.WriteString("\r\n")
+
+	text, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	qp := quotedprintable.NewWriter(text)
+	qp.Write([]byte(vars.expand(body)))
+	qp.Close()
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": pdfName})
+	attachment, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType("application/pdf", map[string]string{"name": pdfName})},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {disposition},
+	})
+	if err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(pdfData)
+	for len(encoded) > 76 {
+		attachment.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	attachment.Write([]byte(encoded + "\r\n"))
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return msg.Bytes(), nil
+}
+
+// messageID returns a unique Message-ID in the domain of from
+func messageID(from string, now time.Time) string {
+	domain := "hours-signer.localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if _, d, ok := strings.Cut(addr.Address, "@"); ok {
+			domain = d
+		}
+	}
+	random := make([]byte, 8)
+	rand.Read(random)
+	return fmt.Sprintf("<%d.%s@%s>", now.UnixNano(), hex.EncodeToString(random), domain)
+}
+
+// emailPath returns where the message for a signed PDF is written
+func emailPath(pdfPath string) string {
+	return strings.TrimSuffix(pdfPath, filepath.Ext(pdfPath)) + ".eml"
+}
+
+// EmailResult describes a generated email
+type EmailResult struct {
+	Path      string `json:"path,omitempty"` // written .eml file, if any
+	Delivered string `json:"delivered"`      // delivery method used
+	To        string `json:"to"`
+}
+
+// emailSignedPDF builds the message for a signed PDF and delivers it as
+// configured: written next to the PDF, handed to sendmail or sent over SMTP.
+// Deliveries other than writing a file are recorded in the history.
+func emailSignedPDF(cfg EmailConfig, entry HistoryEntry, mode os.FileMode) (*EmailResult, error) {
+	pdfData, err := os.ReadFile(entry.OutputPath)
+	if err != nil {
+		return nil, signError(KindEmail, entry.OutputPath, fmt.Errorf("failed to read signed PDF: %w", err))
+	}
+	msg, err := buildEmail(cfg, emailVarsFor(entry), filepath.Base(entry.OutputPath), pdfData, time.Now())
+	if err != nil {
+		return nil, signError(KindEmail, ConfigPath(), err)
+	}
+
+	result, err := deliverEmail(cfg, entry.OutputPath, msg, mode)
+	if cfg.deliver() != deliverFile {
+		status := DeliveryStatus{Timestamp: time.Now(), Method: cfg.deliver(), Target: cfg.To, OK: err == nil}
+		if err != nil {
+			status.Error = err.Error()
+		}
+		if histErr := AppendDelivery(sha256Hex(pdfData), status); histErr != nil && err == nil {
+			return result, fmt.Errorf("email sent but not recorded in history: %w", histErr)
+		}
+	}
+	return result, err
+}
+
+func deliverEmail(cfg EmailConfig, pdfPath string, msg []byte, mode os.FileMode) (*EmailResult, error) {
+	result := &EmailResult{Delivered: cfg.deliver(), To: cfg.To}
+	switch cfg.deliver() {
+	case deliverSMTP:
+		from, rcpts, err := cfg.envelope()
+		if err != nil {
+			return nil, signError(KindEmail, ConfigPath(), err)
+		}
+		if err := sendSMTP(*cfg.smtp(), from, rcpts, msg); err != nil {
+			return nil, signError(KindEmail, "", err)
+		}
+	case deliverSendmail:
+		command := cfg.Sendmail
+		if command == "" {
+			command = defaultSendmail
+		}
+		parts := strings.Fields(command)
+		cmd := exec.Command(parts[0], parts[1:]...)
+		cmd.Stdin = bytes.NewReader(msg)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			if msg := strings.TrimSpace(string(out)); msg != "" {
+				err = fmt.Errorf("%w: %s", err, msg)
+			}
+			return nil, signError(KindEmail, "", fmt.Errorf("sendmail failed: %w", err))
+		}
+	default:
+		result.Path = emailPath(pdfPath)
+		if err := writeFileAtomic(result.Path, msg, outputFileMode(result.Path, mode)); err != nil {
+			return nil, signError(KindEmail, result.Path, fmt.Errorf("failed to write email: %w", err))
+		}
+	}
+	return result, nil
+}
+
+// entryForSignedPDF returns the ledger entry of the most recent signing that
+// produced path, or a minimal entry from the config if there is none
+func entryForSignedPDF(path string, cfg Config) HistoryEntry {
+	abs := absPath(path)
+	entries, _ := LoadHistory()
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].OutputPath == abs {
+			return entries[i]
+		}
+	}
+	return HistoryEntry{
+		Period:     SignOptions{DocumentPeriod: pdfPeriod(path)}.period(),
+		OutputPath: abs,
+		Employee:   cfg.EmployeeName,
+		Manager:    cfg.ManagerName,
+	}
+}
+
+func runEmail(args []string) int {
+	cfg := LoadConfig()
+
+	fs := newFlagSet("email", "[flags] <signed.pdf>", "Create the email to the manager for a signed PDF, using the email settings from\nthe config. The message is written next to the PDF, handed to sendmail or sent\nover SMTP.")
+	to := fs.String("to", cfg.email().To, "Recipients, comma separated")
+	deliver := fs.String("deliver", cfg.email().deliver(), "Delivery: file, sendmail or smtp")
+	jsonOut := fs.Bool("json", false, "Print the result as JSON")
+	positional, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: exactly one signed PDF is required")
+		fs.Usage()
+		return exitUsage
+	}
+	if err := validateDeliver(*deliver); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -deliver %v\n", err)
+		return exitUsage
+	}
+
+	emailCfg := *cfg.email()
+	emailCfg.To = *to
+	emailCfg.Deliver = *deliver
+	opts := signOptionsFromConfig(cfg)
+	result, err := emailSignedPDF(emailCfg, entryForSignedPDF(positional[0], cfg), opts.OutputMode)
+	if err != nil {
+		return fail(*jsonOut, err)
+	}
+	if *jsonOut {
+		writeJSON(struct {
+			OK bool `json:"ok"`
+			*EmailResult
+		}{true, result})
+		return exitOK
+	}
+	printEmailResult(result)
+	return exitOK
+}
+
+func printEmailResult(r *EmailResult) {
+	if r.Path != "" {
+		fmt.Printf("✓ Created email to %s: %s\n", r.To, r.Path)
+	} else {
+		fmt.Printf("✓ Sent email to %s via %s\n", r.To, r.Delivered)
+	}
+}
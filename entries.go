@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// Hours Entries
+// ============================================================================
+
+// Entry is a number of hours worked on one day
+type Entry struct {
+	Date        string  `json:"date"` // yyyy-mm-dd
+	Client      string  `json:"client,omitempty"`
+	Project     string  `json:"project,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Hours       float64 `json:"hours"`
+}
+
+func (e Entry) day() time.Time {
+	t, _ := time.Parse("2006-01-02", e.Date)
+	return t
+}
+
+// entryJSON accepts hours as a number or as text such as "7:30"
+type entryJSON struct {
+	Date        string          `json:"date"`
+	Client      string          `json:"client"`
+	Project     string          `json:"project"`
+	Description string          `json:"description"`
+	Hours       json.RawMessage `json:"hours"`
+}
+
+// loadEntries reads hours entries from a CSV or JSON file, by extension.
+// The CSV needs a header row; JSON is a list of entries or an object with
+// "entries" or "rows", such as the output of extract -format json.
+func loadEntries(path string) ([]Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, signError(KindHoursData, path, fmt.Errorf("failed to read hours: %w", err))
+	}
+	var entries []Entry
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		entries, err = parseEntriesJSON(data)
+	} else {
+		entries, err = parseEntriesCSV(data)
+	}
+	if err != nil {
+		return nil, signError(KindHoursData, path, err)
+	}
+	sortEntries(entries)
+	return entries, nil
+}
+
+func parseEntriesJSON(data []byte) ([]Entry, error) {
+	var list []entryJSON
+	if err := json.Unmarshal(data, &list); err != nil {
+		var wrapped struct {
+			Entries []entryJSON `json:"entries"`
+			Rows    []entryJSON `json:"rows"`
+		}
+		if err := json.Unmarshal(data, &wrapped); err != nil {
+			return nil, fmt.Errorf("invalid JSON: expected a list of entries: %w", err)
+		}
+		list = append(wrapped.Entries, wrapped.Rows...)
+	}
+
+	var entries []Entry
+	for i, raw := range list {
+		if len(raw.Hours) == 0 || string(raw.Hours) == "null" {
+			// Rows of extract output for days without hours
+			continue
+		}
+		hours, err := strconv.ParseFloat(string(raw.Hours), 64)
+		if err != nil {
+			text := strings.Trim(string(raw.Hours), `"`)
+			var ok bool
+			if hours, ok = parseHours(text); !ok {
+				return nil, fmt.Errorf("entry %d: invalid hours %q", i+1, text)
+			}
+		}
+		e, err := newEntry(raw.Date, raw.Project, raw.Description, hours)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+		e.Client = raw.Client
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// parseEntriesCSV reads a CSV with a header naming the date, project,
+// description and hours columns, in Dutch or English. A semicolon is
+// accepted as separator.
+func parseEntriesCSV(data []byte) ([]Entry, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		r.Comma = ';'
+	}
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	cols := csvColumns(header)
+	if _, ok := cols[roleDate]; !ok {
+		return nil, fmt.Errorf("no date column in the CSV header")
+	}
+	if _, ok := cols[roleHours]; !ok {
+		return nil, fmt.Errorf("no hours column in the CSV header")
+	}
+
+	var entries []Entry
+	for line := 2; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		get := func(role string) string {
+			if i, ok := cols[role]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		if get(roleHours) == "" {
+			continue
+		}
+		hours, ok := parseHours(get(roleHours))
+		if !ok {
+			return nil, fmt.Errorf("line %d: invalid hours %q", line, get(roleHours))
+		}
+		e, err := newEntry(get(roleDate), get(roleProject), get(roleDescription), hours)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// csvColumns maps the column roles to their index in header
+func csvColumns(header []string) map[string]int {
+	cols := map[string]int{}
+	for i, h := range header {
+		h = strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))
+		for _, rp := range rolePatterns {
+			if _, ok := cols[rp.role]; !ok && rp.pattern.MatchString(h) {
+				cols[rp.role] = i
+				break
+			}
+		}
+	}
+	if _, ok := cols[roleDate]; !ok {
+		for i, h := range header {
+			if dayHeaderPattern.MatchString(strings.TrimSpace(h)) {
+				cols[roleDate] = i
+				break
+			}
+		}
+	}
+	return cols
+}
+
+func newEntry(date, project, description string, hours float64) (Entry, error) {
+	iso, ok := parseTableDate(date)
+	if !ok {
+		return Entry{}, fmt.Errorf("invalid date %q", date)
+	}
+	return Entry{Date: iso, Project: project, Description: description, Hours: hours}, nil
+}
+
+// encodeEntries writes entries as CSV with the columns of extract, or as
+// JSON, both as read by loadEntries
+func encodeEntries(entries []Entry, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	if format == tableJSON {
+		if entries == nil {
+			entries = []Entry{}
+		}
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		err := enc.Encode(entries)
+		return buf.Bytes(), err
+	}
+
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"date", "project", "description", "hours"})
+	for _, e := range entries {
+		w.Write([]string{e.Date, e.Project, e.Description, strconv.FormatFloat(e.Hours, 'f', -1, 64)})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// sortEntries orders entries by date, keeping the order within a day
+func sortEntries(entries []Entry) {
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Date < entries[j].Date })
+}
+
+// entriesPeriod returns the month all entries fall in, as yyyy-mm
+func entriesPeriod(entries []Entry) (string, error) {
+	if len(entries) == 0 {
+		return "", fmt.Errorf("no entries")
+	}
+	first, last := entries[0].Date[:7], entries[len(entries)-1].Date[:7]
+	if first != last {
+		return "", fmt.Errorf("the entries span %s to %s; choose a month with -period", first, last)
+	}
+	return first, nil
+}
+
+// entriesIn returns the entries in period and how many were left out
+func entriesIn(entries []Entry, period string) ([]Entry, int) {
+	var in []Entry
+	for _, e := range entries {
+		if strings.HasPrefix(e.Date, period+"-") {
+			in = append(in, e)
+		}
+	}
+	return in, len(entries) - len(in)
+}
+
+func validatePeriod(value string) error {
+	if _, err := time.Parse("2006-01", value); err != nil {
+		return fmt.Errorf("invalid period %q (expected yyyy-mm)", value)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ============================================================================
+// Errors and Machine-Readable Output
+// ============================================================================
+
+// ErrorKind classifies the errors returned by signPDF
+type ErrorKind string
+
+const (
+	KindUsage          ErrorKind = "usage"
+	KindConfig         ErrorKind = "config"
+	KindSignatureImage ErrorKind = "signature_image"
+	KindInputPDF       ErrorKind = "input_pdf"
+	KindOutputWrite    ErrorKind = "output_write"
+	KindAlreadySigned  ErrorKind = "already_signed"
+	KindOutputExists   ErrorKind = "output_exists"
+	KindEmail          ErrorKind = "email"
+	KindUpload         ErrorKind = "upload"
+	KindHoursData      ErrorKind = "hours_data"
+	KindValidation     ErrorKind = "validation"
+	KindContent        ErrorKind = "content_mismatch"
+)
+
+// SignError is an error of a known kind concerning the file at Path
+type SignError struct {
+	Kind ErrorKind
+	Path string
+	Err  error
+}
+
+func (e *SignError) Error() string { return e.Err.Error() }
+func (e *SignError) Unwrap() error { return e.Err }
+
+// signError wraps err as a SignError of the given kind. A nil err stays nil
+// and errors that already have a kind keep it.
+func signError(kind ErrorKind, path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var se *SignError
+	if errors.As(err, &se) {
+		return err
+	}
+	return &SignError{Kind: kind, Path: path, Err: err}
+}
+
+// errorKind returns the kind of err, or "" if it has none
+func errorKind(err error) ErrorKind {
+	var se *SignError
+	if errors.As(err, &se) {
+		return se.Kind
+	}
+	return ""
+}
+
+// exitCodeFor returns the exit code for a command failing with err
+func exitCodeFor(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	switch errorKind(err) {
+	case KindUsage:
+		return exitUsage
+	case KindConfig:
+		return exitConfig
+	case KindSignatureImage:
+		return exitSignatureImage
+	case KindInputPDF:
+		return exitInputPDF
+	case KindOutputWrite:
+		return exitOutputWrite
+	case KindAlreadySigned:
+		return exitAlreadySigned
+	case KindOutputExists:
+		return exitOutputExists
+	case KindEmail:
+		return exitEmail
+	case KindUpload:
+		return exitUpload
+	case KindHoursData:
+		return exitHoursData
+	case KindValidation:
+		return exitValidation
+	case KindContent:
+		return exitContent
+	}
+	return exitError
+}
+
+// writeJSON prints v as indented JSON on stdout
+func writeJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
+
+// jsonError is printed instead of an error message when -json is set
+type jsonError struct {
+	OK       bool      `json:"ok"`
+	Error    string    `json:"error"`
+	Kind     ErrorKind `json:"error_kind,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	ExitCode int       `json:"exit_code"`
+}
+
+// fail reports err as JSON on stdout or as a message on stderr and returns
+// the matching exit code
+func fail(jsonOut bool, err error) int {
+	code := exitCodeFor(err)
+	if jsonOut {
+		out := jsonError{Error: err.Error(), Kind: errorKind(err), ExitCode: code}
+		var se *SignError
+		if errors.As(err, &se) {
+			out.Path = se.Path
+		}
+		writeJSON(out)
+	} else {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	return code
+}
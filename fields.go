@@ -0,0 +1,354 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ============================================================================
+// Extra Fields and Languages
+// ============================================================================
+
+const defaultLanguage = "nl"
+
+// blockLabels are the fixed labels of the signature block per language
+var blockLabels = map[string]map[string]string{
+	"nl": {"employee": "Werknemer", "manager": "Manager", "date": "Datum", "signature": "Handtekening", "total": "Totaal uren"},
+	"en": {"employee": "Employee", "manager": "Manager", "date": "Date", "signature": "Signature", "total": "Total hours"},
+}
+
+func validateLanguage(value string) error {
+	if _, ok := blockLabels[value]; !ok {
+		return fmt.Errorf("must be \"nl\" or \"en\"")
+	}
+	return nil
+}
+
+// language returns the language of the signature block
+func (cfg Config) language() string {
+	if cfg.Language == "" {
+		return defaultLanguage
+	}
+	return cfg.Language
+}
+
+// localized is a text in one or more languages. In the config it is either a
+// plain string, used for every language, or an object keyed by language.
+type localized map[string]string
+
+func (l localized) in(lang string) string {
+	for _, key := range []string{lang, "", defaultLanguage} {
+		if s, ok := l[key]; ok {
+			return s
+		}
+	}
+	// Fall back to any translation, the same one every time
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		return l[keys[0]]
+	}
+	return ""
+}
+
+func (l *localized) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*l = localized{"": s}
+		return nil
+	}
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("expected a text or an object of texts per language")
+	}
+	*l = m
+	return nil
+}
+
+func (l localized) MarshalJSON() ([]byte, error) {
+	if s, ok := l[""]; ok && len(l) == 1 {
+		return json.Marshal(s)
+	}
+	return json.Marshal(map[string]string(l))
+}
+
+// FieldDef is an extra line in the signature block, such as a project code or
+// PO number. Its value is given with -field key=value or in the TUI.
+type FieldDef struct {
+	Key      string    `json:"key"`
+	Label    localized `json:"label"`
+	Default  localized `json:"default,omitempty"`
+	Required bool      `json:"required,omitempty"`
+}
+
+// Position places an extra field, in the same coordinates as the rest of the
+// signature block: points from the lower left corner, before the layout offset
+type Position struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// defaultFieldPosition stacks extra fields above the employee's part of the
+// signature block
+func defaultFieldPosition(i int) Position {
+	return Position{X: 40, Y: 225 + 15*float64(i)}
+}
+
+// fieldValue is an extra field as stamped
+type fieldValue struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// missingFieldValue stands in for required values in previews
+const missingFieldValue = "________"
+
+// resolveFields returns the extra fields to stamp, in the order they are
+// defined. Fields without a value or default are left out. Unless lenient,
+// values for undefined fields and missing required values are errors.
+func resolveFields(defs []FieldDef, values map[string]string, lang string, lenient bool) ([]fieldValue, error) {
+	if !lenient {
+		for key := range values {
+			if findField(defs, key) < 0 {
+				return nil, fmt.Errorf("unknown field %q (define it with hours-signer fields add)", key)
+			}
+		}
+	}
+	var fields []fieldValue
+	for _, def := range defs {
+		value, ok := values[def.Key]
+		if !ok {
+			value = def.Default.in(lang)
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			if !def.Required {
+				continue
+			}
+			if !lenient {
+				return nil, fmt.Errorf("field %q requires a value (-field %s=...)", def.Key, def.Key)
+			}
+			value = missingFieldValue
+		}
+		fields = append(fields, fieldValue{Key: def.Key, Label: def.Label.in(lang), Value: value})
+	}
+	return fields, nil
+}
+
+// fieldMap returns the values of fields by key, for the history
+func fieldMap(fields []fieldValue) map[string]string {
+	if len(fields) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(fields))
+	for _, f := range fields {
+		m[f.Key] = f.Value
+	}
+	return m
+}
+
+func findField(defs []FieldDef, key string) int {
+	for i, def := range defs {
+		if def.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// validateFields checks that extra fields have unique keys and a label
+func validateFields(defs []FieldDef) error {
+	seen := map[string]bool{}
+	for _, def := range defs {
+		if def.Key == "" || strings.ContainsAny(def.Key, "= \t\r\n") {
+			return fmt.Errorf("invalid field key %q", def.Key)
+		}
+		if def.Key == totalFieldKey {
+			return fmt.Errorf("field key %q is reserved for the total hours", def.Key)
+		}
+		if seen[def.Key] {
+			return fmt.Errorf("duplicate field %q", def.Key)
+		}
+		seen[def.Key] = true
+		if len(def.Label) == 0 {
+			return fmt.Errorf("field %q has no label", def.Key)
+		}
+	}
+	return nil
+}
+
+func fieldsUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: hours-signer fields <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Extra fields are additional lines in the signature block, such as a project")
+	fmt.Fprintln(w, "code or PO number. Give their values with sign -field key=value or in the TUI.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	fmt.Fprintln(w, "  list               List the extra fields")
+	fmt.Fprintln(w, "  add [flags]        Define an extra field")
+	fmt.Fprintln(w, "  remove <key>       Remove an extra field")
+}
+
+func runFields(args []string) int {
+	if len(args) == 0 {
+		fieldsUsage(os.Stderr)
+		return exitUsage
+	}
+	switch args[0] {
+	case "list":
+		return fieldsList(args[1:])
+	case "add":
+		return fieldsAdd(args[1:])
+	case "remove":
+		return fieldsRemove(args[1:])
+	case "help", "-h", "-help", "--help":
+		fieldsUsage(os.Stdout)
+		return exitOK
+	}
+	fmt.Fprintf(os.Stderr, "Error: unknown fields command %q\n\n", args[0])
+	fieldsUsage(os.Stderr)
+	return exitUsage
+}
+
+func fieldsList(args []string) int {
+	fs := newFlagSet("fields list", "", "List the extra fields of the signature block.")
+	jsonOut := fs.Bool("json", false, "Print the fields as JSON")
+	positional, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+	if len(positional) > 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	cfg := LoadConfig()
+	if *jsonOut {
+		defs := cfg.Fields
+		if defs == nil {
+			defs = []FieldDef{}
+		}
+		writeJSON(struct {
+			OK     bool       `json:"ok"`
+			Fields []FieldDef `json:"fields"`
+		}{true, defs})
+		return exitOK
+	}
+	if len(cfg.Fields) == 0 {
+		fmt.Println("No extra fields defined")
+		return exitOK
+	}
+	for _, def := range cfg.Fields {
+		fmt.Printf("%-16s %s", def.Key, def.Label.in(cfg.language()))
+		if d := def.Default.in(cfg.language()); d != "" {
+			fmt.Printf(" (default %q)", d)
+		}
+		if def.Required {
+			fmt.Print(" (required)")
+		}
+		fmt.Println()
+	}
+	return exitOK
+}
+
+func fieldsAdd(args []string) int {
+	fs := newFlagSet("fields add", "-key <key> -label <label> [flags]", "Define an extra field of the signature block. Fields are stamped in the order\nthey are defined.")
+	key := fs.String("key", "", "Key used with -field key=value (required)")
+	label := fs.String("label", "", "Label stamped before the value (required)")
+	labels := keyValueFlag{}
+	fs.Var(labels, "label-in", "Label in a specific language, as `lang=label` (repeatable)")
+	def := fs.String("default", "", "Value used when none is given")
+	defaults := keyValueFlag{}
+	fs.Var(defaults, "default-in", "Default in a specific language, as `lang=value` (repeatable)")
+	required := fs.Bool("required", false, "Refuse to sign without a value")
+	jsonOut := fs.Bool("json", false, "Print the added field as JSON")
+	positional, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+	if len(positional) > 0 || *key == "" || (*label == "" && len(labels) == 0) {
+		fmt.Fprintln(os.Stderr, "Error: a key and a label are required")
+		fs.Usage()
+		return exitUsage
+	}
+
+	field := FieldDef{Key: *key, Label: localized{}, Required: *required}
+	if *label != "" {
+		field.Label[""] = *label
+	}
+	for lang, l := range labels {
+		field.Label[lang] = l
+	}
+	if *def != "" || len(defaults) > 0 {
+		field.Default = localized{}
+		if *def != "" {
+			field.Default[""] = *def
+		}
+		for lang, d := range defaults {
+			field.Default[lang] = d
+		}
+	}
+
+	cfg := LoadConfig()
+	if findField(cfg.Fields, field.Key) >= 0 {
+		return fail(*jsonOut, signError(KindConfig, ConfigPath(), fmt.Errorf("field %q already exists", field.Key)))
+	}
+	cfg.Fields = append(cfg.Fields, field)
+	if err := validateFields(cfg.Fields); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	if err := SaveConfig(cfg); err != nil {
+		return fail(*jsonOut, signError(KindConfig, ConfigPath(), err))
+	}
+	if *jsonOut {
+		writeJSON(struct {
+			OK    bool     `json:"ok"`
+			Field FieldDef `json:"field"`
+		}{true, field})
+		return exitOK
+	}
+	fmt.Printf("✓ Added field %s\n", field.Key)
+	return exitOK
+}
+
+func fieldsRemove(args []string) int {
+	fs := newFlagSet("fields remove", "<key>", "Remove an extra field.")
+	jsonOut := fs.Bool("json", false, "Print the result as JSON")
+	positional, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	key := positional[0]
+
+	cfg := LoadConfig()
+	i := findField(cfg.Fields, key)
+	if i < 0 {
+		return fail(*jsonOut, signError(KindConfig, ConfigPath(), fmt.Errorf("no field %q", key)))
+	}
+	cfg.Fields = append(cfg.Fields[:i], cfg.Fields[i+1:]...)
+	if err := SaveConfig(cfg); err != nil {
+		return fail(*jsonOut, signError(KindConfig, ConfigPath(), err))
+	}
+	if *jsonOut {
+		writeJSON(struct {
+			OK      bool   `json:"ok"`
+			Removed string `json:"removed"`
+		}{true, key})
+		return exitOK
+	}
+	fmt.Printf("✓ Removed field %s\n", key)
+	return exitOK
+}
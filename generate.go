@@ -0,0 +1,591 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// ============================================================================
+// Timesheet Generation
+// ============================================================================
+
+// sheetLabels are the texts of a generated timesheet per language
+var sheetLabels = map[string]map[string]string{
+	"nl": {
+		"title": "Urenstaat", "employee": "Werknemer", "manager": "Manager", "company": "Bedrijf", "client": "Klant", "period": "Periode", "range": "%s t/m %s",
+		"date": "Datum", "project": "Project", "description": "Omschrijving", "hours": "Uren",
+		"week": "Totaal week %d", "total": "Totaal", "page": "Pagina %d van %d", "signatures": "Handtekeningen",
+	},
+	"en": {
+		"title": "Timesheet", "employee": "Employee", "manager": "Manager", "company": "Company", "client": "Client", "period": "Period", "range": "%s to %s",
+		"date": "Date", "project": "Project", "description": "Description", "hours": "Hours",
+		"week": "Total week %d", "total": "Total", "page": "Page %d of %d", "signatures": "Signatures",
+	},
+}
+
+var (
+	monthNames = map[string][]string{
+		"nl": {"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+		"en": {"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+	}
+	weekdayNames = map[string][]string{
+		"nl": {"zo", "ma", "di", "wo", "do", "vr", "za"},
+		"en": {"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+	}
+)
+
+// Page geometry of generated timesheets, in points
+const (
+	sheetWidth     = 595 // A4
+	sheetHeight    = 842
+	sheetMargin    = 50
+	sheetRowHeight = 10
+	sheetFontSize  = 8
+	sheetBottom    = 60 // lowest row on pages before the last
+	// The signature block is stamped below this on the last page; the
+	// signature image can reach above its labels
+	sheetSignatureTop = 300
+	sheetLogoWidth    = 140
+	sheetLogoHeight   = 50
+)
+
+// Column positions: the hours are right aligned at sheetHoursRight
+const (
+	sheetDateX        = 50
+	sheetProjectX     = 140
+	sheetDescX        = 250
+	sheetHoursRight   = 545
+	sheetProjectW     = sheetDescX - sheetProjectX - 8
+	sheetDescriptionW = 470 - sheetDescX
+)
+
+// GenerateOptions describes the timesheet generate builds
+type GenerateOptions struct {
+	Period   string // yyyy-mm
+	Employee string
+	Manager  string
+	Company  string
+	Client   string
+	LogoPath string
+	Language string
+}
+
+// GenerateResult describes a generated timesheet
+type GenerateResult struct {
+	Output     string  `json:"output"`
+	Period     string  `json:"period"`
+	Entries    int     `json:"entries"`
+	Skipped    int     `json:"skipped,omitempty"` // entries outside the period
+	TotalHours float64 `json:"total_hours"`
+	Pages      int     `json:"pages"`
+
+	// ExpectedHours are the hours to work in the period by contract_hours
+	ExpectedHours *float64 `json:"expected_hours,omitempty"`
+}
+
+// sheetRow is a line of the generated table
+type sheetRow struct {
+	Kind    string // "day", "week" or "total"
+	Day     time.Time
+	Entry   *Entry
+	Hours   float64
+	Week    int
+	Weekend bool
+}
+
+// sheetRows lays out one row per entry, a row for each day without entries,
+// a subtotal after every week and the total of the month
+func sheetRows(entries []Entry, month time.Time) []sheetRow {
+	byDay := map[string][]Entry{}
+	for _, e := range entries {
+		byDay[e.Date] = append(byDay[e.Date], e)
+	}
+
+	var rows []sheetRow
+	weekHours, total := 0.0, 0.0
+	end := month.AddDate(0, 1, 0)
+	for day := month; day.Before(end); day = day.AddDate(0, 0, 1) {
+		weekend := day.Weekday() == time.Saturday || day.Weekday() == time.Sunday
+		dayEntries := byDay[day.Format("2006-01-02")]
+		if len(dayEntries) == 0 {
+			rows = append(rows, sheetRow{Kind: "day", Day: day, Weekend: weekend})
+		}
+		for i := range dayEntries {
+			e := dayEntries[i]
+			rows = append(rows, sheetRow{Kind: "day", Day: day, Entry: &e, Hours: e.Hours, Weekend: weekend})
+			weekHours += e.Hours
+			total += e.Hours
+		}
+		if day.Weekday() == time.Sunday || day.AddDate(0, 0, 1).Equal(end) {
+			_, week := day.ISOWeek()
+			rows = append(rows, sheetRow{Kind: "week", Week: week, Hours: weekHours})
+			weekHours = 0
+		}
+	}
+	return append(rows, sheetRow{Kind: "total", Hours: total})
+}
+
+// generateTimesheet builds a monthly timesheet PDF from entries. Entries
+// outside opts.Period are ignored.
+func generateTimesheet(entries []Entry, opts GenerateOptions) ([]byte, int, error) {
+	month, err := time.Parse("2006-01", opts.Period)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid period %q (expected yyyy-mm)", opts.Period)
+	}
+	lang := opts.Language
+	if _, ok := sheetLabels[lang]; !ok {
+		lang = defaultLanguage
+	}
+	labels := sheetLabels[lang]
+	periodName := fmt.Sprintf("%s %d", monthNames[lang][month.Month()-1], month.Year())
+
+	var pages []*sheetPage
+	newPage := func() *sheetPage {
+		p := &sheetPage{}
+		pages = append(pages, p)
+		return p
+	}
+
+	// Heading and employee details on the first page. Without a logo the
+	// company name heads the page.
+	page := newPage()
+	page.text(sheetMargin, 800, true, 18, labels["title"]+" "+periodName)
+	if opts.Company != "" && opts.LogoPath == "" {
+		page.textRight(sheetHoursRight, 800, true, 12, opts.Company)
+	}
+	details := [][2]string{
+		{labels["employee"], opts.Employee},
+		{labels["manager"], opts.Manager},
+	}
+	if opts.Company != "" {
+		details = append(details, [2]string{labels["company"], opts.Company})
+	}
+	if opts.Client != "" {
+		details = append(details, [2]string{labels["client"], opts.Client})
+	}
+	details = append(details, [2]string{labels["period"], fmt.Sprintf(labels["range"], month.Format("02-01-2006"), month.AddDate(0, 1, -1).Format("02-01-2006"))})
+	y := 775.0
+	for _, d := range details {
+		page.text(sheetMargin, y, true, 9, d[0]+":")
+		page.text(sheetMargin+70, y, false, 9, d[1])
+		y -= 12
+	}
+	y -= 10
+
+	header := func(p *sheetPage, y float64) {
+		p.text(sheetDateX, y, true, sheetFontSize, labels["date"])
+		p.text(sheetProjectX, y, true, sheetFontSize, labels["project"])
+		p.text(sheetDescX, y, true, sheetFontSize, labels["description"])
+		p.textRight(sheetHoursRight, y, true, sheetFontSize, labels["hours"])
+		p.line(sheetMargin, y-3.5, sheetHoursRight, y-3.5, 0.8)
+	}
+	header(page, y)
+	y -= sheetRowHeight + 2
+
+	for _, row := range sheetRows(entries, month) {
+		if y < sheetBottom {
+			page = newPage()
+			y = 790
+			header(page, y)
+			y -= sheetRowHeight + 2
+		}
+		switch row.Kind {
+		case "day":
+			if row.Weekend {
+				page.fill(sheetMargin, y-3, sheetHoursRight-sheetMargin, sheetRowHeight, 0.93)
+			}
+			// Days without hours are grayed out
+			if row.Entry == nil {
+				page.gray = 0.45
+			}
+			page.text(sheetDateX, y, false, sheetFontSize, weekdayNames[lang][row.Day.Weekday()]+" "+row.Day.Format("02-01-2006"))
+			if row.Entry != nil {
+				page.text(sheetProjectX, y, false, sheetFontSize, fitText(row.Entry.Project, sheetProjectW))
+				page.text(sheetDescX, y, false, sheetFontSize, fitText(row.Entry.Description, sheetDescriptionW))
+				page.textRight(sheetHoursRight, y, false, sheetFontSize, formatHours(row.Hours, lang))
+			}
+			page.gray = 0
+		case "week":
+			page.line(sheetDescX, y+sheetRowHeight-3, sheetHoursRight, y+sheetRowHeight-3, 0.3)
+			page.text(sheetDateX, y, true, sheetFontSize, fmt.Sprintf(labels["week"], row.Week))
+			page.textRight(sheetHoursRight, y, true, sheetFontSize, formatHours(row.Hours, lang))
+			y -= 4
+		case "total":
+			page.line(sheetMargin, y+sheetRowHeight-3, sheetHoursRight, y+sheetRowHeight-3, 1)
+			page.text(sheetDateX, y, true, 10, labels["total"])
+			page.textRight(sheetHoursRight, y, true, 10, formatHours(row.Hours, lang))
+		}
+		y -= sheetRowHeight
+	}
+
+	// Leave room for the signature block on the last page
+	if y+sheetRowHeight < sheetSignatureTop {
+		page = newPage()
+		page.text(sheetMargin, 790, true, 12, labels["signatures"])
+	}
+	for i, p := range pages {
+		p.gray = 0.45
+		text := fmt.Sprintf(labels["page"], i+1, len(pages))
+		p.text(sheetWidth/2-sheetTextWidth(text, false, 8)/2, 30, false, 8, text)
+	}
+
+	info := map[string]string{
+		"Title":    labels["title"] + " " + periodName,
+		"Author":   opts.Employee,
+		"Subject":  opts.Company,
+		"Creator":  "hours-signer",
+		"Producer": "hours-signer " + version,
+	}
+	data := writeSheetPDF(pages, info)
+	if opts.LogoPath != "" {
+		if data, err = stampLogo(data, opts.LogoPath); err != nil {
+			return nil, 0, err
+		}
+	}
+	return data, len(pages), nil
+}
+
+// stampLogo places the logo in the top right corner of the first page,
+// scaled to fit sheetLogoWidth × sheetLogoHeight
+func stampLogo(data []byte, logoPath string) ([]byte, error) {
+	logo, err := os.ReadFile(expandHome(logoPath))
+	if err != nil {
+		return nil, signError(KindConfig, logoPath, fmt.Errorf("failed to read logo: %w", err))
+	}
+	w, h, err := imageSize(logo)
+	if err != nil {
+		return nil, signError(KindConfig, logoPath, fmt.Errorf("failed to decode logo: %w", err))
+	}
+	scale := math.Min(sheetLogoWidth/float64(w), sheetLogoHeight/float64(h))
+	e := stampElement{
+		Name:  "logo",
+		Image: true,
+		X:     sheetHoursRight - scale*float64(w),
+		Y:     805 - scale*float64(h),
+		Scale: scale,
+	}
+	return stampElements(data, 1, []stampElement{e}, expandHome(logoPath), pdfmodel.NewDefaultConfiguration())
+}
+
+// fitText shortens s with an ellipsis to fit width points
+func fitText(s string, width float64) string {
+	if sheetTextWidth(s, false, sheetFontSize) <= width {
+		return s
+	}
+	r := []rune(s)
+	for len(r) > 0 && sheetTextWidth(string(r)+"…", false, sheetFontSize) > width {
+		r = r[:len(r)-1]
+	}
+	return strings.TrimSpace(string(r)) + "…"
+}
+
+// ----------------------------------------------------------------------------
+// PDF Writer
+// ----------------------------------------------------------------------------
+
+// sheetPage collects the content stream of a generated page. Text is set
+// in Helvetica with WinAnsiEncoding.
+type sheetPage struct {
+	content bytes.Buffer
+	gray    float64 // text color, 0 for black
+}
+
+func (p *sheetPage) text(x, y float64, bold bool, size float64, s string) {
+	if s == "" {
+		return
+	}
+	f := "F1"
+	if bold {
+		f = "F2"
+	}
+	fmt.Fprintf(&p.content, "BT %g g /%s %g Tf %.2f %.2f Td (%s) Tj ET\n", p.gray, f, size, x, y, pdfText(s))
+}
+
+// textRight draws s ending at x
+func (p *sheetPage) textRight(x, y float64, bold bool, size float64, s string) {
+	p.text(x-sheetTextWidth(s, bold, size), y, bold, size, s)
+}
+
+// sheetTextWidth returns the width of s in points, for fractional font sizes
+func sheetTextWidth(s string, bold bool, size float64) float64 {
+	f := stampFont
+	if bold {
+		f = stampFontBold
+	}
+	// Core font widths are looked up per byte, so measure the encoded text
+	encoded := make([]byte, 0, len(s))
+	for _, r := range s {
+		c, ok := winAnsiByte(r)
+		if !ok {
+			c = '?'
+		}
+		encoded = append(encoded, c)
+	}
+	return font.TextWidth(string(encoded), f, 1000) * size / 1000
+}
+
+func (p *sheetPage) line(x0, y0, x1, y1, width float64) {
+	fmt.Fprintf(&p.content, "%g w 0 G %.2f %.2f m %.2f %.2f l S\n", width, x0, y0, x1, y1)
+}
+
+// fill paints a rectangle in a shade of gray
+func (p *sheetPage) fill(x, y, w, h, gray float64) {
+	fmt.Fprintf(&p.content, "%g g %.2f %.2f %.2f %.2f re f\n", gray, x, y, w, h)
+}
+
+// pdfText encodes s as the body of a PDF literal string in WinAnsiEncoding
+func pdfText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		c, ok := winAnsiByte(r)
+		if !ok {
+			c = '?'
+		}
+		switch c {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			if c < 0x20 || c > 0x7e {
+				fmt.Fprintf(&b, "\\%03o", c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	return b.String()
+}
+
+// winAnsiExtra are the characters of WinAnsiEncoding outside Latin-1
+var winAnsiExtra = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+func winAnsiByte(r rune) (byte, bool) {
+	if r < 0x80 || (r >= 0xa0 && r <= 0xff) {
+		return byte(r), true
+	}
+	c, ok := winAnsiExtra[r]
+	return c, ok
+}
+
+// writeSheetPDF writes pages as an A4 PDF with the given document info
+func writeSheetPDF(pages []*sheetPage, info map[string]string) []byte {
+	var objects []string
+	add := func(obj string) int {
+		objects = append(objects, obj)
+		return len(objects)
+	}
+	catalog := add("")
+	pagesObj := add("")
+	regular := add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	bold := add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	var kids []string
+	for _, p := range pages {
+		content := add(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.content.Len(), p.content.String()))
+		page := add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> >> /Contents %d 0 R >>",
+			pagesObj, sheetWidth, sheetHeight, regular, bold, content))
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+	objects[catalog-1] = fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObj)
+	objects[pagesObj-1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages))
+
+	var infoDict strings.Builder
+	infoDict.WriteString("<<")
+	for _, key := range []string{"Title", "Author", "Subject", "Creator", "Producer"} {
+		if v := info[key]; v != "" {
+			fmt.Fprintf(&infoDict, " /%s (%s)", key, pdfText(v))
+		}
+	}
+	fmt.Fprintf(&infoDict, " /CreationDate (D:%s) >>", time.Now().Format("20060102150405"))
+	infoObj := add(infoDict.String())
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, catalog, infoObj, xref)
+	return buf.Bytes()
+}
+
+// generatedOutputName returns the name of the generated timesheet of a period
+func generatedOutputName(period string) string {
+	return fmt.Sprintf("Urenstaat-%s.pdf", period)
+}
+
+// generate writes the timesheet of the entries in opts.Period to output. An
+// empty period is taken from the entries; an empty output is named after the
+// period.
+func generate(entries []Entry, output string, opts GenerateOptions, force bool, mode os.FileMode) (*GenerateResult, error) {
+	if opts.Period == "" {
+		period, err := entriesPeriod(entries)
+		if err != nil {
+			return nil, signError(KindHoursData, "", err)
+		}
+		opts.Period = period
+	}
+	inPeriod, skipped := entriesIn(entries, opts.Period)
+	if len(inPeriod) == 0 {
+		return nil, signError(KindHoursData, "", fmt.Errorf("no entries in %s", opts.Period))
+	}
+	if output == "" {
+		output = generatedOutputName(opts.Period)
+	}
+
+	data, pages, err := generateTimesheet(inPeriod, opts)
+	if err != nil {
+		return nil, signError(KindHoursData, "", err)
+	}
+
+	unlock, err := lockOutput(output)
+	if err != nil {
+		return nil, signError(KindOutputWrite, output, fmt.Errorf("failed to lock output file: %w", err))
+	}
+	defer unlock()
+	if !force && fileExists(output) {
+		return nil, signError(KindOutputExists, output, fmt.Errorf("%w: %s (use -force to overwrite)", ErrOutputExists, output))
+	}
+	if err := writeFileAtomic(output, data, outputFileMode(output, mode)); err != nil {
+		return nil, signError(KindOutputWrite, output, fmt.Errorf("failed to write output file: %w", err))
+	}
+
+	total := 0.0
+	for _, e := range inPeriod {
+		total += e.Hours
+	}
+	return &GenerateResult{
+		Output:     output,
+		Period:     opts.Period,
+		Entries:    len(inPeriod),
+		Skipped:    skipped,
+		TotalHours: math.Round(total*100) / 100,
+		Pages:      pages,
+	}, nil
+}
+
+func runGenerate(args []string) int {
+	cfg := LoadConfig()
+
+	fs := newFlagSet("generate", "[flags] <hours.csv|hours.json|export.csv|calendar.ics>", "Build a monthly timesheet PDF from hours entries (date, project, description,\nhours), a Toggl Track, Clockify or Harvest export or a calendar, with a row per\nday, weekly and monthly totals and the employee details from the config. With\n-sign the timesheet is signed in the same run.")
+	period := fs.String("period", "", "Month of the timesheet as yyyy-mm (default: the month of the entries)")
+	outputFile := fs.String("output", "", "Output PDF file (default: Urenstaat-<period>.pdf)")
+	employeeName := fs.String("employee", cfg.EmployeeName, "Employee name")
+	managerName := fs.String("manager", cfg.ManagerName, "Manager name")
+	company := fs.String("company", cfg.CompanyName, "Company name in the header")
+	logo := fs.String("logo", cfg.LogoPath, "Logo image (PNG/JPG) in the top right corner")
+	lang := fs.String("lang", cfg.language(), "Language of the timesheet: nl or en")
+	source := addHoursSourceFlags(fs, cfg)
+	signIt := fs.Bool("sign", false, "Sign the timesheet after generating it")
+	signedFile := fs.String("signed-output", "", "Signed PDF file with -sign (default: Urenstaat-<period>-signed.pdf)")
+	signaturePath := fs.String("signature", cfg.SignaturePath, "Path to signature image (PNG/JPG) for -sign")
+	force := fs.Bool("force", false, "Overwrite existing output files")
+	outputMode := fs.String("mode", "", "Permissions for new output files, e.g. 0600 (default: from config or 0644)")
+	jsonOut := fs.Bool("json", false, "Print the result as JSON")
+	positional, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: exactly one hours file is required")
+		fs.Usage()
+		return exitUsage
+	}
+	if *period != "" {
+		if err := validatePeriod(*period); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -period %v\n", err)
+			return exitUsage
+		}
+	}
+	if err := validateLanguage(*lang); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -lang %v\n", err)
+		return exitUsage
+	}
+	importOpts, code, ok := source.options(cfg, *period, *jsonOut)
+	if !ok {
+		return code
+	}
+
+	opts := signOptionsFromConfig(cfg)
+	opts.EmployeeName = *employeeName
+	opts.ManagerName = *managerName
+	opts.SignaturePath = *signaturePath
+	opts.Language = *lang
+	opts.Force = *force
+	if code := applyOutputMode(&opts, *outputMode); code != exitOK {
+		return code
+	}
+
+	entries, _, err := loadHours(positional[0], *source.from, importOpts)
+	if err != nil {
+		return fail(*jsonOut, err)
+	}
+	result, err := generate(entries, *outputFile, GenerateOptions{
+		Period:   *period,
+		Employee: *employeeName,
+		Manager:  *managerName,
+		Company:  *company,
+		Client:   *source.client,
+		LogoPath: *logo,
+		Language: *lang,
+	}, *force, opts.OutputMode)
+	if err != nil {
+		return fail(*jsonOut, err)
+	}
+
+	if weekly, ok := cfg.contractHours(); ok {
+		first, last, _ := periodDays(result.Period)
+		expected := opts.Calendar.expectedHours(first, last, weekly)
+		result.ExpectedHours = &expected
+	}
+
+	output := *signedFile
+	if output == "" {
+		output = signedOutputName(result.Period)
+	}
+	opts.Period = result.Period
+
+	if *jsonOut {
+		out := struct {
+			OK bool `json:"ok"`
+			*GenerateResult
+			Signed *SignResult `json:"signed,omitempty"`
+		}{OK: true, GenerateResult: result}
+		if *signIt {
+			if out.Signed, err = signPDF(result.Output, output, opts); err != nil {
+				return fail(true, err)
+			}
+		}
+		writeJSON(out)
+		return exitOK
+	}
+	fmt.Printf("✓ Created timesheet: %s\n", result.Output)
+	fmt.Printf("  Period: %s\n", result.Period)
+	fmt.Printf("  Entries: %d, %s hours, %d pages\n", result.Entries, formatHours(result.TotalHours, *lang), result.Pages)
+	if result.ExpectedHours != nil {
+		fmt.Printf("  Expected: %s hours by contract\n", formatHours(*result.ExpectedHours, *lang))
+	}
+	if result.Skipped > 0 {
+		fmt.Printf("⚠ %d entries outside %s left out\n", result.Skipped, result.Period)
+	}
+	if !*signIt {
+		return exitOK
+	}
+	return sign(result.Output, output, opts, nil, nil, false, false)
+}
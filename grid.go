@@ -0,0 +1,508 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ============================================================================
+// Hours Grid
+// ============================================================================
+
+// hoursDraft is a month of hours entered in the grid, saved as it is edited
+type hoursDraft struct {
+	Period   string                        `json:"period"` // yyyy-mm
+	Projects []string                      `json:"projects"`
+	Hours    map[string]map[string]float64 `json:"hours"` // by date, then project
+	Updated  time.Time                     `json:"updated"`
+}
+
+// DraftPath returns where the draft of period is saved
+func DraftPath(period string) string {
+	configPath := ConfigPath()
+	if configPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPath), "drafts", period+".json")
+}
+
+// loadDraft reads the draft of period. Without one, a new draft gets the
+// projects of the latest earlier draft.
+func loadDraft(period string) (*hoursDraft, error) {
+	draft := &hoursDraft{Period: period, Hours: map[string]map[string]float64{}}
+	data, err := os.ReadFile(DraftPath(period))
+	if errors.Is(err, os.ErrNotExist) {
+		draft.Projects = latestDraftProjects(period)
+		return draft, nil
+	}
+	if err != nil {
+		return draft, fmt.Errorf("failed to read draft: %w", err)
+	}
+	if err := json.Unmarshal(data, draft); err != nil {
+		return draft, fmt.Errorf("failed to parse draft: %w", err)
+	}
+	if draft.Hours == nil {
+		draft.Hours = map[string]map[string]float64{}
+	}
+	return draft, nil
+}
+
+// latestDraftProjects returns the projects of the latest draft before period
+func latestDraftProjects(period string) []string {
+	paths, _ := filepath.Glob(filepath.Join(filepath.Dir(DraftPath(period)), "*.json"))
+	sort.Strings(paths)
+	for i := len(paths) - 1; i >= 0; i-- {
+		if strings.TrimSuffix(filepath.Base(paths[i]), ".json") >= period {
+			continue
+		}
+		data, err := os.ReadFile(paths[i])
+		if err != nil {
+			continue
+		}
+		var d hoursDraft
+		if json.Unmarshal(data, &d) == nil && len(d.Projects) > 0 {
+			return d.Projects
+		}
+	}
+	return nil
+}
+
+func saveDraft(d *hoursDraft) error {
+	path := DraftPath(d.Period)
+	if path == "" {
+		return fmt.Errorf("could not determine draft path")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create drafts directory: %w", err)
+	}
+	d.Updated = time.Now()
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal draft: %w", err)
+	}
+	if err := writeFileAtomic(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write draft: %w", err)
+	}
+	return nil
+}
+
+func (d *hoursDraft) month() time.Time {
+	t, _ := time.Parse("2006-01", d.Period)
+	return t
+}
+
+func (d *hoursDraft) days() int {
+	return d.month().AddDate(0, 1, -1).Day()
+}
+
+func (d *hoursDraft) day(i int) time.Time {
+	return d.month().AddDate(0, 0, i)
+}
+
+func (d *hoursDraft) get(day int, project string) float64 {
+	return d.Hours[d.day(day).Format("2006-01-02")][project]
+}
+
+func (d *hoursDraft) set(day int, project string, hours float64) {
+	key := d.day(day).Format("2006-01-02")
+	if hours == 0 {
+		delete(d.Hours[key], project)
+		if len(d.Hours[key]) == 0 {
+			delete(d.Hours, key)
+		}
+		return
+	}
+	if d.Hours[key] == nil {
+		d.Hours[key] = map[string]float64{}
+	}
+	d.Hours[key][project] = hours
+}
+
+// sum returns the hours of days first to last, inclusive, of one project or,
+// if project is empty, of all projects
+func (d *hoursDraft) sum(first, last int, project string) float64 {
+	total := 0.0
+	for i := first; i <= last; i++ {
+		for p, h := range d.Hours[d.day(i).Format("2006-01-02")] {
+			if project == "" || p == project {
+				total += h
+			}
+		}
+	}
+	return total
+}
+
+// entries returns the hours of the draft as entries for generate
+func (d *hoursDraft) entries() []Entry {
+	var entries []Entry
+	for i := 0; i < d.days(); i++ {
+		for _, p := range d.Projects {
+			if h := d.get(i, p); h > 0 {
+				entries = append(entries, Entry{Date: d.day(i).Format("2006-01-02"), Project: p, Hours: h})
+			}
+		}
+	}
+	return entries
+}
+
+// weekEnd returns the last day of the week of day i, within the month
+func (d *hoursDraft) weekEnd(i int) int {
+	for i < d.days()-1 && d.day(i).Weekday() != time.Sunday {
+		i++
+	}
+	return i
+}
+
+// openGrid shows the grid with the draft of period
+func (m model) openGrid(period string) (model, tea.Cmd) {
+	draft, err := loadDraft(period)
+	m.draft, m.gridErr = draft, err
+	m.gridDay, m.gridCol = 0, 0
+	m.gridEditing, m.gridAdding = false, false
+	// Start at today in the current month
+	if today := time.Now(); today.Format("2006-01") == period {
+		m.gridDay = today.Day() - 1
+	}
+	m.screen = screenGrid
+	return m, nil
+}
+
+// saveGrid saves the draft after a change
+func (m model) saveGrid() model {
+	m.gridErr = saveDraft(m.draft)
+	return m
+}
+
+func (m model) updateGrid(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	if m.gridAdding {
+		return m.updateGridProject(key)
+	}
+	if m.gridEditing {
+		return m.updateGridCell(key), nil
+	}
+
+	d := m.draft
+	switch k := key.String(); k {
+	case "up", "k":
+		m.gridDay = max(m.gridDay-1, 0)
+	case "down", "j":
+		m.gridDay = min(m.gridDay+1, d.days()-1)
+	case "left", "h":
+		m.gridCol = max(m.gridCol-1, 0)
+	case "right", "l", "tab":
+		m.gridCol = min(m.gridCol+1, max(len(d.Projects)-1, 0))
+	case "pgup":
+		m.gridDay = max(m.gridDay-7, 0)
+	case "pgdown":
+		m.gridDay = min(m.gridDay+7, d.days()-1)
+	case "[", "]":
+		month := d.month().AddDate(0, -1, 0)
+		if k == "]" {
+			month = d.month().AddDate(0, 1, 0)
+		}
+		return m.openGrid(month.Format("2006-01"))
+	case "a":
+		m.gridInput = textinput.New()
+		m.gridInput.Placeholder = "Project name"
+		m.gridInput.CharLimit = 40
+		m.gridInput.Width = 30
+		m.gridInput.Focus()
+		m.gridAdding = true
+		return m, textinput.Blink
+	case "D":
+		if len(d.Projects) == 0 {
+			return m, nil
+		}
+		project := d.Projects[m.gridCol]
+		if d.sum(0, d.days()-1, project) > 0 {
+			m.gridErr = fmt.Errorf("clear the hours of %s before removing it", project)
+			return m, nil
+		}
+		d.Projects = append(d.Projects[:m.gridCol], d.Projects[m.gridCol+1:]...)
+		m.gridCol = min(m.gridCol, max(len(d.Projects)-1, 0))
+		return m.saveGrid(), nil
+	case "x", "delete", "backspace":
+		if len(d.Projects) > 0 {
+			d.set(m.gridDay, d.Projects[m.gridCol], 0)
+			return m.saveGrid(), nil
+		}
+	case "enter":
+		if len(d.Projects) > 0 {
+			m.gridEditing, m.gridEdit = true, ""
+			if h := d.get(m.gridDay, d.Projects[m.gridCol]); h > 0 {
+				m.gridEdit = formatHours(h, m.config.language())
+			}
+		}
+	case "g", "G":
+		return m.generateFromGrid(k == "G")
+	case "esc", "q":
+		m.screen = screenMain
+		return m, nil
+	default:
+		// Typing a number starts editing the cell
+		if len(d.Projects) > 0 && len(k) == 1 && strings.Contains("0123456789", k) {
+			m.gridEditing, m.gridEdit = true, k
+		}
+	}
+	m.gridErr = nil
+	return m, nil
+}
+
+// updateGridCell edits the hours of the cell under the cursor
+func (m model) updateGridCell(key tea.KeyMsg) model {
+	d := m.draft
+	switch k := key.String(); k {
+	case "enter", "tab", "down", "up":
+		hours := 0.0
+		if value := strings.TrimSpace(m.gridEdit); value != "" {
+			h, ok := parseHours(value)
+			if !ok || h > 24 {
+				m.gridErr = fmt.Errorf("invalid number of hours %q (e.g. 8, 7,5 or 7:30)", value)
+				return m
+			}
+			hours = h
+		}
+		d.set(m.gridDay, d.Projects[m.gridCol], hours)
+		m.gridEditing, m.gridErr = false, nil
+		switch k {
+		case "enter", "down":
+			m.gridDay = min(m.gridDay+1, d.days()-1)
+		case "up":
+			m.gridDay = max(m.gridDay-1, 0)
+		case "tab":
+			m.gridCol = (m.gridCol + 1) % len(d.Projects)
+		}
+		return m.saveGrid()
+	case "esc":
+		m.gridEditing, m.gridErr = false, nil
+	case "backspace":
+		if m.gridEdit != "" {
+			m.gridEdit = m.gridEdit[:len(m.gridEdit)-1]
+		}
+	default:
+		if len(k) == 1 && strings.Contains("0123456789,.:", k) && len(m.gridEdit) < 5 {
+			m.gridEdit += k
+		}
+	}
+	return m
+}
+
+// updateGridProject asks the name of a new project column
+func (m model) updateGridProject(key tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch key.String() {
+	case "enter":
+		name := strings.TrimSpace(m.gridInput.Value())
+		if name == "" {
+			return m, nil
+		}
+		for _, p := range m.draft.Projects {
+			if strings.EqualFold(p, name) {
+				m.gridErr = fmt.Errorf("project %s already exists", p)
+				return m, nil
+			}
+		}
+		m.draft.Projects = append(m.draft.Projects, name)
+		m.gridCol = len(m.draft.Projects) - 1
+		m.gridAdding, m.gridErr = false, nil
+		return m.saveGrid(), nil
+	case "esc":
+		m.gridAdding, m.gridErr = false, nil
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.gridInput, cmd = m.gridInput.Update(key)
+	return m, cmd
+}
+
+// generateFromGrid builds the timesheet of the draft and goes on to signing
+// it, as a selected file. An existing timesheet is only replaced with force.
+func (m model) generateFromGrid(force bool) (tea.Model, tea.Cmd) {
+	d := m.draft
+	entries := d.entries()
+	if len(entries) == 0 {
+		m.gridErr = fmt.Errorf("no hours entered for %s", d.Period)
+		return m, nil
+	}
+	cfg := m.config
+	result, err := generate(entries, generatedOutputName(d.Period), GenerateOptions{
+		Period:   d.Period,
+		Employee: cfg.EmployeeName,
+		Manager:  cfg.ManagerName,
+		Company:  cfg.CompanyName,
+		LogoPath: cfg.LogoPath,
+		Language: cfg.language(),
+	}, force, signOptionsFromConfig(cfg).OutputMode)
+	if errors.Is(err, ErrOutputExists) {
+		m.gridErr = fmt.Errorf("%s already exists; press G to replace it", generatedOutputName(d.Period))
+		return m, nil
+	}
+	if err != nil {
+		m.gridErr = err
+		return m, nil
+	}
+
+	m.selectedFile = absPath(result.Output)
+	m.outputPath = signedOutputName(d.Period)
+	m.signPeriod, m.signFrom = d.Period, screenGrid
+	m.inputState = stateUnsigned
+	m.outputExists = fileExists(m.outputPath)
+	m.totalHours = &TotalHours{Hours: result.TotalHours, Source: totalSourceGrid, Confident: true}
+	return m.askFields()
+}
+
+var (
+	gridOffStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	gridWeekStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("111"))
+	gridHolidayStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+)
+
+// Widths of the grid columns, in characters
+const (
+	gridDayWidth  = 9
+	gridCellWidth = 9
+)
+
+func (m model) viewGrid() string {
+	d := m.draft
+	lang := m.config.language()
+	month := d.month()
+	s := titleStyle.Render(fmt.Sprintf("🗓  Hours %s %d", monthNames[lang][month.Month()-1], month.Year())) + "\n"
+
+	cell := func(text string) string {
+		if len(text) > gridCellWidth-1 {
+			text = text[:gridCellWidth-2] + "…"
+		}
+		return fmt.Sprintf("%*s", gridCellWidth, text)
+	}
+
+	header := fmt.Sprintf("%-*s", gridDayWidth, "")
+	for _, p := range d.Projects {
+		header += cell(p)
+	}
+	header += cell("Total")
+	s += subtitleStyle.Render(header) + "\n"
+
+	weekly, hasContract := m.config.contractHours()
+	cal := m.config.workCalendar()
+	var lines []string
+	var cursorLine int
+	weekStart := 0
+	for i := 0; i < d.days(); i++ {
+		day := d.day(i)
+		name, holiday := cal.holiday(day)
+		line := fmt.Sprintf("%-*s", gridDayWidth, weekdayNames[lang][day.Weekday()]+" "+day.Format("02"))
+		for c, p := range d.Projects {
+			text := ""
+			if h := d.get(i, p); h > 0 {
+				text = formatHours(h, lang)
+			}
+			if i == m.gridDay && c == m.gridCol {
+				cursorLine = len(lines)
+				if m.gridEditing {
+					text = m.gridEdit + "_"
+				}
+				line += selectedItemStyle.Render(cell("[" + text + "]"))
+				continue
+			}
+			if text == "" {
+				text = "·"
+			}
+			line += cell(text)
+		}
+		total := ""
+		if h := d.sum(i, i, ""); h > 0 {
+			total = formatHours(h, lang)
+		}
+		line += cell(total)
+		switch {
+		case holiday:
+			line = gridHolidayStyle.Render(line + "  " + name)
+		case cal.isDayOff(day):
+			line = gridHolidayStyle.Render(line + "  " + dayOffLabels[lang])
+		case isWeekend(day):
+			line = gridOffStyle.Render(line)
+		}
+		if len(d.Projects) == 0 && i == m.gridDay {
+			cursorLine = len(lines)
+		}
+		lines = append(lines, line)
+
+		if end := d.weekEnd(i); end == i {
+			_, week := day.ISOWeek()
+			w := fmt.Sprintf("%-*s", gridDayWidth, fmt.Sprintf("wk %d", week))
+			for _, p := range d.Projects {
+				w += cell(formatHours(d.sum(weekStart, i, p), lang))
+			}
+			sum := d.sum(weekStart, i, "")
+			w += cell(formatHours(sum, lang))
+			if hasContract {
+				w += "  " + expectedText(sum, cal.expectedHours(d.day(weekStart), day, weekly), lang)
+			}
+			lines = append(lines, gridWeekStyle.Render(w))
+			weekStart = i + 1
+		}
+	}
+
+	// Show the part of the month around the cursor that fits the window
+	visible := len(lines)
+	if m.height > 0 {
+		visible = min(max(m.height-12, 7), len(lines))
+	}
+	top := min(max(cursorLine-visible/2, 0), len(lines)-visible)
+	s += strings.Join(lines[top:top+visible], "\n") + "\n\n"
+
+	total := d.sum(0, d.days()-1, "")
+	s += fmt.Sprintf("Month: %s hours", formatHours(total, lang))
+	if hasContract {
+		s += "  " + expectedText(total, cal.expectedHours(d.day(0), d.day(d.days()-1), weekly), lang)
+	} else {
+		s += subtitleStyle.Render("  (set contract_hours to check against your contract)")
+	}
+	s += "\n"
+
+	switch {
+	case m.gridAdding:
+		s += "\nNew project:\n" + m.gridInput.View() + "\n"
+		s += helpStyle.Render("Enter to add • Esc to cancel")
+		return s
+	case m.gridErr != nil:
+		s += errorStyle.Render(fmt.Sprintf("✗ %v", m.gridErr)) + "\n"
+	case len(d.Projects) == 0:
+		s += "\nNo projects yet: press a to add one.\n"
+	}
+	if m.gridEditing {
+		s += helpStyle.Render("Type the hours (8, 7,5 or 7:30) • Enter to save • Esc to cancel")
+		return s
+	}
+	s += helpStyle.Render("↑/↓/←/→ move • 0-9 or Enter edit • x clear • a add project • D remove project\n[/] month • g generate and sign • Esc back")
+	return s
+}
+
+// expectedText compares hours with the expected hours
+func expectedText(hours, expected float64, lang string) string {
+	diff := math.Round((hours-expected)*100) / 100
+	text := fmt.Sprintf("expected %s", formatHours(expected, lang))
+	switch {
+	case diff == 0:
+		return successStyle.Render("✓ " + text)
+	case diff > 0:
+		return errorStyle.Render(fmt.Sprintf("%s (+%s)", text, formatHours(diff, lang)))
+	default:
+		return errorStyle.Render(fmt.Sprintf("%s (−%s)", text, formatHours(-diff, lang)))
+	}
+}
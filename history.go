@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// Signing History
+// ============================================================================
+
+// HistoryEntry is one line of the signing ledger
+type HistoryEntry struct {
+	Timestamp    time.Time `json:"timestamp"`
+	Period       string    `json:"period"`
+	InputPath    string    `json:"input_path"`
+	InputSHA256  string    `json:"input_sha256"`
+	OutputPath   string    `json:"output_path"`
+	OutputSHA256 string    `json:"output_sha256"`
+	Employee     string    `json:"employee"`
+	Manager      string    `json:"manager"`
+
+	// Date is the date in the signature block, as dd-mm-yyyy
+	Date string `json:"date,omitempty"`
+
+	// Action is "approved" or "rejected" for a review, empty for a signing
+	Action string `json:"action,omitempty"`
+	Reason string `json:"reason,omitempty"`
+
+	// Fields are the values of the extra fields in the signature block
+	Fields map[string]string `json:"fields,omitempty"`
+
+	// TotalHours is the total recorded when signing, and where it came from
+	TotalHours  *float64 `json:"total_hours,omitempty"`
+	TotalSource string   `json:"total_source,omitempty"`
+
+	// Deliveries are attached by LoadHistory from the delivery lines that
+	// follow the signing in the ledger
+	Deliveries []DeliveryStatus `json:"deliveries,omitempty"`
+}
+
+// DeliveryStatus records one attempt to deliver a signed PDF
+type DeliveryStatus struct {
+	Timestamp time.Time `json:"timestamp"`
+	Method    string    `json:"method"` // e.g. "smtp"
+	Target    string    `json:"target"` // recipients or URL
+	OK        bool      `json:"ok"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// deliveryLine is how a delivery is written to the ledger. It refers to the
+// signing by the hash of the signed PDF.
+type deliveryLine struct {
+	Event        string         `json:"event"`
+	OutputSHA256 string         `json:"output_sha256"`
+	Delivery     DeliveryStatus `json:"delivery"`
+}
+
+const deliveryEvent = "delivery"
+
+// HistoryPath returns the location of the JSON-lines signing ledger
+func HistoryPath() string {
+	configPath := ConfigPath()
+	if configPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPath), "history.jsonl")
+}
+
+// currentPeriod returns the period (yyyy-mm) a timesheet signed now belongs to
+func currentPeriod() string {
+	return time.Now().Format("2006-01")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// absPath returns an absolute version of path, falling back to path itself
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
+
+// AppendHistory adds an entry to the ledger, creating it if necessary
+func AppendHistory(entry HistoryEntry) error {
+	entry.Deliveries = nil
+	return appendHistoryLine(entry)
+}
+
+// AppendDelivery records a delivery of the signed PDF with the given hash
+func AppendDelivery(outputSHA256 string, status DeliveryStatus) error {
+	return appendHistoryLine(deliveryLine{Event: deliveryEvent, OutputSHA256: outputSHA256, Delivery: status})
+}
+
+func appendHistoryLine(v any) error {
+	historyPath := HistoryPath()
+	if historyPath == "" {
+		return fmt.Errorf("could not determine history path")
+	}
+	if err := os.MkdirAll(filepath.Dir(historyPath), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	line, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history entry: %w", err)
+	}
+	f, err := os.OpenFile(historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// LoadHistory reads all ledger entries in the order they were written, with
+// their deliveries. Lines that cannot be parsed are skipped.
+func LoadHistory() ([]HistoryEntry, error) {
+	historyPath := HistoryPath()
+	if historyPath == "" {
+		return nil, fmt.Errorf("could not determine history path")
+	}
+	f, err := os.Open(historyPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var delivery deliveryLine
+		if err := json.Unmarshal(scanner.Bytes(), &delivery); err == nil && delivery.Event == deliveryEvent {
+			// Attach to the latest signing of that file
+			for i := len(entries) - 1; i >= 0; i-- {
+				if entries[i].OutputSHA256 == delivery.OutputSHA256 {
+					entries[i].Deliveries = append(entries[i].Deliveries, delivery.Delivery)
+					break
+				}
+			}
+			continue
+		}
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return entries, fmt.Errorf("failed to read history: %w", err)
+	}
+	return entries, nil
+}
+
+// historyFilter selects ledger entries; zero values match everything
+type historyFilter struct {
+	since    time.Time
+	until    time.Time
+	period   string
+	employee string
+	manager  string
+	search   string
+}
+
+func (f historyFilter) match(e HistoryEntry) bool {
+	if !f.since.IsZero() && e.Timestamp.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && !e.Timestamp.Before(f.until) {
+		return false
+	}
+	if f.period != "" && e.Period != f.period {
+		return false
+	}
+	if f.employee != "" && !containsFold(e.Employee, f.employee) {
+		return false
+	}
+	if f.manager != "" && !containsFold(e.Manager, f.manager) {
+		return false
+	}
+	if f.search != "" && !containsFold(e.InputPath, f.search) && !containsFold(e.OutputPath, f.search) &&
+		!strings.HasPrefix(e.InputSHA256, strings.ToLower(f.search)) && !strings.HasPrefix(e.OutputSHA256, strings.ToLower(f.search)) {
+		return false
+	}
+	return true
+}
+
+func (d DeliveryStatus) String() string {
+	s := fmt.Sprintf("%s %s to %s", d.Timestamp.Local().Format("02-01-2006 15:04"), d.Method, d.Target)
+	if d.OK {
+		return "✓ " + s
+	}
+	return fmt.Sprintf("✗ %s: %s", s, d.Error)
+}
+
+// review describes the decision of a review entry
+func (e HistoryEntry) review() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("%s: %s", e.Action, e.Reason)
+	}
+	return e.Action
+}
+
+// actionSummary marks reviews in one-line listings
+func (e HistoryEntry) actionSummary() string {
+	if e.Action == "" {
+		return ""
+	}
+	return fmt.Sprintf("  [%s]", e.Action)
+}
+
+// deliverySummary describes the latest delivery of e for one-line listings
+func (e HistoryEntry) deliverySummary() string {
+	if len(e.Deliveries) == 0 {
+		return ""
+	}
+	d := e.Deliveries[len(e.Deliveries)-1]
+	if d.OK {
+		return fmt.Sprintf("  (sent via %s)", d.Method)
+	}
+	return fmt.Sprintf("  (%s failed)", d.Method)
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// parseDate accepts yyyy-mm-dd as well as the Dutch dd-mm-yyyy format
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "02-01-2006"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q (use yyyy-mm-dd or dd-mm-yyyy)", s)
+}
+
+func runHistory(args []string) int {
+	fs := newFlagSet("history", "[flags]", "List past signings recorded in the history ledger, most recent first.")
+	since := fs.String("since", "", "Only show signings on or after this date (yyyy-mm-dd)")
+	until := fs.String("until", "", "Only show signings before the end of this date (yyyy-mm-dd)")
+	period := fs.String("period", "", "Only show signings for this period (yyyy-mm)")
+	employee := fs.String("employee", "", "Only show signings whose employee name contains this text")
+	manager := fs.String("manager", "", "Only show signings whose manager name contains this text")
+	search := fs.String("search", "", "Only show signings whose paths contain this text or whose hashes start with it")
+	limit := fs.Int("limit", 0, "Show at most this many entries (0 for all)")
+	verbose := fs.Bool("v", false, "Show full paths and hashes")
+	jsonOut := fs.Bool("json", false, "Print the entries as JSON")
+	positional, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+	if len(positional) > 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	filter := historyFilter{period: *period, employee: *employee, manager: *manager, search: *search}
+	var err error
+	if *since != "" {
+		if filter.since, err = parseDate(*since); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitUsage
+		}
+	}
+	if *until != "" {
+		if filter.until, err = parseDate(*until); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitUsage
+		}
+		filter.until = filter.until.AddDate(0, 0, 1)
+	}
+
+	entries, err := LoadHistory()
+	if err != nil {
+		return fail(*jsonOut, err)
+	}
+
+	matched := []HistoryEntry{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if *limit > 0 && len(matched) >= *limit {
+			break
+		}
+		if filter.match(entries[i]) {
+			matched = append(matched, entries[i])
+		}
+	}
+
+	if *jsonOut {
+		writeJSON(struct {
+			OK      bool           `json:"ok"`
+			Entries []HistoryEntry `json:"entries"`
+		}{true, matched})
+		return exitOK
+	}
+	for _, e := range matched {
+		if *verbose {
+			fmt.Printf("%s  period %s\n", e.Timestamp.Local().Format("02-01-2006 15:04"), e.Period)
+			fmt.Printf("  Employee: %s\n", e.Employee)
+			fmt.Printf("  Manager:  %s\n", e.Manager)
+			if e.TotalHours != nil {
+				fmt.Printf("  Total:    %s hours (%s)\n", formatHours(*e.TotalHours, "en"), e.TotalSource)
+			}
+			if e.Action != "" {
+				fmt.Printf("  Review:   %s\n", e.review())
+			}
+			fmt.Printf("  Input:    %s\n", e.InputPath)
+			fmt.Printf("            sha256 %s\n", e.InputSHA256)
+			fmt.Printf("  Output:   %s\n", e.OutputPath)
+			fmt.Printf("            sha256 %s\n", e.OutputSHA256)
+			for _, d := range e.Deliveries {
+				fmt.Printf("  Sent:     %s\n", d)
+			}
+			fmt.Println()
+			continue
+		}
+		fmt.Printf("%s  %s  %-20s %s%s%s\n", e.Timestamp.Local().Format("02-01-2006 15:04"), e.Period, e.Employee, e.OutputPath, e.actionSummary(), e.deliverySummary())
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No signings recorded")
+	} else if len(matched) == 0 {
+		fmt.Println("No matching signings")
+	}
+	return exitOK
+}
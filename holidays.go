@@ -0,0 +1,436 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// Working Days
+// ============================================================================
+
+// holiday is a public holiday
+type holiday struct {
+	Date time.Time
+	Name string
+}
+
+// easter returns Easter Sunday of year, by the anonymous Gregorian algorithm
+func easter(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return civilDate(year, time.Month(month), day)
+}
+
+// civilDate returns midnight UTC of a day; days are compared by their date only
+func civilDate(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// dutchHolidays returns the Dutch public holidays of year. Liberation Day is
+// a day off in lustrum years only, as in most collective agreements.
+func dutchHolidays(year int) []holiday {
+	e := easter(year)
+	kingsDay := civilDate(year, time.April, 27)
+	if kingsDay.Weekday() == time.Sunday {
+		kingsDay = kingsDay.AddDate(0, 0, -1)
+	}
+	days := []holiday{
+		{civilDate(year, time.January, 1), "Nieuwjaarsdag"},
+		{e, "Eerste Paasdag"},
+		{e.AddDate(0, 0, 1), "Tweede Paasdag"},
+		{kingsDay, "Koningsdag"},
+		{e.AddDate(0, 0, 39), "Hemelvaartsdag"},
+		{e.AddDate(0, 0, 49), "Eerste Pinksterdag"},
+		{e.AddDate(0, 0, 50), "Tweede Pinksterdag"},
+		{civilDate(year, time.December, 25), "Eerste Kerstdag"},
+		{civilDate(year, time.December, 26), "Tweede Kerstdag"},
+	}
+	if year%5 == 0 {
+		days = append(days, holiday{civilDate(year, time.May, 5), "Bevrijdingsdag"})
+	}
+	return days
+}
+
+// belgianHolidays returns the Belgian public holidays of year
+func belgianHolidays(year int) []holiday {
+	e := easter(year)
+	return []holiday{
+		{civilDate(year, time.January, 1), "Nieuwjaar"},
+		{e, "Pasen"},
+		{e.AddDate(0, 0, 1), "Paasmaandag"},
+		{civilDate(year, time.May, 1), "Feest van de Arbeid"},
+		{e.AddDate(0, 0, 39), "O.L.H. Hemelvaart"},
+		{e.AddDate(0, 0, 49), "Pinksteren"},
+		{e.AddDate(0, 0, 50), "Pinkstermaandag"},
+		{civilDate(year, time.July, 21), "Nationale feestdag"},
+		{civilDate(year, time.August, 15), "O.L.V. Hemelvaart"},
+		{civilDate(year, time.November, 1), "Allerheiligen"},
+		{civilDate(year, time.November, 11), "Wapenstilstand"},
+		{civilDate(year, time.December, 25), "Kerstmis"},
+	}
+}
+
+// germanHolidays returns the public holidays of year observed in all German
+// states; regional ones can be added as days off
+func germanHolidays(year int) []holiday {
+	e := easter(year)
+	return []holiday{
+		{civilDate(year, time.January, 1), "Neujahr"},
+		{e.AddDate(0, 0, -2), "Karfreitag"},
+		{e.AddDate(0, 0, 1), "Ostermontag"},
+		{civilDate(year, time.May, 1), "Tag der Arbeit"},
+		{e.AddDate(0, 0, 39), "Christi Himmelfahrt"},
+		{e.AddDate(0, 0, 50), "Pfingstmontag"},
+		{civilDate(year, time.October, 3), "Tag der Deutschen Einheit"},
+		{civilDate(year, time.December, 25), "1. Weihnachtstag"},
+		{civilDate(year, time.December, 26), "2. Weihnachtstag"},
+	}
+}
+
+// britishHolidays returns the bank holidays of England and Wales in year.
+// Holidays on a weekend move to the next free weekday; one-off bank holidays
+// can be added as days off.
+func britishHolidays(year int) []holiday {
+	e := easter(year)
+	firstMonday := func(month time.Month) time.Time {
+		d := civilDate(year, month, 1)
+		return d.AddDate(0, 0, (8-int(d.Weekday()))%7)
+	}
+	lastMonday := func(month time.Month) time.Time {
+		d := civilDate(year, month+1, 0)
+		return d.AddDate(0, 0, -(int(d.Weekday())+6)%7)
+	}
+	days := []holiday{
+		{e.AddDate(0, 0, -2), "Good Friday"},
+		{e.AddDate(0, 0, 1), "Easter Monday"},
+		{firstMonday(time.May), "Early May bank holiday"},
+		{lastMonday(time.May), "Spring bank holiday"},
+		{lastMonday(time.August), "Summer bank holiday"},
+	}
+	taken := map[time.Time]bool{}
+	for _, h := range days {
+		taken[h.Date] = true
+	}
+	fixed := []holiday{
+		{civilDate(year, time.January, 1), "New Year's Day"},
+		{civilDate(year, time.December, 25), "Christmas Day"},
+		{civilDate(year, time.December, 26), "Boxing Day"},
+	}
+	for _, h := range fixed {
+		if !isWeekend(h.Date) {
+			days = append(days, h)
+			taken[h.Date] = true
+		}
+	}
+	for _, h := range fixed {
+		if !isWeekend(h.Date) {
+			continue
+		}
+		d := h.Date
+		for isWeekend(d) || taken[d] {
+			d = d.AddDate(0, 0, 1)
+		}
+		days = append(days, holiday{d, h.Name + " (substitute day)"})
+		taken[d] = true
+	}
+	return days
+}
+
+// holidayCalendars are the public holidays by country code
+var holidayCalendars = map[string]func(year int) []holiday{
+	"nl": dutchHolidays,
+	"be": belgianHolidays,
+	"de": germanHolidays,
+	"gb": britishHolidays,
+}
+
+// noHolidays is the holiday country for no public holidays at all
+const noHolidays = "none"
+
+func validateHolidayCountry(value string) error {
+	if _, ok := holidayCalendars[value]; !ok && value != noHolidays {
+		return fmt.Errorf("invalid holiday country %q (expected nl, be, de, gb or none)", value)
+	}
+	return nil
+}
+
+// holidayCountry returns the country of the public holidays, nl by default
+func (cfg Config) holidayCountry() string {
+	if cfg.HolidayCountry == "" {
+		return "nl"
+	}
+	return cfg.HolidayCountry
+}
+
+// maxDaysOffRange is the longest range of days off, against typos in years
+const maxDaysOffRange = 366
+
+// parseDaysOff parses days off as dates and ranges of dates, e.g.
+// "2026-05-15, 2026-07-20..2026-08-07", into a set of yyyy-mm-dd dates
+func parseDaysOff(value string) (map[string]bool, error) {
+	days := map[string]bool{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to, isRange := strings.Cut(part, "..")
+		first, err := time.Parse("2006-01-02", strings.TrimSpace(from))
+		if err != nil {
+			return nil, fmt.Errorf("invalid day off %q (expected yyyy-mm-dd or yyyy-mm-dd..yyyy-mm-dd)", part)
+		}
+		last := first
+		if isRange {
+			if last, err = time.Parse("2006-01-02", strings.TrimSpace(to)); err != nil {
+				return nil, fmt.Errorf("invalid day off %q (expected yyyy-mm-dd or yyyy-mm-dd..yyyy-mm-dd)", part)
+			}
+			if last.Before(first) || last.Sub(first) >= maxDaysOffRange*24*time.Hour {
+				return nil, fmt.Errorf("invalid range of days off %q (at most %d days, in order)", part, maxDaysOffRange)
+			}
+		}
+		for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+			days[d.Format("2006-01-02")] = true
+		}
+	}
+	return days, nil
+}
+
+func validateDaysOff(value string) error {
+	_, err := parseDaysOff(value)
+	return err
+}
+
+// dayOffLabels name extra days off per language
+var dayOffLabels = map[string]string{"nl": "Vrije dag", "en": "Day off"}
+
+// workCalendar tells working days from days off: weekends, the public
+// holidays of a country and extra days off
+type workCalendar struct {
+	Country string          // key of holidayCalendars, or noHolidays
+	DaysOff map[string]bool // yyyy-mm-dd
+}
+
+// workCalendar returns the calendar configured in cfg. Invalid days off were
+// refused by config set and are ignored.
+func (cfg Config) workCalendar() workCalendar {
+	daysOff, _ := parseDaysOff(cfg.DaysOff)
+	return workCalendar{Country: cfg.holidayCountry(), DaysOff: daysOff}
+}
+
+// holidays returns the public holidays of year, in order
+func (c workCalendar) holidays(year int) []holiday {
+	holidays, ok := holidayCalendars[c.Country]
+	if !ok {
+		return nil
+	}
+	days := holidays(year)
+	sort.Slice(days, func(i, j int) bool { return days[i].Date.Before(days[j].Date) })
+	return days
+}
+
+// holiday returns the name of the public holiday on day, if any
+func (c workCalendar) holiday(day time.Time) (string, bool) {
+	d := civilDate(day.Year(), day.Month(), day.Day())
+	for _, h := range c.holidays(day.Year()) {
+		if h.Date.Equal(d) {
+			return h.Name, true
+		}
+	}
+	return "", false
+}
+
+func (c workCalendar) isDayOff(day time.Time) bool {
+	return c.DaysOff[day.Format("2006-01-02")]
+}
+
+func isWeekend(day time.Time) bool {
+	return day.Weekday() == time.Saturday || day.Weekday() == time.Sunday
+}
+
+// isWorkingDay reports whether day is a weekday that is neither a holiday
+// nor a day off
+func (c workCalendar) isWorkingDay(day time.Time) bool {
+	_, holiday := c.holiday(day)
+	return !isWeekend(day) && !holiday && !c.isDayOff(day)
+}
+
+// workingDays returns the number of working days from the first to the last
+// day, inclusive
+func (c workCalendar) workingDays(first, last time.Time) int {
+	days := 0
+	for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+		if c.isWorkingDay(d) {
+			days++
+		}
+	}
+	return days
+}
+
+// periodDays returns the first and last day of a yyyy-mm period
+func periodDays(period string) (time.Time, time.Time, error) {
+	month, err := time.Parse("2006-01", period)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid period %q (expected yyyy-mm)", period)
+	}
+	return month, month.AddDate(0, 1, -1), nil
+}
+
+// lastWorkingDay returns the last working day of a period, or its last day
+// if it has no working days
+func (c workCalendar) lastWorkingDay(period string) (time.Time, error) {
+	first, last, err := periodDays(period)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for d := last; !d.Before(first); d = d.AddDate(0, 0, -1) {
+		if c.isWorkingDay(d) {
+			return d, nil
+		}
+	}
+	return last, nil
+}
+
+// signingDate returns the default date to sign the timesheet of a period
+// with: its last working day, or today if that is earlier
+func (c workCalendar) signingDate(period string, now time.Time) time.Time {
+	today := civilDate(now.Year(), now.Month(), now.Day())
+	day, err := c.lastWorkingDay(period)
+	if err != nil || day.After(today) {
+		return today
+	}
+	return day
+}
+
+// parseSigningDate parses a signing date given as dd-mm-yyyy or yyyy-mm-dd
+// and returns it as dd-mm-yyyy, the way it is stamped
+func parseSigningDate(value string) (string, error) {
+	for _, layout := range []string{"02-01-2006", "2006-01-02"} {
+		if d, err := time.Parse(layout, value); err == nil {
+			return d.Format("02-01-2006"), nil
+		}
+	}
+	return "", fmt.Errorf("invalid date %q (expected dd-mm-yyyy)", value)
+}
+
+// contractHours returns the contract hours per week, if set
+func (cfg Config) contractHours() (float64, bool) {
+	hours, ok := parseHours(cfg.ContractHours)
+	return hours, ok && hours > 0
+}
+
+func validateContractHours(value string) error {
+	hours, ok := parseHours(value)
+	if !ok || hours <= 0 || hours > 7*24 {
+		return fmt.Errorf("invalid contract hours %q (hours per week, e.g. 40 or 32)", value)
+	}
+	return nil
+}
+
+// expectedHours returns the hours to work from the first to the last day,
+// inclusive: the working days times a fifth of the weekly hours
+func (c workCalendar) expectedHours(first, last time.Time, weekly float64) float64 {
+	return float64(c.workingDays(first, last)) * weekly / 5
+}
+
+// calendarDay is a day off within a period
+type calendarDay struct {
+	Date string `json:"date"` // yyyy-mm-dd
+	Name string `json:"name"`
+	Kind string `json:"kind"` // "holiday" or "day_off"
+}
+
+// daysOff returns the holidays and the extra days off on weekdays from the
+// first to the last day, in order
+func (c workCalendar) daysOff(first, last time.Time, lang string) []calendarDay {
+	var days []calendarDay
+	for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+		if name, ok := c.holiday(d); ok {
+			days = append(days, calendarDay{d.Format("2006-01-02"), name, "holiday"})
+		} else if c.isDayOff(d) && !isWeekend(d) {
+			days = append(days, calendarDay{d.Format("2006-01-02"), dayOffLabels[lang], "day_off"})
+		}
+	}
+	return days
+}
+
+func runCalendar(args []string) int {
+	cfg := LoadConfig()
+
+	fs := newFlagSet("calendar", "[flags]", "Show the holidays, days off and working days of a month, and the hours\nexpected from contract_hours.")
+	period := fs.String("period", currentPeriod(), "Month as yyyy-mm")
+	country := fs.String("country", cfg.holidayCountry(), "Public holidays: nl, be, de, gb or none")
+	jsonOut := fs.Bool("json", false, "Print the result as JSON")
+	positional, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+	if len(positional) > 0 {
+		fmt.Fprintln(os.Stderr, "Error: calendar takes no arguments")
+		fs.Usage()
+		return exitUsage
+	}
+	first, last, err := periodDays(*period)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -period %v\n", err)
+		return exitUsage
+	}
+	if err := validateHolidayCountry(*country); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -country %v\n", err)
+		return exitUsage
+	}
+
+	cal := cfg.workCalendar()
+	cal.Country = *country
+	lang := cfg.language()
+	days := cal.daysOff(first, last, lang)
+	working := cal.workingDays(first, last)
+	lastDay, _ := cal.lastWorkingDay(*period)
+	weekly, hasContract := cfg.contractHours()
+
+	if *jsonOut {
+		if days == nil {
+			days = []calendarDay{}
+		}
+		out := struct {
+			OK             bool          `json:"ok"`
+			Period         string        `json:"period"`
+			Country        string        `json:"country"`
+			WorkingDays    int           `json:"working_days"`
+			DaysOff        []calendarDay `json:"days_off"`
+			LastWorkingDay string        `json:"last_working_day"`
+			ContractHours  *float64      `json:"contract_hours,omitempty"`
+			ExpectedHours  *float64      `json:"expected_hours,omitempty"`
+		}{OK: true, Period: *period, Country: *country, WorkingDays: working, DaysOff: days, LastWorkingDay: lastDay.Format("2006-01-02")}
+		if hasContract {
+			expected := cal.expectedHours(first, last, weekly)
+			out.ContractHours, out.ExpectedHours = &weekly, &expected
+		}
+		writeJSON(out)
+		return exitOK
+	}
+	fmt.Printf("%s %d: %d working days (holidays: %s)\n", monthNames[lang][first.Month()-1], first.Year(), working, *country)
+	for _, d := range days {
+		day, _ := time.Parse("2006-01-02", d.Date)
+		fmt.Printf("  %s %s  %s\n", weekdayNames[lang][day.Weekday()], day.Format("02-01-2006"), d.Name)
+	}
+	fmt.Printf("Last working day: %s\n", lastDay.Format("02-01-2006"))
+	if hasContract {
+		fmt.Printf("Expected: %s hours (%s per week)\n", formatHours(cal.expectedHours(first, last, weekly), lang), formatHours(weekly, lang))
+	} else {
+		fmt.Println("Set contract_hours to see the expected hours")
+	}
+	return exitOK
+}
@@ -0,0 +1,589 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// iCalendar Import
+// ============================================================================
+
+// icsProperty is a content line of an iCalendar file, such as
+// DTSTART;TZID=Europe/Amsterdam:20250106T090000
+type icsProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// icsEvent is a VEVENT with the properties needed to count hours
+type icsEvent struct {
+	UID          string
+	Summary      string
+	Categories   []string
+	Calendar     string
+	Start, End   time.Time
+	AllDay       bool
+	Cancelled    bool
+	RRule        string
+	ExDates      []time.Time
+	RecurrenceID time.Time // set on a changed instance of a recurring event
+}
+
+// CalendarFilter selects the events of a calendar that are work. Empty
+// fields match all events.
+type CalendarFilter struct {
+	Category string // events with this category
+	Calendar string // events of the calendar with this name (X-WR-CALNAME)
+	Prefix   string // events whose title starts with this, which is stripped
+}
+
+// icsLines unfolds the content lines of an iCalendar file
+func icsLines(data []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func parseICSProperty(line string) icsProperty {
+	// The value starts at the first colon outside a quoted parameter
+	inQuote, split := false, len(line)
+	for i, c := range line {
+		if c == '"' {
+			inQuote = !inQuote
+		} else if c == ':' && !inQuote {
+			split = i
+			break
+		}
+	}
+	p := icsProperty{Params: map[string]string{}}
+	if split < len(line) {
+		p.Value = line[split+1:]
+	}
+	parts := strings.Split(line[:split], ";")
+	p.Name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		if k, v, ok := strings.Cut(param, "="); ok {
+			p.Params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return p
+}
+
+// icsText unescapes a TEXT value
+func icsText(s string) string {
+	r := strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`)
+	return strings.TrimSpace(r.Replace(s))
+}
+
+// parseICSTime parses a DATE or DATE-TIME value in the time zone of its TZID
+// parameter, or in UTC for values ending in Z. Time zones that cannot be
+// loaded, such as Windows names, are taken as local time.
+func parseICSTime(p icsProperty) (t time.Time, allDay bool, err error) {
+	value := p.Value
+	if p.Params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err = time.ParseInLocation("20060102", value, time.Local)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse("20060102T150405Z", value)
+		return t.Local(), false, err
+	}
+	loc := time.Local
+	if tzid := p.Params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err = time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+// parseICSDuration parses a DURATION value such as PT1H30M or P1D
+func parseICSDuration(s string) (time.Duration, error) {
+	orig := s
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimLeft(s, "+-")
+	if !strings.HasPrefix(s, "P") {
+		return 0, fmt.Errorf("invalid duration %q", orig)
+	}
+	s = s[1:]
+	var d time.Duration
+	inTime := false
+	for s != "" {
+		if s[0] == 'T' {
+			inTime, s = true, s[1:]
+			continue
+		}
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == 0 || i == len(s) {
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+		n, _ := strconv.Atoi(s[:i])
+		var unit time.Duration
+		switch {
+		case !inTime && s[i] == 'W':
+			unit = 7 * 24 * time.Hour
+		case !inTime && s[i] == 'D':
+			unit = 24 * time.Hour
+		case inTime && s[i] == 'H':
+			unit = time.Hour
+		case inTime && s[i] == 'M':
+			unit = time.Minute
+		case inTime && s[i] == 'S':
+			unit = time.Second
+		default:
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+		d += time.Duration(n) * unit
+		s = s[i+1:]
+	}
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
+
+// parseICS reads the events of an iCalendar file
+func parseICS(data []byte) ([]icsEvent, error) {
+	var (
+		events   []icsEvent
+		calendar string
+		event    *icsEvent
+		duration time.Duration
+		depth    int // nesting inside the event, e.g. VALARM
+	)
+	for _, line := range icsLines(data) {
+		p := parseICSProperty(line)
+		switch {
+		case p.Name == "BEGIN" && strings.EqualFold(p.Value, "VEVENT"):
+			event, duration, depth = &icsEvent{}, -1, 0
+			continue
+		case event == nil:
+			if p.Name == "X-WR-CALNAME" {
+				calendar = icsText(p.Value)
+			}
+			continue
+		case p.Name == "BEGIN":
+			depth++
+			continue
+		case p.Name == "END" && depth > 0:
+			depth--
+			continue
+		case depth > 0:
+			continue
+		case p.Name == "END" && strings.EqualFold(p.Value, "VEVENT"):
+			if event.Start.IsZero() {
+				return nil, fmt.Errorf("event %q has no DTSTART", event.Summary)
+			}
+			if event.End.IsZero() {
+				switch {
+				case duration >= 0:
+					event.End = event.Start.Add(duration)
+				case event.AllDay:
+					event.End = event.Start.AddDate(0, 0, 1)
+				default:
+					event.End = event.Start
+				}
+			}
+			event.Calendar = calendar
+			events = append(events, *event)
+			event = nil
+			continue
+		}
+
+		var err error
+		switch p.Name {
+		case "UID":
+			event.UID = p.Value
+		case "SUMMARY":
+			event.Summary = icsText(p.Value)
+		case "CATEGORIES":
+			for _, c := range strings.Split(p.Value, ",") {
+				if c = icsText(c); c != "" {
+					event.Categories = append(event.Categories, c)
+				}
+			}
+		case "STATUS":
+			event.Cancelled = strings.EqualFold(p.Value, "CANCELLED")
+		case "DTSTART":
+			event.Start, event.AllDay, err = parseICSTime(p)
+		case "DTEND":
+			event.End, _, err = parseICSTime(p)
+		case "DURATION":
+			duration, err = parseICSDuration(p.Value)
+		case "RRULE":
+			event.RRule = p.Value
+		case "EXDATE":
+			for _, v := range strings.Split(p.Value, ",") {
+				var t time.Time
+				if t, _, err = parseICSTime(icsProperty{Params: p.Params, Value: v}); err != nil {
+					break
+				}
+				event.ExDates = append(event.ExDates, t)
+			}
+		case "RECURRENCE-ID":
+			event.RecurrenceID, _, err = parseICSTime(p)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("event %q: invalid %s: %w", event.Summary, p.Name, err)
+		}
+	}
+	if event != nil {
+		return nil, fmt.Errorf("event %q is not closed with END:VEVENT", event.Summary)
+	}
+	return events, nil
+}
+
+// recurrence is a parsed RRULE
+type recurrence struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []weekdayRule
+	ByMonthDay []int
+	WeekStart  time.Weekday
+}
+
+// weekdayRule is a BYDAY value such as MO, 2TU or -1FR
+type weekdayRule struct {
+	Ordinal int // 0 for every such weekday
+	Day     time.Weekday
+}
+
+var icsWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+func parseRRule(s string) (recurrence, error) {
+	r := recurrence{Interval: 1, WeekStart: time.Monday}
+	for _, part := range strings.Split(s, ";") {
+		key, value, _ := strings.Cut(part, "=")
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = strings.ToUpper(value)
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err == nil && r.Interval < 1 {
+				err = fmt.Errorf("must be at least 1")
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+		case "UNTIL":
+			r.Until, _, err = parseICSTime(icsProperty{Value: value})
+		case "WKST":
+			day, ok := icsWeekdays[strings.ToUpper(value)]
+			if !ok {
+				err = fmt.Errorf("unknown weekday %q", value)
+			}
+			r.WeekStart = day
+		case "BYDAY":
+			for _, v := range strings.Split(value, ",") {
+				v = strings.ToUpper(v)
+				if len(v) < 2 {
+					return r, fmt.Errorf("invalid BYDAY %q", value)
+				}
+				day, ok := icsWeekdays[v[len(v)-2:]]
+				if !ok {
+					return r, fmt.Errorf("invalid BYDAY %q", value)
+				}
+				ordinal := 0
+				if n := strings.TrimPrefix(v[:len(v)-2], "+"); n != "" {
+					if ordinal, err = strconv.Atoi(n); err != nil {
+						return r, fmt.Errorf("invalid BYDAY %q", value)
+					}
+				}
+				r.ByDay = append(r.ByDay, weekdayRule{ordinal, day})
+			}
+		case "BYMONTHDAY":
+			for _, v := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(v)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return r, fmt.Errorf("invalid BYMONTHDAY %q", value)
+				}
+				r.ByMonthDay = append(r.ByMonthDay, n)
+			}
+		}
+		if err != nil {
+			return r, fmt.Errorf("invalid %s %q: %w", key, value, err)
+		}
+	}
+	switch r.Freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+		return r, nil
+	}
+	return r, fmt.Errorf("unsupported FREQ %q", r.Freq)
+}
+
+// onDay returns start moved to the date of day, at the same wall clock time
+func onDay(start time.Time, year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+}
+
+// candidates returns the instances of the n-th period of the rule, in order
+func (r recurrence) candidates(start time.Time, n int) []time.Time {
+	var days []time.Time
+	switch r.Freq {
+	case "DAILY":
+		day := start.AddDate(0, 0, n*r.Interval)
+		if len(r.ByDay) == 0 || r.matchesWeekday(day.Weekday()) {
+			days = append(days, day)
+		}
+	case "WEEKLY":
+		offset := (int(start.Weekday()) - int(r.WeekStart) + 7) % 7
+		weekStart := start.AddDate(0, 0, n*7*r.Interval-offset)
+		if len(r.ByDay) == 0 {
+			return []time.Time{weekStart.AddDate(0, 0, offset)}
+		}
+		for i := 0; i < 7; i++ {
+			if day := weekStart.AddDate(0, 0, i); r.matchesWeekday(day.Weekday()) {
+				days = append(days, day)
+			}
+		}
+	case "MONTHLY":
+		first := time.Date(start.Year(), start.Month()+time.Month(n*r.Interval), 1, 0, 0, 0, 0, start.Location())
+		days = r.monthDays(start, first.Year(), first.Month())
+	case "YEARLY":
+		year := start.Year() + n*r.Interval
+		if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+			if day := onDay(start, year, start.Month(), start.Day()); day.Day() == start.Day() {
+				days = append(days, day)
+			}
+		} else {
+			days = r.monthDays(start, year, start.Month())
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
+
+func (r recurrence) matchesWeekday(day time.Weekday) bool {
+	for _, w := range r.ByDay {
+		if w.Day == day {
+			return true
+		}
+	}
+	return false
+}
+
+// monthDays returns the days of a month selected by BYMONTHDAY or BYDAY, or
+// the day of the month of start
+func (r recurrence) monthDays(start time.Time, year int, month time.Month) []time.Time {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	var days []time.Time
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, d := range r.ByMonthDay {
+			if d < 0 {
+				d = last + 1 + d
+			}
+			if d >= 1 && d <= last {
+				days = append(days, onDay(start, year, month, d))
+			}
+		}
+	case len(r.ByDay) > 0:
+		for _, w := range r.ByDay {
+			var matches []int
+			for d := 1; d <= last; d++ {
+				if time.Date(year, month, d, 0, 0, 0, 0, time.UTC).Weekday() == w.Day {
+					matches = append(matches, d)
+				}
+			}
+			switch {
+			case w.Ordinal == 0:
+				for _, d := range matches {
+					days = append(days, onDay(start, year, month, d))
+				}
+			case w.Ordinal > 0 && w.Ordinal <= len(matches):
+				days = append(days, onDay(start, year, month, matches[w.Ordinal-1]))
+			case w.Ordinal < 0 && -w.Ordinal <= len(matches):
+				days = append(days, onDay(start, year, month, matches[len(matches)+w.Ordinal]))
+			}
+		}
+	case start.Day() <= last:
+		days = append(days, onDay(start, year, month, start.Day()))
+	}
+	return days
+}
+
+// maxRecurrences bounds the expansion of rules without COUNT or UNTIL
+const maxRecurrences = 100000
+
+// occurrences returns the start times of the instances of a recurring
+// event that start before end, minus its EXDATEs
+func occurrences(e icsEvent, r recurrence, end time.Time) []time.Time {
+	excluded := func(t time.Time) bool {
+		for _, x := range e.ExDates {
+			if x.Equal(t) || (e.AllDay && x.Format("20060102") == t.Format("20060102")) {
+				return true
+			}
+		}
+		return false
+	}
+	var starts []time.Time
+	count := 0
+	for n := 0; n < maxRecurrences; n++ {
+		for _, t := range r.candidates(e.Start, n) {
+			if t.Before(e.Start) {
+				continue
+			}
+			if !t.Before(end) || (!r.Until.IsZero() && t.After(r.Until)) || (r.Count > 0 && count >= r.Count) {
+				return starts
+			}
+			// COUNT includes the instances removed by EXDATE
+			count++
+			if !excluded(t) {
+				starts = append(starts, t)
+			}
+		}
+	}
+	return starts
+}
+
+// matches reports whether the event passes filter
+func (f CalendarFilter) matches(e icsEvent) bool {
+	if f.Calendar != "" && !strings.EqualFold(e.Calendar, f.Calendar) {
+		return false
+	}
+	if f.Category != "" && !slices.ContainsFunc(e.Categories, func(c string) bool { return strings.EqualFold(c, f.Category) }) {
+		return false
+	}
+	if f.Prefix != "" {
+		if _, ok := cutPrefixFold(e.Summary, f.Prefix); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// cutPrefixFold returns s without prefix, matched ignoring case. Only the
+// first len(prefix) bytes of s are compared, as lowercasing may change the
+// length of text such as "İ".
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
+
+// eventProject splits the title of an event into a project and description:
+// "Acme: backend" is project Acme. Without a colon the project is the first
+// category other than the filter's, or else the name of the calendar.
+func (f CalendarFilter) eventProject(e icsEvent) (project, description string) {
+	title := e.Summary
+	if rest, ok := cutPrefixFold(title, f.Prefix); ok {
+		title = rest
+	}
+	title = strings.TrimLeft(strings.TrimSpace(title), ":-– ")
+	if p, d, ok := strings.Cut(title, ":"); ok && strings.TrimSpace(p) != "" {
+		return strings.TrimSpace(p), strings.TrimSpace(d)
+	}
+	for _, c := range e.Categories {
+		if !strings.EqualFold(c, f.Category) {
+			return c, title
+		}
+	}
+	return e.Calendar, title
+}
+
+// calendarEntries returns the time entries of the events in filter between
+// from and to, as imported time tracker entries. All-day and cancelled
+// events are left out; an event counts on the day it starts.
+func calendarEntries(events []icsEvent, filter CalendarFilter, from, to time.Time) ([]trackerEntry, error) {
+	// Instances are keyed by Unix time, as equal times in different
+	// locations are different time.Time values
+	type instance struct {
+		uid   string
+		start int64
+	}
+	// Changed instances of recurring events replace the original
+	changed := map[instance]bool{}
+	for _, e := range events {
+		if !e.RecurrenceID.IsZero() {
+			changed[instance{e.UID, e.RecurrenceID.Unix()}] = true
+		}
+	}
+
+	var entries []trackerEntry
+	add := func(e icsEvent, start time.Time) {
+		if start.Before(from) || !start.Before(to) {
+			return
+		}
+		project, description := filter.eventProject(e)
+		entries = append(entries, trackerEntry{
+			Date:        start.Format("2006-01-02"),
+			Project:     project,
+			Description: description,
+			Hours:       e.End.Sub(e.Start).Hours(),
+		})
+	}
+	for _, e := range events {
+		if e.AllDay || e.Cancelled || !filter.matches(e) {
+			continue
+		}
+		if e.RRule == "" || !e.RecurrenceID.IsZero() {
+			add(e, e.Start)
+			continue
+		}
+		r, err := parseRRule(e.RRule)
+		if err != nil {
+			return nil, fmt.Errorf("event %q: %w", e.Summary, err)
+		}
+		for _, start := range occurrences(e, r, to) {
+			if !changed[instance{e.UID, start.Unix()}] {
+				add(e, start)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// loadCalendar reads the hours of the events of an iCalendar file in
+// opts.Period
+func loadCalendar(path string, opts ImportOptions) ([]Entry, error) {
+	if opts.Period == "" {
+		return nil, signError(KindHoursData, path, fmt.Errorf("a calendar needs the month to count (use -period)"))
+	}
+	month, err := time.ParseInLocation("2006-01", opts.Period, time.Local)
+	if err != nil {
+		return nil, signError(KindHoursData, path, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, signError(KindHoursData, path, fmt.Errorf("failed to read calendar: %w", err))
+	}
+	events, err := parseICS(data)
+	if err != nil {
+		return nil, signError(KindHoursData, path, fmt.Errorf("invalid calendar: %w", err))
+	}
+	raw, err := calendarEntries(events, opts.Filter, month, month.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, signError(KindHoursData, path, fmt.Errorf("invalid calendar: %w", err))
+	}
+	return importTracker(raw, opts), nil
+}
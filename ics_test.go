@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestCalendarFilterPrefix(t *testing.T) {
+	f := CalendarFilter{Prefix: "work"}
+	for _, tc := range []struct {
+		summary              string
+		match                bool
+		project, description string
+	}{
+		{"WORK: Acme: backend", true, "Acme", "backend"},
+		{"Work - Acme: release", true, "Acme", "release"},
+		{"Workshop", true, "Cal", "shop"},
+		{"Wor", false, "", ""},
+		{"Lunch", false, "", ""},
+	} {
+		e := icsEvent{Summary: tc.summary, Calendar: "Cal"}
+		if got := f.matches(e); got != tc.match {
+			t.Errorf("%q: matches = %v, want %v", tc.summary, got, tc.match)
+		}
+		if !tc.match {
+			continue
+		}
+		project, description := f.eventProject(e)
+		if project != tc.project || description != tc.description {
+			t.Errorf("%q: project %q, description %q, want %q, %q", tc.summary, project, description, tc.project, tc.description)
+		}
+	}
+}
+
+func TestCalendarFilterPrefixLength(t *testing.T) {
+	// Lowercase "İ" is three bytes, one more than "İ" itself: the summary
+	// must not be cut at the length of the prefix once lowercased
+	f := CalendarFilter{Prefix: "i̇ş"}
+	for _, summary := range []string{"İş", "İ", "İŞ: Acme"} {
+		e := icsEvent{Summary: summary, Calendar: "Cal"}
+		if f.matches(e) {
+			t.Errorf("%q matches prefix %q", summary, f.Prefix)
+		}
+		// Must not panic on titles shorter than the prefix
+		f.eventProject(e)
+	}
+
+	f = CalendarFilter{Prefix: "Ärzte"}
+	e := icsEvent{Summary: "ÄRZTE: Acme: backend", Calendar: "Cal"}
+	if !f.matches(e) {
+		t.Errorf("%q does not match prefix %q", e.Summary, f.Prefix)
+	}
+	if project, description := f.eventProject(e); project != "Acme" || description != "backend" {
+		t.Errorf("project %q, description %q, want Acme, backend", project, description)
+	}
+}
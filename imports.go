@@ -0,0 +1,544 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// Time Tracker Imports
+// ============================================================================
+
+// Time trackers whose CSV exports can be imported, and iCalendar files
+const (
+	trackerToggl    = "toggl"
+	trackerClockify = "clockify"
+	trackerHarvest  = "harvest"
+	sourceCalendar  = "ics"
+)
+
+// How durations are rounded
+const (
+	roundNearest = "nearest"
+	roundUp      = "up"
+	roundDown    = "down"
+)
+
+// roundingRule rounds hours to a multiple of Step. The zero value does not
+// round.
+type roundingRule struct {
+	Mode string
+	Step time.Duration
+}
+
+// parseRounding parses a rounding rule such as 15m, up:15m or down:6m; off
+// or an empty value does not round
+func parseRounding(value string) (roundingRule, error) {
+	if value == "" || value == "off" {
+		return roundingRule{}, nil
+	}
+	mode, step := roundNearest, value
+	if m, s, ok := strings.Cut(value, ":"); ok {
+		mode, step = m, s
+	}
+	if mode != roundNearest && mode != roundUp && mode != roundDown {
+		return roundingRule{}, fmt.Errorf("invalid rounding %q: the mode must be %q, %q or %q", value, roundNearest, roundUp, roundDown)
+	}
+	d, err := time.ParseDuration(step)
+	if err != nil || d < time.Minute || d > 24*time.Hour {
+		return roundingRule{}, fmt.Errorf("invalid rounding %q (e.g. 15m, up:15m or down:6m)", value)
+	}
+	return roundingRule{Mode: mode, Step: d}, nil
+}
+
+func validateRounding(value string) error {
+	_, err := parseRounding(value)
+	return err
+}
+
+func (r roundingRule) apply(hours float64) float64 {
+	if r.Step == 0 {
+		return hours
+	}
+	steps := hours / r.Step.Hours()
+	// Durations in seconds are not exact in hours; 0.25000000001 steps is 0.25
+	steps = math.Round(steps*1e6) / 1e6
+	switch r.Mode {
+	case roundUp:
+		steps = math.Ceil(steps)
+	case roundDown:
+		steps = math.Floor(steps)
+	default:
+		steps = math.Round(steps)
+	}
+	return steps * r.Step.Hours()
+}
+
+func (r roundingRule) String() string {
+	if r.Step == 0 {
+		return "off"
+	}
+	// Written as 15m or 1h rather than 15m0s or 1h0m0s
+	step := strings.TrimSuffix(r.Step.String(), "0s")
+	if strings.HasSuffix(step, "h0m") {
+		step = strings.TrimSuffix(step, "0m")
+	}
+	return fmt.Sprintf("%s:%s", r.Mode, step)
+}
+
+// parseProjectClients parses "project=client" pairs separated by commas
+func parseProjectClients(value string) (map[string]string, error) {
+	clients := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		project, client, ok := strings.Cut(pair, "=")
+		project, client = strings.TrimSpace(project), strings.TrimSpace(client)
+		if !ok || project == "" || client == "" {
+			return nil, fmt.Errorf("invalid project mapping %q (expected project=client)", pair)
+		}
+		clients[strings.ToLower(project)] = client
+	}
+	return clients, nil
+}
+
+func validateProjectClients(value string) error {
+	_, err := parseProjectClients(value)
+	return err
+}
+
+// ImportOptions describes how time tracker entries become hours entries
+type ImportOptions struct {
+	Rounding roundingRule
+	// Clients maps lowercase project names to clients, over the client in
+	// the export
+	Clients map[string]string
+	// Client keeps only the entries of this client, if set
+	Client string
+	// Filter selects the events of a calendar, and Period is the month they
+	// are counted in
+	Filter CalendarFilter
+	Period string
+}
+
+// importOptionsFromConfig returns the import options set in cfg
+func importOptionsFromConfig(cfg Config) (ImportOptions, error) {
+	rounding, err := parseRounding(cfg.Rounding)
+	if err != nil {
+		return ImportOptions{}, signError(KindConfig, "", fmt.Errorf("rounding: %w", err))
+	}
+	clients, err := parseProjectClients(cfg.ProjectClients)
+	if err != nil {
+		return ImportOptions{}, signError(KindConfig, "", fmt.Errorf("project_clients: %w", err))
+	}
+	return ImportOptions{Rounding: rounding, Clients: clients}, nil
+}
+
+// trackerEntry is a time entry of a time tracker export
+type trackerEntry struct {
+	Date        string // yyyy-mm-dd
+	Client      string
+	Project     string
+	Description string
+	Hours       float64
+}
+
+// trackerColumns are the columns of a time tracker export, by role. The
+// first header found is used.
+type trackerColumns struct {
+	Date        []string
+	Client      []string
+	Project     []string
+	Description []string
+	Duration    []string
+}
+
+var trackerFormats = map[string]trackerColumns{
+	// Toggl Track: Reports > Detailed > Export CSV
+	trackerToggl: {
+		Date:        []string{"start date"},
+		Client:      []string{"client"},
+		Project:     []string{"project"},
+		Description: []string{"description", "task"},
+		Duration:    []string{"duration"},
+	},
+	// Clockify: Reports > Detailed > Export CSV
+	trackerClockify: {
+		Date:        []string{"start date"},
+		Client:      []string{"client"},
+		Project:     []string{"project"},
+		Description: []string{"description", "task"},
+		Duration:    []string{"duration (decimal)", "duration (h)"},
+	},
+	// Harvest: Reports > Detailed time > Export CSV
+	trackerHarvest: {
+		Date:        []string{"date"},
+		Client:      []string{"client"},
+		Project:     []string{"project"},
+		Description: []string{"notes", "task"},
+		Duration:    []string{"hours"},
+	},
+}
+
+// detectTracker recognizes the time tracker of an export by its header
+func detectTracker(header []string) string {
+	has := map[string]bool{}
+	for _, h := range header {
+		has[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = true
+	}
+	switch {
+	case has["start date"] && (has["duration (decimal)"] || has["duration (h)"]):
+		return trackerClockify
+	case has["start date"] && has["duration"]:
+		return trackerToggl
+	case has["date"] && has["hours"] && has["notes"]:
+		return trackerHarvest
+	}
+	return ""
+}
+
+func validateSource(value string) error {
+	if _, ok := trackerFormats[value]; !ok && value != sourceCalendar {
+		return fmt.Errorf("must be %q, %q, %q or %q", trackerToggl, trackerClockify, trackerHarvest, sourceCalendar)
+	}
+	return nil
+}
+
+// readTrackerCSV reads the header and records of a CSV export
+func readTrackerCSV(data []byte) ([]string, [][]string, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		r.Comma = ';'
+	}
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	var records [][]string
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		records = append(records, record)
+	}
+	return header, records, nil
+}
+
+// parseTrackerCSV reads the time entries of an export of tracker
+func parseTrackerCSV(tracker string, header []string, records [][]string) ([]trackerEntry, error) {
+	format := trackerFormats[tracker]
+	index := map[string]int{}
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		if _, ok := index[h]; !ok {
+			index[h] = i
+		}
+	}
+	column := func(names []string) int {
+		for _, name := range names {
+			if i, ok := index[name]; ok {
+				return i
+			}
+		}
+		return -1
+	}
+	dateCol, durationCol := column(format.Date), column(format.Duration)
+	if dateCol < 0 || durationCol < 0 {
+		return nil, fmt.Errorf("not a %s export: no %q or %q column", tracker, format.Date[0], format.Duration[0])
+	}
+	clientCol, projectCol, descCol := column(format.Client), column(format.Project), column(format.Description)
+
+	var entries []trackerEntry
+	for n, record := range records {
+		line := n + 2
+		get := func(i int) string {
+			if i >= 0 && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		if get(dateCol) == "" && get(durationCol) == "" {
+			continue
+		}
+		date, ok := parseTrackerDate(tracker, get(dateCol))
+		if !ok {
+			return nil, fmt.Errorf("line %d: invalid date %q", line, get(dateCol))
+		}
+		hours, ok := parseDuration(get(durationCol))
+		if !ok {
+			return nil, fmt.Errorf("line %d: invalid duration %q", line, get(durationCol))
+		}
+		entries = append(entries, trackerEntry{
+			Date:        date,
+			Client:      get(clientCol),
+			Project:     get(projectCol),
+			Description: get(descCol),
+			Hours:       hours,
+		})
+	}
+	return entries, nil
+}
+
+// parseTrackerDate parses the date of a time entry. Clockify writes dates
+// with slashes month first, as 01/31/2025, unless set to another format.
+func parseTrackerDate(tracker, s string) (string, bool) {
+	if tracker == trackerClockify && strings.Count(s, "/") == 2 {
+		if t, err := time.Parse("01/02/2006", s); err == nil {
+			return t.Format("2006-01-02"), true
+		}
+	}
+	return parseTableDate(s)
+}
+
+// parseDuration parses a duration as h:mm:ss, h:mm or decimal hours
+func parseDuration(s string) (float64, bool) {
+	parts := strings.Split(s, ":")
+	if len(parts) == 3 {
+		var v [3]int
+		for i, p := range parts {
+			n, err := strconv.Atoi(p)
+			if err != nil || n < 0 || (i > 0 && n > 59) {
+				return 0, false
+			}
+			v[i] = n
+		}
+		return float64(v[0]) + float64(v[1])/60 + float64(v[2])/3600, true
+	}
+	if v, err := strconv.ParseFloat(s, 64); err == nil && v >= 0 {
+		return v, true
+	}
+	return parseHours(s)
+}
+
+// importTracker turns time entries into hours entries: one per day, client
+// and project, with the descriptions combined and the hours rounded
+func importTracker(entries []trackerEntry, opts ImportOptions) []Entry {
+	type key struct{ date, client, project string }
+	var (
+		order  []key
+		hours  = map[key]float64{}
+		descs  = map[key][]string{}
+		client = opts.Client
+	)
+	for _, e := range entries {
+		if c, ok := opts.Clients[strings.ToLower(e.Project)]; ok {
+			e.Client = c
+		}
+		if client != "" && !strings.EqualFold(e.Client, client) {
+			continue
+		}
+		k := key{e.Date, e.Client, e.Project}
+		if _, ok := hours[k]; !ok {
+			order = append(order, k)
+		}
+		hours[k] += e.Hours
+		if e.Description != "" && !slices.ContainsFunc(descs[k], func(d string) bool { return strings.EqualFold(d, e.Description) }) {
+			descs[k] = append(descs[k], e.Description)
+		}
+	}
+
+	var result []Entry
+	for _, k := range order {
+		h := opts.Rounding.apply(hours[k])
+		if h == 0 {
+			continue
+		}
+		result = append(result, Entry{
+			Date:        k.date,
+			Client:      k.client,
+			Project:     k.project,
+			Description: strings.Join(descs[k], "; "),
+			Hours:       math.Round(h*100) / 100,
+		})
+	}
+	sortEntries(result)
+	return result
+}
+
+// loadHours reads hours entries from a time tracker export, an iCalendar
+// file, or an hours file as read by loadEntries. An empty source is detected
+// from the extension and the header; the source read is returned, empty for
+// an hours file.
+func loadHours(path, source string, opts ImportOptions) ([]Entry, string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if source == sourceCalendar || (source == "" && ext == ".ics") {
+		entries, err := loadCalendar(path, opts)
+		return entries, sourceCalendar, err
+	}
+	if source == "" && ext == ".json" {
+		entries, err := loadEntries(path)
+		return filterClient(entries, opts.Client), "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", signError(KindHoursData, path, fmt.Errorf("failed to read hours: %w", err))
+	}
+	header, records, err := readTrackerCSV(data)
+	if err != nil {
+		return nil, "", signError(KindHoursData, path, err)
+	}
+	if source == "" {
+		source = detectTracker(header)
+	}
+	if source == "" {
+		entries, err := loadEntries(path)
+		return filterClient(entries, opts.Client), "", err
+	}
+	raw, err := parseTrackerCSV(source, header, records)
+	if err != nil {
+		return nil, "", signError(KindHoursData, path, err)
+	}
+	return importTracker(raw, opts), source, nil
+}
+
+// filterClient keeps the entries of client and those without a client
+func filterClient(entries []Entry, client string) []Entry {
+	if client == "" {
+		return entries
+	}
+	var kept []Entry
+	for _, e := range entries {
+		if e.Client == "" || strings.EqualFold(e.Client, client) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// hoursSourceFlags are the flags of the commands that read hours, on how
+// time tracker exports and calendars are imported
+type hoursSourceFlags struct {
+	from, rounding, client *string
+	filter                 CalendarFilter
+}
+
+func addHoursSourceFlags(fs *flag.FlagSet, cfg Config) *hoursSourceFlags {
+	f := &hoursSourceFlags{
+		from:     fs.String("from", "", "Source of the hours: toggl, clockify, harvest or ics (default: detected)"),
+		rounding: fs.String("round", cfg.Rounding, "Rounding of imported hours per day and project, e.g. 15m, up:15m or down:6m"),
+		client:   fs.String("client", "", "Only the hours of this client"),
+	}
+	fs.StringVar(&f.filter.Category, "category", "", "Only calendar events with this category")
+	fs.StringVar(&f.filter.Calendar, "calendar", "", "Only events of the calendar with this name")
+	fs.StringVar(&f.filter.Prefix, "prefix", "", "Only calendar events whose title starts with this")
+	return f
+}
+
+// options returns the import options of the flags and the config. On invalid
+// flags it prints the error and returns false with the exit code.
+func (f *hoursSourceFlags) options(cfg Config, period string, jsonOut bool) (ImportOptions, int, bool) {
+	if *f.from != "" {
+		if err := validateSource(*f.from); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -from %v\n", err)
+			return ImportOptions{}, exitUsage, false
+		}
+	}
+	opts, err := importOptionsFromConfig(cfg)
+	if err != nil {
+		return ImportOptions{}, fail(jsonOut, err), false
+	}
+	if opts.Rounding, err = parseRounding(*f.rounding); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -round %v\n", err)
+		return ImportOptions{}, exitUsage, false
+	}
+	opts.Client = *f.client
+	opts.Filter = f.filter
+	opts.Period = period
+	return opts, exitOK, true
+}
+
+func runImport(args []string) int {
+	cfg := LoadConfig()
+
+	fs := newFlagSet("import", "[flags] <export.csv|calendar.ics>", "Convert a CSV export of Toggl Track, Clockify or Harvest, or the events of an\niCalendar file, to hours entries for generate: one per day and project,\nrounded, and mapped to clients.")
+	source := addHoursSourceFlags(fs, cfg)
+	period := fs.String("period", "", "Import only the entries of this month (yyyy-mm); required for calendars")
+	format := fs.String("format", tableCSV, "Output format: csv or json")
+	outputFile := fs.String("output", "", "Write to this file instead of standard output")
+	jsonOut := fs.Bool("json", false, "Print a summary as JSON; requires -output")
+	positional, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: exactly one export or calendar file is required")
+		fs.Usage()
+		return exitUsage
+	}
+	if err := validateTableFormat(*format); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -format %v\n", err)
+		return exitUsage
+	}
+	if *period != "" {
+		if err := validatePeriod(*period); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -period %v\n", err)
+			return exitUsage
+		}
+	}
+	if *jsonOut && *outputFile == "" {
+		fmt.Fprintln(os.Stderr, "Error: -json requires -output")
+		return exitUsage
+	}
+	opts, code, ok := source.options(cfg, *period, *jsonOut)
+	if !ok {
+		return code
+	}
+
+	entries, tracker, err := loadHours(positional[0], *source.from, opts)
+	if err != nil {
+		return fail(*jsonOut, err)
+	}
+	if tracker == "" {
+		return fail(*jsonOut, signError(KindHoursData, positional[0], fmt.Errorf("not a Toggl Track, Clockify or Harvest export or a calendar (use -from)")))
+	}
+	if *period != "" {
+		entries, _ = entriesIn(entries, *period)
+	}
+	data, err := encodeEntries(entries, *format)
+	if err != nil {
+		return fail(*jsonOut, err)
+	}
+	if *outputFile == "" {
+		os.Stdout.Write(data)
+		return exitOK
+	}
+	if err := writeFileAtomic(*outputFile, data, outputFileMode(*outputFile, 0)); err != nil {
+		return fail(*jsonOut, signError(KindOutputWrite, *outputFile, fmt.Errorf("failed to write output file: %w", err)))
+	}
+
+	total := 0.0
+	for _, e := range entries {
+		total += e.Hours
+	}
+	if *jsonOut {
+		writeJSON(struct {
+			OK         bool    `json:"ok"`
+			Output     string  `json:"output"`
+			Tracker    string  `json:"tracker"`
+			Rounding   string  `json:"rounding"`
+			Entries    int     `json:"entries"`
+			TotalHours float64 `json:"total_hours"`
+		}{true, *outputFile, tracker, opts.Rounding.String(), len(entries), math.Round(total*100) / 100})
+		return exitOK
+	}
+	fmt.Printf("✓ Imported %d entries (%s hours) from %s to %s\n", len(entries), formatHours(total, "en"), tracker, *outputFile)
+	fmt.Printf("  Rounding: %s\n", opts.Rounding)
+	return exitOK
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// formatEntries renders entries one per line, for comparing in tests
+func formatEntries(entries []Entry) string {
+	var b strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&b, "%s|%s|%s|%s|%v\n", e.Date, e.Client, e.Project, e.Description, e.Hours)
+	}
+	return b.String()
+}
+
+func loadTestExport(t *testing.T, name string, opts ImportOptions) ([]Entry, string) {
+	t.Helper()
+	entries, tracker, err := loadHours("testdata/imports/"+name, "", opts)
+	if err != nil {
+		t.Fatalf("loadHours(%s): %v", name, err)
+	}
+	return entries, tracker
+}
+
+func TestDetectTracker(t *testing.T) {
+	for name, want := range map[string]string{
+		"toggl.csv":    trackerToggl,
+		"clockify.csv": trackerClockify,
+		"harvest.csv":  trackerHarvest,
+	} {
+		data, err := os.ReadFile("testdata/imports/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header, _, err := readTrackerCSV(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := detectTracker(header); got != want {
+			t.Errorf("%s: detectTracker = %q, want %q", name, got, want)
+		}
+	}
+
+	// An hours file is not an export
+	if got := detectTracker([]string{"Date", "Project", "Hours"}); got != "" {
+		t.Errorf("detectTracker(hours file) = %q, want none", got)
+	}
+}
+
+func TestImportToggl(t *testing.T) {
+	entries, tracker := loadTestExport(t, "toggl.csv", ImportOptions{})
+	if tracker != trackerToggl {
+		t.Errorf("tracker = %q, want %q", tracker, trackerToggl)
+	}
+	// One entry per day and project, h:mm:ss durations added up, the same
+	// description only once
+	want := "" +
+		"2026-09-01|Acme|Backend|API review; Deploy|4.45\n" +
+		"2026-09-01|Globex|Support|Tickets|1.17\n" +
+		"2026-09-02||Internal|Planning|0.83\n" +
+		"2026-09-02|Acme|Backend|API review|6\n"
+	if got := formatEntries(entries); got != want {
+		t.Errorf("entries:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestImportClockify(t *testing.T) {
+	entries, tracker := loadTestExport(t, "clockify.csv", ImportOptions{})
+	if tracker != trackerClockify {
+		t.Errorf("tracker = %q, want %q", tracker, trackerClockify)
+	}
+	// Semicolons, month-first dates and decimal commas
+	want := "" +
+		"2026-09-01|Acme|Backend|API review; Deploy|4.25\n" +
+		"2026-09-02|Globex|Support|Tickets|0.67\n" +
+		"2026-09-30|Acme|Backend|Release|7\n"
+	if got := formatEntries(entries); got != want {
+		t.Errorf("entries:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestImportClockifyDurationH(t *testing.T) {
+	data, err := os.ReadFile("testdata/imports/clockify.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, records, err := readTrackerCSV(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the decimal column the h:mm:ss duration is used
+	decimal := -1
+	for i, h := range header {
+		if h == "Duration (decimal)" {
+			decimal = i
+		}
+	}
+	if decimal < 0 {
+		t.Fatal("no Duration (decimal) column in the test export")
+	}
+	drop := func(row []string) []string {
+		return append(append([]string{}, row[:decimal]...), row[decimal+1:]...)
+	}
+	header = drop(header)
+	for i := range records {
+		records[i] = drop(records[i])
+	}
+
+	if got := detectTracker(header); got != trackerClockify {
+		t.Errorf("detectTracker = %q, want %q", got, trackerClockify)
+	}
+	raw, err := parseTrackerCSV(trackerClockify, header, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "" +
+		"2026-09-01|Acme|Backend|API review; Deploy|4.25\n" +
+		"2026-09-02|Globex|Support|Tickets|0.67\n" +
+		"2026-09-30|Acme|Backend|Release|7\n"
+	if got := formatEntries(importTracker(raw, ImportOptions{})); got != want {
+		t.Errorf("entries:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestImportHarvest(t *testing.T) {
+	entries, tracker := loadTestExport(t, "harvest.csv", ImportOptions{})
+	if tracker != trackerHarvest {
+		t.Errorf("tracker = %q, want %q", tracker, trackerHarvest)
+	}
+	// The hours, not the rounded hours, of Harvest
+	want := "" +
+		"2026-09-01|Acme|Backend|API review|3.75\n" +
+		"2026-09-03|Globex|Support|Tickets|0.4\n"
+	if got := formatEntries(entries); got != want {
+		t.Errorf("entries:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestImportRounding(t *testing.T) {
+	// The Toggl export has 4:27, 1:10, 0:50 and 6:00 per day and project
+	for _, tc := range []struct {
+		rounding string
+		want     []float64
+	}{
+		{"off", []float64{4.45, 1.17, 0.83, 6}},
+		{"15m", []float64{4.5, 1.25, 0.75, 6}},
+		{"up:15m", []float64{4.5, 1.25, 1, 6}},
+		{"down:6m", []float64{4.4, 1.1, 0.8, 6}},
+	} {
+		rule, err := parseRounding(tc.rounding)
+		if err != nil {
+			t.Fatalf("parseRounding(%q): %v", tc.rounding, err)
+		}
+		entries, _ := loadTestExport(t, "toggl.csv", ImportOptions{Rounding: rule})
+		var got []float64
+		for _, e := range entries {
+			got = append(got, e.Hours)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("%s: hours = %v, want %v", tc.rounding, got, tc.want)
+		}
+	}
+}
+
+func TestRoundingRule(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		hours float64
+		want  float64
+	}{
+		// Exact multiples stay as they are, also when rounding up
+		{"up:15m", 0.25, 0.25},
+		{"up:15m", 900.0 / 3600, 0.25},
+		{"up:15m", 0.26, 0.5},
+		{"down:6m", 0.19, 0.1},
+		{"15m", 0.125, 0.25},
+		{"nearest:30m", 7.7, 7.5},
+	} {
+		rule, err := parseRounding(tc.value)
+		if err != nil {
+			t.Fatalf("parseRounding(%q): %v", tc.value, err)
+		}
+		if got := rule.apply(tc.hours); fmt.Sprintf("%.4f", got) != fmt.Sprintf("%.4f", tc.want) {
+			t.Errorf("%s of %v = %v, want %v", tc.value, tc.hours, got, tc.want)
+		}
+	}
+
+	for value, want := range map[string]string{"": "off", "off": "off", "15m": "nearest:15m", "up:15m": "up:15m", "down:6m": "down:6m", "up:1h": "up:1h", "up:90m": "up:1h30m"} {
+		rule, err := parseRounding(value)
+		if err != nil {
+			t.Fatalf("parseRounding(%q): %v", value, err)
+		}
+		if got := rule.String(); got != want {
+			t.Errorf("parseRounding(%q) = %s, want %s", value, got, want)
+		}
+	}
+
+	for _, value := range []string{"15", "sideways:15m", "up:30s", "up:25h", "up:"} {
+		if _, err := parseRounding(value); err == nil {
+			t.Errorf("parseRounding(%q) succeeded", value)
+		}
+	}
+}
+
+func TestImportProjectClients(t *testing.T) {
+	clients, err := parseProjectClients("Internal=Eigen beheer, support = Initech")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The mapping sets clients that are missing and replaces those in the
+	// export, whatever the case of the project
+	entries, _ := loadTestExport(t, "toggl.csv", ImportOptions{Clients: clients})
+	want := "" +
+		"2026-09-01|Acme|Backend|API review; Deploy|4.45\n" +
+		"2026-09-01|Initech|Support|Tickets|1.17\n" +
+		"2026-09-02|Eigen beheer|Internal|Planning|0.83\n" +
+		"2026-09-02|Acme|Backend|API review|6\n"
+	if got := formatEntries(entries); got != want {
+		t.Errorf("entries:\n%s\nwant:\n%s", got, want)
+	}
+
+	// A client filter applies after the mapping
+	entries, _ = loadTestExport(t, "toggl.csv", ImportOptions{Clients: clients, Client: "initech"})
+	if got, want := formatEntries(entries), "2026-09-01|Initech|Support|Tickets|1.17\n"; got != want {
+		t.Errorf("entries of initech:\n%s\nwant:\n%s", got, want)
+	}
+
+	for _, value := range []string{"internal", "=Acme", "backend="} {
+		if _, err := parseProjectClients(value); err == nil {
+			t.Errorf("parseProjectClients(%q) succeeded", value)
+		}
+	}
+}
+
+func TestParseTrackerCSVErrors(t *testing.T) {
+	header := []string{"Start date", "Duration"}
+	for _, tc := range []struct {
+		record []string
+		want   string
+	}{
+		{[]string{"2026-31-09", "01:00:00"}, `line 2: invalid date "2026-31-09"`},
+		{[]string{"2026-09-01", "1:75:00"}, `line 2: invalid duration "1:75:00"`},
+	} {
+		_, err := parseTrackerCSV(trackerToggl, header, [][]string{tc.record})
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("err = %v, want %s", err, tc.want)
+		}
+	}
+
+	if _, err := parseTrackerCSV(trackerHarvest, header, nil); err == nil {
+		t.Error("Toggl header parsed as a Harvest export")
+	}
+}
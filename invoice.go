@@ -0,0 +1,1008 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// ============================================================================
+// Invoices
+// ============================================================================
+
+// InvoiceConfig holds the details invoices are made with
+type InvoiceConfig struct {
+	Rate   string `json:"rate,omitempty"`   // hourly rate in euros, excluding VAT
+	VAT    string `json:"vat,omitempty"`    // VAT percentage, 21 when empty
+	Terms  string `json:"terms,omitempty"`  // payment terms in days, 30 when empty
+	Prefix string `json:"prefix,omitempty"` // of invoice numbers; {year} is replaced
+
+	// The business sending the invoice; Company falls back to company_name
+	// and the employee name. Address lines are separated by commas.
+	Company string `json:"company,omitempty"`
+	Address string `json:"address,omitempty"`
+	KvK     string `json:"kvk,omitempty"`
+	BTW     string `json:"btw,omitempty"`
+	IBAN    string `json:"iban,omitempty"`
+
+	// The client the invoice is for, and their reference such as an order
+	// number
+	Client        string `json:"client,omitempty"`
+	ClientAddress string `json:"client_address,omitempty"`
+	ClientKvK     string `json:"client_kvk,omitempty"`
+	ClientBTW     string `json:"client_btw,omitempty"`
+	Reference     string `json:"reference,omitempty"`
+
+	// UBL is "on" to write a UBL e-invoice next to every invoice PDF
+	UBL string `json:"ubl,omitempty"`
+}
+
+// invoice returns the invoice settings of cfg, creating them if necessary
+func (cfg *Config) invoice() *InvoiceConfig {
+	if cfg.Invoice == nil {
+		cfg.Invoice = &InvoiceConfig{}
+	}
+	return cfg.Invoice
+}
+
+// Invoice defaults
+const (
+	defaultVAT           = 21
+	defaultPaymentTerms  = 30
+	defaultInvoicePrefix = "{year}-"
+)
+
+func (c InvoiceConfig) vat() float64 {
+	if vat, ok := parsePercent(c.VAT); ok {
+		return vat
+	}
+	return defaultVAT
+}
+
+func (c InvoiceConfig) terms() int {
+	if days, err := strconv.Atoi(c.Terms); err == nil {
+		return days
+	}
+	return defaultPaymentTerms
+}
+
+func (c InvoiceConfig) prefix() string {
+	if c.Prefix == "" {
+		return defaultInvoicePrefix
+	}
+	return c.Prefix
+}
+
+// invoiceCompany returns the name of the business sending invoices
+func (cfg Config) invoiceCompany() string {
+	switch {
+	case cfg.Invoice != nil && cfg.Invoice.Company != "":
+		return cfg.Invoice.Company
+	case cfg.CompanyName != "":
+		return cfg.CompanyName
+	}
+	return cfg.EmployeeName
+}
+
+// addressLines splits a comma separated address into its lines
+func addressLines(address string) []string {
+	var lines []string
+	for _, line := range strings.Split(address, ",") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// ----------------------------------------------------------------------------
+// Amounts and Numbers
+// ----------------------------------------------------------------------------
+
+// money is an amount in euro cents
+type money int64
+
+// moneyPattern matches 85, 85,50, 1.250,00, 1250.5 and € 85
+var moneyPattern = regexp.MustCompile(`^(?:€\s*)?(\d{1,3}(?:[.,]\d{3})*|\d+)(?:[.,](\d{1,2}))?$`)
+
+// parseMoney parses an amount in euros. A separator followed by three digits
+// groups thousands; one followed by one or two digits starts the cents.
+func parseMoney(s string) (money, bool) {
+	m := moneyPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, false
+	}
+	euros, err := strconv.ParseInt(strings.NewReplacer(".", "", ",", "").Replace(m[1]), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	cents := int64(0)
+	if m[2] != "" {
+		cents, _ = strconv.ParseInt((m[2] + "0")[:2], 10, 64)
+	}
+	return money(euros*100 + cents), true
+}
+
+// times returns the amount for a quantity at this price, rounded to cents
+func (m money) times(quantity float64) money {
+	return money(math.Round(float64(m) * quantity))
+}
+
+// percent returns p percent of the amount, rounded to cents
+func (m money) percent(p float64) money {
+	return money(math.Round(float64(m) * p / 100))
+}
+
+// decimal returns the amount in euros with two decimals, as in 1234.50
+func (m money) decimal() string {
+	sign := ""
+	if m < 0 {
+		sign, m = "-", -m
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, m/100, m%100)
+}
+
+// format returns the amount as written in an invoice, as in € 1.234,50 or
+// €1,234.50
+func (m money) format(lang string) string {
+	sign := ""
+	if m < 0 {
+		sign, m = "-", -m
+	}
+	euros := strconv.FormatInt(int64(m/100), 10)
+	thousands, decimal, prefix := ".", ",", "€ "
+	if lang == "en" {
+		thousands, decimal, prefix = ",", ".", "€"
+	}
+	for i := len(euros) - 3; i > 0; i -= 3 {
+		euros = euros[:i] + thousands + euros[i:]
+	}
+	return fmt.Sprintf("%s%s%s%s%02d", sign, prefix, euros, decimal, m%100)
+}
+
+func (m money) MarshalJSON() ([]byte, error) {
+	return []byte(m.decimal()), nil
+}
+
+func (m *money) UnmarshalJSON(data []byte) error {
+	f, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return fmt.Errorf("invalid amount %s", data)
+	}
+	*m = money(math.Round(f * 100))
+	return nil
+}
+
+func validateRate(value string) error {
+	if rate, ok := parseMoney(value); !ok || rate <= 0 {
+		return fmt.Errorf("invalid hourly rate %q (euros, e.g. 85 or 92,50)", value)
+	}
+	return nil
+}
+
+func validateVAT(value string) error {
+	if vat, ok := parsePercent(value); !ok || vat > 100 {
+		return fmt.Errorf("invalid VAT percentage %q (e.g. 21, 9 or 0)", value)
+	}
+	return nil
+}
+
+func validateTerms(value string) error {
+	if days, err := strconv.Atoi(value); err != nil || days < 0 || days > 365 {
+		return fmt.Errorf("invalid payment terms %q (days, e.g. 14 or 30)", value)
+	}
+	return nil
+}
+
+func validateKvK(value string) error {
+	if !regexp.MustCompile(`^\d{8}$`).MatchString(value) {
+		return fmt.Errorf("invalid KvK number %q (8 digits)", value)
+	}
+	return nil
+}
+
+// normalizeVATNumber removes the spaces and dots from a VAT number
+func normalizeVATNumber(value string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", ".", "").Replace(value))
+}
+
+func validateBTW(value string) error {
+	v := normalizeVATNumber(value)
+	if strings.HasPrefix(v, "NL") {
+		if !regexp.MustCompile(`^NL\d{9}B\d{2}$`).MatchString(v) {
+			return fmt.Errorf("invalid BTW number %q (e.g. NL123456789B01)", value)
+		}
+		return nil
+	}
+	if !regexp.MustCompile(`^[A-Z]{2}[0-9A-Z]{2,13}$`).MatchString(v) {
+		return fmt.Errorf("invalid VAT number %q (country code and number, e.g. NL123456789B01)", value)
+	}
+	return nil
+}
+
+// normalizeIBAN removes the spaces from an IBAN
+func normalizeIBAN(value string) string {
+	return strings.ToUpper(strings.ReplaceAll(value, " ", ""))
+}
+
+// validateIBAN checks the format and the check digits of an IBAN
+func validateIBAN(value string) error {
+	iban := normalizeIBAN(value)
+	if !regexp.MustCompile(`^[A-Z]{2}\d{2}[A-Z0-9]{11,30}$`).MatchString(iban) {
+		return fmt.Errorf("invalid IBAN %q (e.g. NL91ABNA0417164300)", value)
+	}
+	var digits strings.Builder
+	for _, r := range iban[4:] + iban[:4] {
+		if r >= 'A' && r <= 'Z' {
+			digits.WriteString(strconv.Itoa(int(r-'A') + 10))
+		} else {
+			digits.WriteRune(r)
+		}
+	}
+	n, _ := new(big.Int).SetString(digits.String(), 10)
+	if new(big.Int).Mod(n, big.NewInt(97)).Int64() != 1 {
+		return fmt.Errorf("invalid IBAN %q (check digits do not match)", value)
+	}
+	return nil
+}
+
+// formatIBAN writes an IBAN in groups of four
+func formatIBAN(iban string) string {
+	iban = normalizeIBAN(iban)
+	var groups []string
+	for len(iban) > 4 {
+		groups = append(groups, iban[:4])
+		iban = iban[4:]
+	}
+	return strings.Join(append(groups, iban), " ")
+}
+
+// ----------------------------------------------------------------------------
+// Invoice Ledger
+// ----------------------------------------------------------------------------
+
+// InvoiceRecord is one line of the invoice ledger
+type InvoiceRecord struct {
+	Number          string    `json:"number"`
+	Date            string    `json:"date"`     // yyyy-mm-dd
+	DueDate         string    `json:"due_date"` // yyyy-mm-dd
+	Period          string    `json:"period"`
+	Client          string    `json:"client"`
+	Hours           float64   `json:"hours"`
+	Subtotal        money     `json:"subtotal"`
+	VAT             money     `json:"vat"`
+	Total           money     `json:"total"`
+	OutputPath      string    `json:"output_path"`
+	TimesheetPath   string    `json:"timesheet_path"`
+	TimesheetSHA256 string    `json:"timesheet_sha256"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+// InvoicePath returns the path of the invoice ledger
+func InvoicePath() string {
+	configPath := ConfigPath()
+	if configPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPath), "invoices.jsonl")
+}
+
+// LoadInvoices reads the invoice ledger. Lines that cannot be parsed are
+// skipped.
+func LoadInvoices() ([]InvoiceRecord, error) {
+	path := InvoicePath()
+	if path == "" {
+		return nil, fmt.Errorf("could not determine invoice ledger path")
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open invoice ledger: %w", err)
+	}
+	defer f.Close()
+
+	var records []InvoiceRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r InvoiceRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err == nil && r.Number != "" {
+			records = append(records, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return records, fmt.Errorf("failed to read invoice ledger: %w", err)
+	}
+	return records, nil
+}
+
+// openInvoiceLedger opens the invoice ledger for appending records, creating
+// it if necessary
+func openInvoiceLedger() (*os.File, error) {
+	path := InvoicePath()
+	if path == "" {
+		return nil, fmt.Errorf("could not determine invoice ledger path")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create config directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open invoice ledger: %w", err)
+	}
+	return f, nil
+}
+
+// appendInvoice adds a record to the invoice ledger opened with
+// openInvoiceLedger and syncs it, so the number is not handed out again
+func appendInvoice(ledger *os.File, r InvoiceRecord) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to marshal invoice record: %w", err)
+	}
+	if _, err := ledger.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write invoice ledger: %w", err)
+	}
+	if err := ledger.Sync(); err != nil {
+		return fmt.Errorf("failed to write invoice ledger: %w", err)
+	}
+	return nil
+}
+
+// ErrInvoiceNumberUsed is returned for an invoice number already in the ledger
+var ErrInvoiceNumberUsed = errors.New("invoice number already used")
+
+// nextInvoiceNumber returns the number after the highest one in records with
+// the same prefix, starting at 1. {year} in the prefix is the year of date.
+func nextInvoiceNumber(records []InvoiceRecord, prefix string, date time.Time) string {
+	prefix = strings.ReplaceAll(prefix, "{year}", strconv.Itoa(date.Year()))
+	last := 0
+	for _, r := range records {
+		if seq, err := strconv.Atoi(strings.TrimPrefix(r.Number, prefix)); err == nil && strings.HasPrefix(r.Number, prefix) && seq > last {
+			last = seq
+		}
+	}
+	return fmt.Sprintf("%s%04d", prefix, last+1)
+}
+
+// ----------------------------------------------------------------------------
+// Invoice Content
+// ----------------------------------------------------------------------------
+
+// invoiceLine is a line of an invoice: the hours of a project at the rate
+type invoiceLine struct {
+	Description string  `json:"description"`
+	Hours       float64 `json:"hours"`
+	Rate        money   `json:"rate"`
+	Amount      money   `json:"amount"`
+}
+
+// Invoice is the content of an invoice
+type Invoice struct {
+	Number    string        `json:"number"`
+	Date      time.Time     `json:"-"`
+	DueDate   time.Time     `json:"-"`
+	Period    string        `json:"period"`
+	Reference string        `json:"reference,omitempty"`
+	Lines     []invoiceLine `json:"lines"`
+	VATRate   float64       `json:"vat_rate"`
+	Subtotal  money         `json:"subtotal"`
+	VAT       money         `json:"vat"`
+	Total     money         `json:"total"`
+
+	Seller  invoiceParty `json:"seller"`
+	Buyer   invoiceParty `json:"buyer"`
+	Terms   int          `json:"payment_terms"`
+	Lang    string       `json:"-"`
+	LogoURL string       `json:"-"`
+}
+
+// invoiceParty is the seller or the buyer of an invoice
+type invoiceParty struct {
+	Name    string   `json:"name"`
+	Address []string `json:"address,omitempty"`
+	KvK     string   `json:"kvk,omitempty"`
+	BTW     string   `json:"btw,omitempty"`
+	IBAN    string   `json:"iban,omitempty"`
+}
+
+// projectHours adds up hours per project, in order of project name; hours
+// without a project come first
+func projectHours(add func(func(project string, hours float64))) ([]string, map[string]float64) {
+	hours := map[string]float64{}
+	add(func(project string, h float64) { hours[project] += h })
+	projects := make([]string, 0, len(hours))
+	for p := range hours {
+		projects = append(projects, p)
+	}
+	sort.Strings(projects)
+	return projects, hours
+}
+
+// invoiceLines makes a line per project at the rate, described as the work
+// of the period
+func invoiceLines(projects []string, hours map[string]float64, rate money, period, lang string) []invoiceLine {
+	month, _ := time.Parse("2006-01", period)
+	periodName := fmt.Sprintf("%s %d", monthNames[lang][month.Month()-1], month.Year())
+	var lines []invoiceLine
+	for _, p := range projects {
+		h := math.Round(hours[p]*100) / 100
+		if h == 0 {
+			continue
+		}
+		desc := fmt.Sprintf(invoiceLabels[lang]["work"], periodName)
+		if p != "" {
+			desc = p + ", " + strings.ToLower(desc[:1]) + desc[1:]
+		}
+		lines = append(lines, invoiceLine{Description: desc, Hours: h, Rate: rate, Amount: rate.times(h)})
+	}
+	return lines
+}
+
+// totals computes the subtotal, VAT and total of the lines
+func (inv *Invoice) totals() {
+	inv.Subtotal = 0
+	for _, l := range inv.Lines {
+		inv.Subtotal += l.Amount
+	}
+	inv.VAT = inv.Subtotal.percent(inv.VATRate)
+	inv.Total = inv.Subtotal + inv.VAT
+}
+
+func (inv *Invoice) hours() float64 {
+	sum := 0.0
+	for _, l := range inv.Lines {
+		sum += l.Hours
+	}
+	return math.Round(sum*100) / 100
+}
+
+// invoiceOutputName returns the name of the PDF of an invoice
+func invoiceOutputName(number string) string {
+	return fmt.Sprintf("Factuur-%s.pdf", number)
+}
+
+// ----------------------------------------------------------------------------
+// Invoice PDF
+// ----------------------------------------------------------------------------
+
+// invoiceLabels are the texts of an invoice per language
+var invoiceLabels = map[string]map[string]string{
+	"nl": {
+		"title": "Factuur", "to": "Aan", "number": "Factuurnummer", "date": "Factuurdatum", "due": "Vervaldatum",
+		"period": "Periode", "reference": "Uw referentie", "description": "Omschrijving", "hours": "Uren", "rate": "Tarief",
+		"amount": "Bedrag", "subtotal": "Subtotaal", "vat": "BTW %s%%", "total": "Totaal", "kvk": "KvK", "btw": "BTW",
+		"iban": "IBAN", "work": "Werkzaamheden %s",
+		"payment":   "Wij verzoeken u het totaalbedrag van %s uiterlijk %s over te maken op %s t.n.v. %s, onder vermelding van factuurnummer %s.",
+		"appendix":  "Bijlage: getekende urenstaat %s",
+		"timesheet": "Getekende urenstaat %s", "terms": "Betaling binnen %d dagen", "exempt": "Vrijgesteld van BTW",
+	},
+	"en": {
+		"title": "Invoice", "to": "To", "number": "Invoice number", "date": "Invoice date", "due": "Due date",
+		"period": "Period", "reference": "Your reference", "description": "Description", "hours": "Hours", "rate": "Rate",
+		"amount": "Amount", "subtotal": "Subtotal", "vat": "VAT %s%%", "total": "Total", "kvk": "CoC", "btw": "VAT",
+		"iban": "IBAN", "work": "Work %s",
+		"payment":   "Please pay the total of %s by %s to %s in the name of %s, stating invoice number %s.",
+		"appendix":  "Appendix: signed timesheet %s",
+		"timesheet": "Signed timesheet %s", "terms": "Payment within %d days", "exempt": "Exempt from VAT",
+	},
+}
+
+// Column positions of the invoice lines, right aligned except the description
+const (
+	invoiceHoursRight  = 350
+	invoiceRateRight   = 445
+	invoiceAmountRight = sheetHoursRight
+	invoiceRowHeight   = 14
+)
+
+// wrapSheetText splits s into lines of at most width points in the sheet font
+func wrapSheetText(s string, width, size float64) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		if line != "" && sheetTextWidth(line+" "+word, false, size) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// invoicePDF lays out an invoice on A4 pages
+func invoicePDF(inv *Invoice, logoPath string) ([]byte, error) {
+	lang := inv.Lang
+	labels := invoiceLabels[lang]
+	var pages []*sheetPage
+	newPage := func() *sheetPage {
+		p := &sheetPage{}
+		pages = append(pages, p)
+		return p
+	}
+	page := newPage()
+
+	// The seller heads the page on the right, below the logo if there is one
+	page.text(sheetMargin, 800, true, 18, labels["title"])
+	y := 800.0
+	if logoPath != "" {
+		y = 740
+	}
+	page.textRight(sheetHoursRight, y, true, 12, inv.Seller.Name)
+	y -= 14
+	seller := append([]string{}, inv.Seller.Address...)
+	if inv.Seller.KvK != "" {
+		seller = append(seller, labels["kvk"]+": "+inv.Seller.KvK)
+	}
+	if inv.Seller.BTW != "" {
+		seller = append(seller, labels["btw"]+": "+inv.Seller.BTW)
+	}
+	if inv.Seller.IBAN != "" {
+		seller = append(seller, labels["iban"]+": "+formatIBAN(inv.Seller.IBAN))
+	}
+	for _, line := range seller {
+		page.textRight(sheetHoursRight, y, false, 9, line)
+		y -= 11
+	}
+
+	// The client and the invoice details on the left
+	y = min(y-10, 700)
+	page.gray = 0.45
+	page.text(sheetMargin, y, false, 8, labels["to"])
+	page.gray = 0
+	y -= 13
+	page.text(sheetMargin, y, true, 10, inv.Buyer.Name)
+	for _, line := range inv.Buyer.Address {
+		y -= 12
+		page.text(sheetMargin, y, false, 10, line)
+	}
+	if inv.Buyer.BTW != "" {
+		y -= 12
+		page.text(sheetMargin, y, false, 10, labels["btw"]+": "+inv.Buyer.BTW)
+	}
+
+	month, _ := time.Parse("2006-01", inv.Period)
+	details := [][2]string{
+		{labels["number"], inv.Number},
+		{labels["date"], inv.Date.Format("02-01-2006")},
+		{labels["due"], inv.DueDate.Format("02-01-2006")},
+		{labels["period"], fmt.Sprintf("%s %d", monthNames[lang][month.Month()-1], month.Year())},
+	}
+	if inv.Reference != "" {
+		details = append(details, [2]string{labels["reference"], inv.Reference})
+	}
+	y -= 30
+	for _, d := range details {
+		page.text(sheetMargin, y, true, 9, d[0]+":")
+		page.text(sheetMargin+90, y, false, 9, d[1])
+		y -= 12
+	}
+
+	header := func(p *sheetPage, y float64) {
+		p.text(sheetMargin, y, true, 9, labels["description"])
+		p.textRight(invoiceHoursRight, y, true, 9, labels["hours"])
+		p.textRight(invoiceRateRight, y, true, 9, labels["rate"])
+		p.textRight(invoiceAmountRight, y, true, 9, labels["amount"])
+		p.line(sheetMargin, y-4, sheetHoursRight, y-4, 0.5)
+	}
+	y -= 20
+	header(page, y)
+	y -= invoiceRowHeight + 4
+	for _, l := range inv.Lines {
+		if y < 200 {
+			page = newPage()
+			y = 790
+			header(page, y)
+			y -= invoiceRowHeight + 4
+		}
+		page.text(sheetMargin, y, false, 9, fitText(l.Description, invoiceHoursRight-sheetMargin-60))
+		page.textRight(invoiceHoursRight, y, false, 9, formatHours(l.Hours, lang))
+		page.textRight(invoiceRateRight, y, false, 9, l.Rate.format(lang))
+		page.textRight(invoiceAmountRight, y, false, 9, l.Amount.format(lang))
+		y -= invoiceRowHeight
+	}
+
+	page.line(invoiceRateRight-95, y+invoiceRowHeight-4, sheetHoursRight, y+invoiceRowHeight-4, 0.5)
+	y -= 4
+	vat := strconv.FormatFloat(inv.VATRate, 'f', -1, 64)
+	if lang == "nl" {
+		vat = strings.ReplaceAll(vat, ".", ",")
+	}
+	for _, t := range []struct {
+		label  string
+		amount money
+		bold   bool
+	}{
+		{labels["subtotal"], inv.Subtotal, false},
+		{fmt.Sprintf(labels["vat"], vat), inv.VAT, false},
+		{labels["total"], inv.Total, true},
+	} {
+		page.text(invoiceRateRight-95, y, t.bold, 10, t.label)
+		page.textRight(invoiceAmountRight, y, t.bold, 10, t.amount.format(lang))
+		y -= invoiceRowHeight
+	}
+
+	// Payment instructions and the appendix
+	y -= 20
+	if inv.Seller.IBAN != "" {
+		payment := fmt.Sprintf(labels["payment"], inv.Total.format(lang), inv.DueDate.Format("02-01-2006"), formatIBAN(inv.Seller.IBAN), inv.Seller.Name, inv.Number)
+		for _, line := range wrapSheetText(payment, sheetHoursRight-sheetMargin, 9) {
+			page.text(sheetMargin, y, false, 9, line)
+			y -= 12
+		}
+		y -= 8
+	}
+	page.gray = 0.45
+	page.text(sheetMargin, y, false, 9, fmt.Sprintf(labels["appendix"], inv.Period))
+
+	info := map[string]string{
+		"Title":    labels["title"] + " " + inv.Number,
+		"Author":   inv.Seller.Name,
+		"Subject":  inv.Buyer.Name,
+		"Creator":  "hours-signer",
+		"Producer": "hours-signer " + version,
+	}
+	data := writeSheetPDF(pages, info)
+	if logoPath != "" {
+		var err error
+		if data, err = stampLogo(data, logoPath); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// appendTimesheet appends the pages of the signed timesheet to the invoice
+// and embeds the signed file itself, so its signature marker is kept
+func appendTimesheet(invoice, timesheet []byte, name string) ([]byte, error) {
+	conf := pdfmodel.NewDefaultConfiguration()
+	var merged bytes.Buffer
+	if err := api.MergeRaw([]io.ReadSeeker{bytes.NewReader(invoice), bytes.NewReader(timesheet)}, &merged, false, conf); err != nil {
+		return nil, fmt.Errorf("failed to append the timesheet: %w", err)
+	}
+	return attachFile(merged.Bytes(), name, "Signed timesheet", timesheet, conf)
+}
+
+// ----------------------------------------------------------------------------
+// Command
+// ----------------------------------------------------------------------------
+
+// InvoiceResult describes a created invoice
+type InvoiceResult struct {
+	*Invoice
+	Date     string   `json:"date"`     // yyyy-mm-dd
+	DueDate  string   `json:"due_date"` // yyyy-mm-dd
+	Output   string   `json:"output"`
+	UBL      string   `json:"ubl,omitempty"` // path of the UBL e-invoice
+	Hours    float64  `json:"hours"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+func runInvoice(args []string) int {
+	cfg := LoadConfig()
+	ic := cfg.invoice()
+
+	fs := newFlagSet("invoice", "[flags] <signed timesheet.pdf> [hours file]", "Create a numbered invoice for the hours of a signed timesheet, with the timesheet\nappended. The hours are read from the timesheet, or from an hours file in any\nformat generate reads.")
+	output := fs.String("output", "", "Output PDF file (default: Factuur-<number>.pdf)")
+	period := fs.String("period", "", "Month to invoice as yyyy-mm (default: the month of the timesheet)")
+	number := fs.String("number", "", "Invoice number (default: the next in the sequence)")
+	date := fs.String("date", "", "Invoice date as dd-mm-yyyy (default: today)")
+	rate := fs.String("rate", ic.Rate, "Hourly rate in euros, excluding VAT")
+	client := fs.String("to", ic.Client, "Name of the client the invoice is for")
+	reference := fs.String("reference", ic.Reference, "The client's reference, such as an order number")
+	lang := fs.String("lang", cfg.language(), "Language of the invoice: nl or en")
+	ubl := fs.Bool("ubl", ic.UBL == checkOn, "Also write a UBL e-invoice (NLCIUS), next to the PDF as .xml")
+	source := addHoursSourceFlags(fs, cfg)
+	force := fs.Bool("force", false, "Invoice an unsigned timesheet and overwrite an existing output file")
+	outputMode := fs.String("mode", "", "Permissions for a new output file, e.g. 0600 (default: from config or 0644)")
+	jsonOut := fs.Bool("json", false, "Print the result as JSON")
+	positional, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+	if len(positional) < 1 || len(positional) > 2 {
+		fmt.Fprintln(os.Stderr, "Error: a signed timesheet PDF and optionally an hours file are required")
+		fs.Usage()
+		return exitUsage
+	}
+	if *period != "" {
+		if err := validatePeriod(*period); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -period %v\n", err)
+			return exitUsage
+		}
+	}
+	invoiceDate := time.Now()
+	if *date != "" {
+		d, err := parseSigningDate(*date)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -date %v\n", err)
+			return exitUsage
+		}
+		invoiceDate, _ = time.Parse("02-01-2006", d)
+	}
+	invoiceDate = civilDate(invoiceDate.Year(), invoiceDate.Month(), invoiceDate.Day())
+	if err := validateLanguage(*lang); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -lang %v\n", err)
+		return exitUsage
+	}
+	if *rate == "" {
+		return fail(*jsonOut, signError(KindConfig, ConfigPath(), fmt.Errorf("no hourly rate (set it with hours-signer config set invoice_rate <euros>, or use -rate)")))
+	}
+	if err := validateRate(*rate); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -rate %v\n", err)
+		return exitUsage
+	}
+	hourlyRate, _ := parseMoney(*rate)
+	if *client == "" {
+		return fail(*jsonOut, signError(KindConfig, ConfigPath(), fmt.Errorf("no client (set it with hours-signer config set invoice_client <name>, or use -to)")))
+	}
+	mode := os.FileMode(0)
+	if *outputMode != "" {
+		m, err := parseFileMode(*outputMode)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitUsage
+		}
+		mode = m
+	} else if cfg.OutputMode != "" {
+		mode, _ = parseFileMode(cfg.OutputMode)
+	}
+
+	input := positional[0]
+	if !*force && !isPDFSigned(input) {
+		return fail(*jsonOut, signError(KindInputPDF, input, fmt.Errorf("%s is not signed (sign it first, or use -force)", input)))
+	}
+	timesheet, err := os.ReadFile(input)
+	if err != nil {
+		return fail(*jsonOut, signError(KindInputPDF, input, fmt.Errorf("failed to read input file: %w", err)))
+	}
+	ctx, err := api.ReadContextFile(input)
+	if err != nil {
+		return fail(*jsonOut, signError(KindInputPDF, input, fmt.Errorf("failed to read PDF context: %w", err)))
+	}
+	table, tableErr := extractTable(ctx)
+	if *period == "" && tableErr == nil {
+		*period = tablePeriod(table)
+	}
+
+	// The hours per project, from the hours file or else the timesheet
+	var projects []string
+	var hours map[string]float64
+	var warnings []string
+	if len(positional) == 2 {
+		importOpts, code, ok := source.options(cfg, *period, *jsonOut)
+		if !ok {
+			return code
+		}
+		entries, _, err := loadHours(positional[1], *source.from, importOpts)
+		if err != nil {
+			return fail(*jsonOut, err)
+		}
+		if *period == "" {
+			if *period, err = entriesPeriod(entries); err != nil {
+				return fail(*jsonOut, signError(KindHoursData, positional[1], err))
+			}
+		}
+		entries, _ = entriesIn(entries, *period)
+		projects, hours = projectHours(func(add func(string, float64)) {
+			for _, e := range entries {
+				add(e.Project, e.Hours)
+			}
+		})
+		if tableErr == nil {
+			fileTotal, pdfTotal := sumHours(entryDays(entries), *period), sumHours(tableDays(table), *period)
+			if !sameHours(fileTotal, pdfTotal) {
+				warnings = append(warnings, fmt.Sprintf("%s has %s hours in %s, the timesheet %s", positional[1], formatHours(fileTotal, "en"), *period, formatHours(pdfTotal, "en")))
+			}
+		}
+	} else {
+		if tableErr != nil {
+			return fail(*jsonOut, signError(KindInputPDF, input, fmt.Errorf("%w (give the hours file to invoice instead)", tableErr)))
+		}
+		if *period == "" {
+			return fail(*jsonOut, signError(KindInputPDF, input, fmt.Errorf("no dated rows in the hours table (use -period)")))
+		}
+		projects, hours = projectHours(func(add func(string, float64)) {
+			for _, r := range table.Rows {
+				if r.Hours != nil && strings.HasPrefix(r.Date, *period+"-") {
+					add(r.Project, *r.Hours)
+				}
+			}
+		})
+	}
+
+	inv := &Invoice{
+		Date:      invoiceDate,
+		DueDate:   invoiceDate.AddDate(0, 0, ic.terms()),
+		Period:    *period,
+		Reference: *reference,
+		Lines:     invoiceLines(projects, hours, hourlyRate, *period, *lang),
+		VATRate:   ic.vat(),
+		Seller: invoiceParty{
+			Name:    cfg.invoiceCompany(),
+			Address: addressLines(ic.Address),
+			KvK:     ic.KvK,
+			BTW:     normalizeVATNumber(ic.BTW),
+			IBAN:    normalizeIBAN(ic.IBAN),
+		},
+		Buyer: invoiceParty{
+			Name:    *client,
+			Address: addressLines(ic.ClientAddress),
+			KvK:     ic.ClientKvK,
+			BTW:     normalizeVATNumber(ic.ClientBTW),
+		},
+		Terms: ic.terms(),
+		Lang:  *lang,
+	}
+	if len(inv.Lines) == 0 {
+		return fail(*jsonOut, signError(KindHoursData, input, fmt.Errorf("no hours in %s to invoice", *period)))
+	}
+	inv.totals()
+	if *ubl {
+		if missing := missingUBLData(inv); len(missing) > 0 {
+			return fail(*jsonOut, signError(KindConfig, ConfigPath(), fmt.Errorf("a UBL invoice needs complete values for %s (set them with hours-signer config set, or the reference with -reference)", strings.Join(missing, ", "))))
+		}
+	}
+
+	// Hold the ledger lock from choosing the number until it is recorded
+	unlock, err := lockOutput(InvoicePath())
+	if err != nil {
+		return fail(*jsonOut, signError(KindOutputWrite, InvoicePath(), fmt.Errorf("failed to lock invoice ledger: %w", err)))
+	}
+	defer unlock()
+	records, err := LoadInvoices()
+	if err != nil {
+		return fail(*jsonOut, signError(KindConfig, InvoicePath(), err))
+	}
+	inv.Number = *number
+	if inv.Number == "" {
+		inv.Number = nextInvoiceNumber(records, ic.prefix(), invoiceDate)
+	}
+	for _, r := range records {
+		if r.Number == inv.Number {
+			return fail(*jsonOut, signError(KindConfig, InvoicePath(), fmt.Errorf("%w: %s (invoice %s of %s)", ErrInvoiceNumberUsed, inv.Number, r.Period, r.Client)))
+		}
+		if r.Period == inv.Period && r.Client == inv.Buyer.Name {
+			warnings = append(warnings, fmt.Sprintf("%s for %s was invoiced before, as %s", inv.Period, inv.Buyer.Name, r.Number))
+		}
+	}
+	if *output == "" {
+		*output = invoiceOutputName(inv.Number)
+	}
+
+	data, err := invoicePDF(inv, cfg.LogoPath)
+	if err != nil {
+		return fail(*jsonOut, err)
+	}
+	if data, err = appendTimesheet(data, timesheet, filepath.Base(input)); err != nil {
+		return fail(*jsonOut, signError(KindInputPDF, input, err))
+	}
+	var ublData []byte
+	ublOutput := ""
+	if *ubl {
+		if ublData, err = ublXML(inv, timesheet, filepath.Base(input)); err != nil {
+			return fail(*jsonOut, err)
+		}
+		ublOutput = ublOutputName(*output)
+	}
+
+	unlockOutput, err := lockOutput(*output)
+	if err != nil {
+		return fail(*jsonOut, signError(KindOutputWrite, *output, fmt.Errorf("failed to lock output file: %w", err)))
+	}
+	defer unlockOutput()
+	for _, path := range []string{*output, ublOutput} {
+		if path != "" && !*force && fileExists(path) {
+			return fail(*jsonOut, signError(KindOutputExists, path, fmt.Errorf("%w: %s (use -force to overwrite)", ErrOutputExists, path)))
+		}
+	}
+
+	// The outputs are written to temp files and only moved into place once
+	// the number is recorded, so no invoice carries a number the next run
+	// hands out again, and a failure leaves files overwritten with -force as
+	// they were
+	ledger, err := openInvoiceLedger()
+	if err != nil {
+		return fail(*jsonOut, signError(KindOutputWrite, InvoicePath(), err))
+	}
+	defer ledger.Close()
+	staged := map[string]string{}
+	defer func() {
+		// No-op for the files moved into place
+		for _, tmpName := range staged {
+			os.Remove(tmpName)
+		}
+	}()
+	outputs := []struct {
+		path, what string
+		data       []byte
+	}{{*output, "output file", data}, {ublOutput, "UBL invoice", ublData}}
+	for _, o := range outputs {
+		if o.path == "" {
+			continue
+		}
+		tmpName, err := stageFile(o.path, o.data, outputFileMode(o.path, mode))
+		if err != nil {
+			return fail(*jsonOut, signError(KindOutputWrite, o.path, fmt.Errorf("failed to write %s: %w", o.what, err)))
+		}
+		staged[o.path] = tmpName
+	}
+
+	result := &InvoiceResult{
+		Invoice:  inv,
+		Date:     inv.Date.Format("2006-01-02"),
+		DueDate:  inv.DueDate.Format("2006-01-02"),
+		Output:   *output,
+		UBL:      ublOutput,
+		Hours:    inv.hours(),
+		Warnings: warnings,
+	}
+	if err := appendInvoice(ledger, InvoiceRecord{
+		Number:          inv.Number,
+		Date:            result.Date,
+		DueDate:         result.DueDate,
+		Period:          inv.Period,
+		Client:          inv.Buyer.Name,
+		Hours:           result.Hours,
+		Subtotal:        inv.Subtotal,
+		VAT:             inv.VAT,
+		Total:           inv.Total,
+		OutputPath:      absPath(*output),
+		TimesheetPath:   absPath(input),
+		TimesheetSHA256: sha256Hex(timesheet),
+		Timestamp:       time.Now(),
+	}); err != nil {
+		return fail(*jsonOut, signError(KindOutputWrite, InvoicePath(), fmt.Errorf("invoice %s not recorded, so it was not written: %w", inv.Number, err)))
+	}
+	for _, o := range outputs {
+		if o.path == "" {
+			continue
+		}
+		if err := commitFile(staged[o.path], o.path); err != nil {
+			return fail(*jsonOut, signError(KindOutputWrite, o.path, fmt.Errorf("invoice %s is recorded, but failed to write %s: %w", inv.Number, o.what, err)))
+		}
+		delete(staged, o.path)
+	}
+
+	if *jsonOut {
+		writeJSON(struct {
+			OK bool `json:"ok"`
+			*InvoiceResult
+		}{true, result})
+		return exitOK
+	}
+	fmt.Printf("✓ Created invoice %s: %s\n", inv.Number, *output)
+	if ublOutput != "" {
+		fmt.Printf("  E-invoice: %s\n", ublOutput)
+	}
+	fmt.Printf("  Client: %s\n", inv.Buyer.Name)
+	fmt.Printf("  Period: %s, %s hours\n", inv.Period, formatHours(result.Hours, *lang))
+	fmt.Printf("  Total: %s (%s + %s VAT)\n", inv.Total.format(*lang), inv.Subtotal.format(*lang), inv.VAT.format(*lang))
+	fmt.Printf("  Due: %s\n", inv.DueDate.Format("02-01-2006"))
+	for _, w := range result.Warnings {
+		fmt.Printf("⚠ %s\n", w)
+	}
+	return exitOK
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// ============================================================================
+// Signature Block Layout
+// ============================================================================
+
+const (
+	stampFont     = "Helvetica"
+	stampFontBold = "Helvetica-Bold"
+	stampFontSize = 10
+)
+
+// stampElement is one text line or the signature image of the signature
+// block, positioned by its lower left corner relative to the page's lower
+// left corner
+type stampElement struct {
+	Name  string // used in error messages and reports
+	Text  string
+	Bold  bool
+	Color string // text color as #rrggbb, black if empty
+	Image bool
+	X, Y  float64
+	Scale float64 // image scale factor
+}
+
+func (e stampElement) font() string {
+	if e.Bold {
+		return stampFontBold
+	}
+	return stampFont
+}
+
+const defaultSignatureScale = .35
+
+// Layout moves the whole signature block, resizes the signature image and
+// places extra fields. The zero Layout is the default placement.
+type Layout struct {
+	OffsetX        float64             `json:"offset_x"`
+	OffsetY        float64             `json:"offset_y"`
+	SignatureScale float64             `json:"signature_scale,omitempty"` // 0 for the default
+	Fields         map[string]Position `json:"fields,omitempty"`          // by field key
+}
+
+func (l Layout) isZero() bool {
+	return l.OffsetX == 0 && l.OffsetY == 0 && l.SignatureScale == 0 && len(l.Fields) == 0
+}
+
+func (l Layout) signatureScale() float64 {
+	if l.SignatureScale > 0 {
+		return l.SignatureScale
+	}
+	return defaultSignatureScale
+}
+
+// blockValues are the texts filled into the signature block
+type blockValues struct {
+	Employee, Manager, Date string
+	Language                string // key of blockLabels
+	Fields                  []fieldValue
+	Total                   string // total hours, not stamped if empty
+}
+
+func (v blockValues) label(name string) string {
+	if labels, ok := blockLabels[v.Language]; ok {
+		return labels[name]
+	}
+	return blockLabels[defaultLanguage][name]
+}
+
+// signatureBlock returns the elements signPDF stamps on the last page,
+// placed according to layout
+func signatureBlock(v blockValues, layout Layout) []stampElement {
+	elements := []stampElement{
+		{Name: "employee label", Text: fmt.Sprintf("%s: %s", v.label("employee"), v.Employee), X: 40, Y: 210},
+		{Name: "employee date", Text: fmt.Sprintf("%s: %s", v.label("date"), v.Date), X: 40, Y: 195},
+		{Name: "handtekening label", Text: v.label("signature") + ":", X: 40, Y: 180},
+		{Name: "signature", Image: true, X: 120, Y: 90, Scale: layout.signatureScale()},
+		{Name: "manager label", Text: fmt.Sprintf("%s: %s", v.label("manager"), v.Manager), X: 350, Y: 210},
+		{Name: "manager date", Text: v.label("date") + ":", X: 350, Y: 195},
+		{Name: "manager handtekening label", Text: v.label("signature") + ":", X: 350, Y: 180},
+	}
+	for i, f := range v.Fields {
+		pos, ok := layout.Fields[f.Key]
+		if !ok {
+			pos = defaultFieldPosition(i)
+		}
+		elements = append(elements, stampElement{Name: "field " + f.Key, Text: fmt.Sprintf("%s: %s", f.Label, f.Value), X: pos.X, Y: pos.Y})
+	}
+	if v.Total != "" {
+		// The total goes above the extra fields unless placed like one
+		pos, ok := layout.Fields[totalFieldKey]
+		if !ok {
+			pos = defaultFieldPosition(len(v.Fields))
+		}
+		elements = append(elements, stampElement{Name: "total hours", Text: fmt.Sprintf("%s: %s", v.label("total"), v.Total), X: pos.X, Y: pos.Y})
+	}
+	for i := range elements {
+		elements[i].X += layout.OffsetX
+		elements[i].Y += layout.OffsetY
+	}
+	return elements
+}
+
+// Review decisions stamped by approve and reject
+const (
+	decisionApproved = "approved"
+	decisionRejected = "rejected"
+)
+
+const (
+	reviewLineHeight = 15
+	reviewTextWidth  = 220 // reasons are wrapped to stay in the manager column
+)
+
+// reviewBlock returns the elements approve and reject stamp on the last page,
+// below the manager's part of the signature block
+func reviewBlock(decision, managerName, date, reason string, layout Layout) []stampElement {
+	heading, color := "Goedgekeurd / Approved", "#1a7f37"
+	if decision == decisionRejected {
+		heading, color = "Afgekeurd / Rejected", "#cf222e"
+	}
+	elements := []stampElement{
+		{Name: "review heading", Text: heading, Bold: true, Color: color, X: 350, Y: 150},
+		{Name: "reviewer", Text: fmt.Sprintf("Manager: %s", managerName), X: 350, Y: 135},
+		{Name: "review date", Text: fmt.Sprintf("Datum: %s", date), X: 350, Y: 120},
+	}
+	if reason != "" {
+		y := 105.0
+		for i, line := range wrapText("Reden: "+reason, reviewTextWidth) {
+			elements = append(elements, stampElement{Name: fmt.Sprintf("reason line %d", i+1), Text: line, X: 350, Y: y})
+			y -= reviewLineHeight
+		}
+	}
+	for i := range elements {
+		elements[i].X += layout.OffsetX
+		elements[i].Y += layout.OffsetY
+	}
+	return elements
+}
+
+// wrapText breaks text into lines no wider than width points in the stamp font
+func wrapText(text string, width float64) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if line != "" && font.TextWidth(candidate, stampFont, stampFontSize) > width {
+			lines = append(lines, line)
+			candidate = word
+		}
+		line = candidate
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// watermark creates the pdfcpu stamp for e; signature is the image file used
+// for image elements
+func (e stampElement) watermark(signature string) (*pdfmodel.Watermark, error) {
+	if e.Image {
+		desc := fmt.Sprintf("pos:bl, off:%g %g, scale:%g abs, rot:0", e.X, e.Y, e.Scale)
+		return api.ImageWatermark(signature, desc, true, false, types.POINTS)
+	}
+	desc := fmt.Sprintf("font:%s, points:%d, pos:bl, off:%g %g, scale:1 abs, rot:0", e.font(), stampFontSize, e.X, e.Y)
+	if e.Color != "" {
+		desc += ", fillc:" + e.Color
+	}
+	return api.TextWatermark(e.Text, desc, true, false, types.POINTS)
+}
+
+// bounds returns the area e covers on a page whose visible region is page.
+// imgW and imgH are the pixel dimensions of the signature image.
+func (e stampElement) bounds(page rect, imgW, imgH int) rect {
+	x, y := page.X0+e.X, page.Y0+e.Y
+	if e.Image {
+		return rect{x, y, x + e.Scale*float64(imgW), y + e.Scale*float64(imgH)}
+	}
+	w := font.TextWidth(e.Text, e.font(), stampFontSize)
+	h := font.LineHeight(e.font(), stampFontSize)
+	return rect{x, y, x + w, y + h}
+}
+
+// imageSize returns the pixel dimensions of a PNG or JPG image
+func imageSize(data []byte) (int, int, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to decode signature image: %w", err)
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// stampElements applies elements one by one to the given page of data
+func stampElements(data []byte, pageNr int, elements []stampElement, signature string, conf *pdfmodel.Configuration) ([]byte, error) {
+	pageSelection := []string{fmt.Sprintf("%d", pageNr)}
+	for _, e := range elements {
+		wm, err := e.watermark(signature)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s watermark: %w", e.Name, err)
+		}
+		var buf bytes.Buffer
+		if err := api.AddWatermarks(bytes.NewReader(data), &buf, pageSelection, wm, conf); err != nil {
+			return nil, fmt.Errorf("failed to add %s: %w", e.Name, err)
+		}
+		data = buf.Bytes()
+	}
+	return data, nil
+}
@@ -0,0 +1,645 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// ============================================================================
+// Layout Templates
+// ============================================================================
+
+// Fingerprint selects the documents a layout template applies to. Empty
+// fields match any document.
+type Fingerprint struct {
+	// Metadata maps document info fields (Producer, Creator, Title, ...) or
+	// custom properties to regular expressions their values must match
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// PageSize is the size of the signed page in points, e.g. "595x842"
+	PageSize string `json:"page_size,omitempty"`
+
+	// Anchor is a regular expression that must match a text line of the
+	// signed page
+	Anchor string `json:"anchor,omitempty"`
+}
+
+// LayoutTemplate is a signature block placement for the documents matching
+// its fingerprint
+type LayoutTemplate struct {
+	Name  string      `json:"name"`
+	Match Fingerprint `json:"match"`
+	Layout
+}
+
+// documentInfo is what fingerprints are matched against
+type documentInfo struct {
+	Metadata map[string]string
+	Page     *pageContent
+	Lines    []string
+}
+
+// infoFields are the document info entries available to fingerprints
+var infoFields = []string{"Title", "Subject", "Author", "Keywords", "Creator", "Producer"}
+
+func newDocumentInfo(ctx *pdfmodel.Context, page *pageContent) documentInfo {
+	doc := documentInfo{Metadata: map[string]string{}, Page: page}
+	for _, name := range infoFields {
+		var value string
+		switch name {
+		case "Title":
+			value = ctx.Title
+		case "Subject":
+			value = ctx.Subject
+		case "Author":
+			value = ctx.Author
+		case "Keywords":
+			value = ctx.Keywords
+		case "Creator":
+			value = ctx.Creator
+		case "Producer":
+			value = ctx.Producer
+		}
+		if value != "" {
+			doc.Metadata[name] = value
+		}
+	}
+	for k, v := range ctx.Properties {
+		doc.Metadata[k] = v
+	}
+	for _, line := range page.textLines() {
+		doc.Lines = append(doc.Lines, line.Text)
+	}
+	return doc
+}
+
+// metadata looks up a metadata field, ignoring case
+func (d documentInfo) metadata(name string) string {
+	if v, ok := d.Metadata[name]; ok {
+		return v
+	}
+	for k, v := range d.Metadata {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+func (d documentInfo) pageSize() string {
+	return fmt.Sprintf("%.0fx%.0f", d.Page.Box.Width(), d.Page.Box.Height())
+}
+
+// String describes the document by the application that made it and its page size
+func (d documentInfo) String() string {
+	app := d.metadata("Creator")
+	if app == "" {
+		app = d.metadata("Producer")
+	}
+	if app == "" {
+		app = "unknown application"
+	}
+	return fmt.Sprintf("%s, %s pt", app, d.pageSize())
+}
+
+// parsePageSize parses a "width x height" size in points
+func parsePageSize(s string) (float64, float64, error) {
+	w, h, ok := strings.Cut(strings.ToLower(strings.ReplaceAll(s, " ", "")), "x")
+	if ok {
+		width, errW := strconv.ParseFloat(w, 64)
+		height, errH := strconv.ParseFloat(h, 64)
+		if errW == nil && errH == nil && width > 0 && height > 0 {
+			return width, height, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("invalid page size %q (use width x height in points, e.g. 595x842)", s)
+}
+
+// fingerprintCheck is the outcome of one fingerprint criterion
+type fingerprintCheck struct {
+	Field string `json:"field"`
+	Want  string `json:"want"`
+	Got   string `json:"got"`
+	OK    bool   `json:"ok"`
+}
+
+// validate checks that the patterns and page size of f can be used
+func (f Fingerprint) validate() error {
+	for k, pattern := range f.Metadata {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid %s pattern: %w", k, err)
+		}
+	}
+	if f.PageSize != "" {
+		if _, _, err := parsePageSize(f.PageSize); err != nil {
+			return err
+		}
+	}
+	if f.Anchor != "" {
+		if _, err := regexp.Compile(f.Anchor); err != nil {
+			return fmt.Errorf("invalid anchor pattern: %w", err)
+		}
+	}
+	return nil
+}
+
+// check evaluates every criterion of f against doc
+func (f Fingerprint) check(doc documentInfo) ([]fingerprintCheck, error) {
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+	var checks []fingerprintCheck
+
+	keys := make([]string, 0, len(f.Metadata))
+	for k := range f.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		got := doc.metadata(k)
+		ok := regexp.MustCompile(f.Metadata[k]).MatchString(got)
+		checks = append(checks, fingerprintCheck{Field: k, Want: f.Metadata[k], Got: got, OK: ok})
+	}
+
+	if f.PageSize != "" {
+		// Sizes are compared to the point, allowing for rounding
+		w, h, _ := parsePageSize(f.PageSize)
+		ok := math.Abs(w-doc.Page.Box.Width()) <= 1 && math.Abs(h-doc.Page.Box.Height()) <= 1
+		checks = append(checks, fingerprintCheck{Field: "page size", Want: f.PageSize, Got: doc.pageSize(), OK: ok})
+	}
+
+	if f.Anchor != "" {
+		re := regexp.MustCompile(f.Anchor)
+		c := fingerprintCheck{Field: "anchor", Want: f.Anchor, Got: "no matching text line"}
+		for _, line := range doc.Lines {
+			if re.MatchString(line) {
+				c.Got, c.OK = line, true
+				break
+			}
+		}
+		checks = append(checks, c)
+	}
+	return checks, nil
+}
+
+func allOK(checks []fingerprintCheck) bool {
+	for _, c := range checks {
+		if !c.OK {
+			return false
+		}
+	}
+	return true
+}
+
+// matchLayout returns the first template whose fingerprint matches doc, or
+// nil if none does
+func matchLayout(templates []LayoutTemplate, doc documentInfo) (*LayoutTemplate, error) {
+	for _, t := range templates {
+		checks, err := t.Match.check(doc)
+		if err != nil {
+			return nil, fmt.Errorf("layout template %q: %w", t.Name, err)
+		}
+		if allOK(checks) {
+			return &t, nil
+		}
+	}
+	return nil, nil
+}
+
+// exactFingerprint matches documents from the same application with the
+// same page size as doc. The Creator is preferred over the Producer, which
+// often changes with every version of a PDF library.
+func exactFingerprint(doc documentInfo) Fingerprint {
+	f := Fingerprint{Metadata: map[string]string{}, PageSize: doc.pageSize()}
+	for _, k := range []string{"Creator", "Producer"} {
+		if v := doc.metadata(k); v != "" {
+			f.Metadata[k] = "^" + regexp.QuoteMeta(v) + "$"
+			break
+		}
+	}
+	return f
+}
+
+func findTemplate(templates []LayoutTemplate, name string) int {
+	for i, t := range templates {
+		if t.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// saveTemplateLayout stores layout in the template that matched doc. Without
+// one a template for exactly this kind of document is added in front, so it
+// takes precedence over broader templates.
+func saveTemplateLayout(templates []LayoutTemplate, matched *LayoutTemplate, doc documentInfo, layout Layout) []LayoutTemplate {
+	templates = append([]LayoutTemplate(nil), templates...)
+	if matched != nil {
+		if i := findTemplate(templates, matched.Name); i >= 0 {
+			templates[i].Layout = layout
+			return templates
+		}
+	}
+	if layout.isZero() {
+		return templates
+	}
+	name := doc.String()
+	for i := 2; findTemplate(templates, name) >= 0; i++ {
+		name = fmt.Sprintf("%s (%d)", doc.String(), i)
+	}
+	t := LayoutTemplate{Name: name, Match: exactFingerprint(doc), Layout: layout}
+	return append([]LayoutTemplate{t}, templates...)
+}
+
+// validateLayouts checks that templates have unique names and usable fingerprints
+func validateLayouts(templates []LayoutTemplate) error {
+	seen := map[string]bool{}
+	for _, t := range templates {
+		if t.Name == "" {
+			return fmt.Errorf("layout template without a name")
+		}
+		if seen[t.Name] {
+			return fmt.Errorf("duplicate layout template %q", t.Name)
+		}
+		seen[t.Name] = true
+		if err := t.Match.validate(); err != nil {
+			return fmt.Errorf("layout template %q: %w", t.Name, err)
+		}
+	}
+	return nil
+}
+
+// readDocumentInfo loads the fingerprint data of the last page of path
+func readDocumentInfo(path string) (documentInfo, error) {
+	ctx, err := api.ReadContextFile(path)
+	if err != nil {
+		return documentInfo{}, fmt.Errorf("failed to read PDF context: %w", err)
+	}
+	page, err := analyzePageContext(ctx, 0)
+	if err != nil {
+		return documentInfo{}, err
+	}
+	return newDocumentInfo(ctx, page), nil
+}
+
+// ============================================================================
+// Layouts Subcommand
+// ============================================================================
+
+func layoutsUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: hours-signer layouts <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Layout templates place the signature block for documents matching a")
+	fmt.Fprintln(w, "fingerprint. The first matching template in the list is used.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	fmt.Fprintln(w, "  list               List layout templates in match order")
+	fmt.Fprintln(w, "  detect <file>      Show the fingerprint of a PDF and which template matches")
+	fmt.Fprintln(w, "  add [flags]        Register a layout template")
+	fmt.Fprintln(w, "  remove <name>      Remove a layout template")
+}
+
+func runLayouts(args []string) int {
+	if len(args) == 0 {
+		layoutsUsage(os.Stderr)
+		return exitUsage
+	}
+	switch args[0] {
+	case "list":
+		return layoutsList(args[1:])
+	case "detect":
+		return layoutsDetect(args[1:])
+	case "add":
+		return layoutsAdd(args[1:])
+	case "remove":
+		return layoutsRemove(args[1:])
+	case "help", "-h", "-help", "--help":
+		layoutsUsage(os.Stdout)
+		return exitOK
+	}
+	fmt.Fprintf(os.Stderr, "Error: unknown layouts command %q\n\n", args[0])
+	layoutsUsage(os.Stderr)
+	return exitUsage
+}
+
+func printTemplate(t LayoutTemplate) {
+	fmt.Printf("%s\n", t.Name)
+	keys := make([]string, 0, len(t.Match.Metadata))
+	for k := range t.Match.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("  %-10s /%s/\n", k+":", t.Match.Metadata[k])
+	}
+	if t.Match.PageSize != "" {
+		fmt.Printf("  %-10s %s\n", "Page size:", t.Match.PageSize)
+	}
+	if t.Match.Anchor != "" {
+		fmt.Printf("  %-10s /%s/\n", "Anchor:", t.Match.Anchor)
+	}
+	fmt.Printf("  %-10s offset %+g %+g pt, signature scale %g\n", "Layout:", t.OffsetX, t.OffsetY, t.signatureScale())
+	keys = keys[:0]
+	for k := range t.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("  %-10s %s at %g,%g\n", "Field:", k, t.Fields[k].X, t.Fields[k].Y)
+	}
+}
+
+func layoutsList(args []string) int {
+	fs := newFlagSet("layouts list", "", "List layout templates in the order they are tried.")
+	jsonOut := fs.Bool("json", false, "Print the templates as JSON")
+	positional, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+	if len(positional) > 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	cfg := LoadConfig()
+	if *jsonOut {
+		templates := cfg.Layouts
+		if templates == nil {
+			templates = []LayoutTemplate{}
+		}
+		writeJSON(struct {
+			OK        bool             `json:"ok"`
+			Templates []LayoutTemplate `json:"templates"`
+		}{true, templates})
+		return exitOK
+	}
+	if len(cfg.Layouts) == 0 {
+		fmt.Println("No layout templates registered")
+		return exitOK
+	}
+	for i, t := range cfg.Layouts {
+		if i > 0 {
+			fmt.Println()
+		}
+		printTemplate(t)
+	}
+	return exitOK
+}
+
+func layoutsDetect(args []string) int {
+	fs := newFlagSet("layouts detect", "<input.pdf>", "Show the fingerprint of a PDF and check it against every layout template.\nExits with 1 if no template matches.")
+	jsonOut := fs.Bool("json", false, "Print the fingerprint and checks as JSON")
+	positional, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: exactly one input PDF is required")
+		fs.Usage()
+		return exitUsage
+	}
+
+	doc, err := readDocumentInfo(positional[0])
+	if err != nil {
+		return fail(*jsonOut, signError(KindInputPDF, positional[0], err))
+	}
+	cfg := LoadConfig()
+	if *jsonOut {
+		return layoutsDetectJSON(positional[0], doc, cfg.Layouts)
+	}
+
+	fmt.Printf("Fingerprint of %s (page %d):\n", positional[0], doc.Page.PageNr)
+	keys := make([]string, 0, len(doc.Metadata))
+	for k := range doc.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("  %-10s %s\n", k+":", doc.Metadata[k])
+	}
+	fmt.Printf("  %-10s %s\n", "Page size:", doc.pageSize())
+	for i, line := range doc.Lines {
+		if i == 5 {
+			fmt.Printf("  %-10s ... %d more\n", "", len(doc.Lines)-5)
+			break
+		}
+		label := ""
+		if i == 0 {
+			label = "Text:"
+		}
+		fmt.Printf("  %-10s %s\n", label, truncate(line, 60))
+	}
+	fmt.Println()
+
+	if len(cfg.Layouts) == 0 {
+		fmt.Println("No layout templates registered: the default placement is used")
+		return exitError
+	}
+
+	var matched *LayoutTemplate
+	for _, t := range cfg.Layouts {
+		checks, err := t.Match.check(doc)
+		if err != nil {
+			fmt.Printf("✗ %s: %v\n", t.Name, err)
+			continue
+		}
+		status := "✗"
+		if allOK(checks) {
+			status = "✓"
+			if matched == nil {
+				matched = &t
+			}
+		}
+		fmt.Printf("%s %s\n", status, t.Name)
+		if len(checks) == 0 {
+			fmt.Println("    matches any document")
+		}
+		for _, c := range checks {
+			mark := "✓"
+			if !c.OK {
+				mark = "✗"
+			}
+			want := "/" + c.Want + "/"
+			if c.Field == "page size" {
+				want = c.Want
+			}
+			fmt.Printf("    %s %-10s want %s, got %q\n", mark, c.Field, want, c.Got)
+		}
+	}
+	fmt.Println()
+
+	if matched == nil {
+		fmt.Println("No template matches: the default placement is used")
+		return exitError
+	}
+	fmt.Printf("Using %s: offset %+g %+g pt, signature scale %g\n", matched.Name, matched.OffsetX, matched.OffsetY, matched.signatureScale())
+	return exitOK
+}
+
+func layoutsDetectJSON(input string, doc documentInfo, templates []LayoutTemplate) int {
+	type templateResult struct {
+		Name    string             `json:"name"`
+		Matched bool               `json:"matched"`
+		Error   string             `json:"error,omitempty"`
+		Checks  []fingerprintCheck `json:"checks"`
+	}
+	out := struct {
+		OK          bool   `json:"ok"`
+		Input       string `json:"input"`
+		Page        int    `json:"page"`
+		Fingerprint struct {
+			Metadata map[string]string `json:"metadata"`
+			PageSize string            `json:"page_size"`
+			Lines    []string          `json:"lines"`
+		} `json:"fingerprint"`
+		Templates      []templateResult `json:"templates"`
+		LayoutTemplate string           `json:"layout_template,omitempty"`
+	}{Input: input, Page: doc.Page.PageNr, Templates: []templateResult{}}
+	out.Fingerprint.Metadata = doc.Metadata
+	out.Fingerprint.PageSize = doc.pageSize()
+	out.Fingerprint.Lines = doc.Lines
+
+	for _, t := range templates {
+		r := templateResult{Name: t.Name, Checks: []fingerprintCheck{}}
+		checks, err := t.Match.check(doc)
+		if err != nil {
+			r.Error = err.Error()
+		} else {
+			r.Checks = append(r.Checks, checks...)
+			r.Matched = allOK(checks)
+		}
+		if r.Matched && !out.OK {
+			out.OK = true
+			out.LayoutTemplate = t.Name
+		}
+		out.Templates = append(out.Templates, r)
+	}
+	writeJSON(out)
+	if !out.OK {
+		return exitError
+	}
+	return exitOK
+}
+
+func layoutsAdd(args []string) int {
+	fs := newFlagSet("layouts add", "-name <name> [flags]", "Register a layout template. Templates are tried in order; the new one is added last.")
+	name := fs.String("name", "", "Template name (required)")
+	from := fs.String("from", "", "Match the application and page size of this PDF")
+	metadata := keyValueFlag{}
+	fs.Var(metadata, "metadata", "Metadata field and the regular expression it must match, as `key=regex` (repeatable)")
+	pageSize := fs.String("page-size", "", "Page size of the signed page in points, e.g. 595x842")
+	anchor := fs.String("anchor", "", "Regular expression matching a text line of the signed page")
+	offsetX := fs.Float64("offset-x", 0, "Move the signature block right by this many points")
+	offsetY := fs.Float64("offset-y", 0, "Move the signature block up by this many points")
+	scale := fs.Float64("scale", 0, fmt.Sprintf("Signature image scale (default %g)", defaultSignatureScale))
+	fieldPos := keyValueFlag{}
+	fs.Var(fieldPos, "field-pos", "Position of an extra field in points from the lower left corner, as `key=x,y` (repeatable)")
+	jsonOut := fs.Bool("json", false, "Print the added template as JSON")
+	positional, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+	if len(positional) > 0 || *name == "" {
+		fmt.Fprintln(os.Stderr, "Error: a template name is required")
+		fs.Usage()
+		return exitUsage
+	}
+
+	t := LayoutTemplate{Name: *name, Layout: Layout{OffsetX: *offsetX, OffsetY: *offsetY, SignatureScale: *scale}}
+	if *from != "" {
+		doc, err := readDocumentInfo(*from)
+		if err != nil {
+			return fail(*jsonOut, signError(KindInputPDF, *from, err))
+		}
+		t.Match = exactFingerprint(doc)
+	}
+	if len(metadata) > 0 && t.Match.Metadata == nil {
+		t.Match.Metadata = map[string]string{}
+	}
+	for k, v := range metadata {
+		t.Match.Metadata[k] = v
+	}
+	if *pageSize != "" {
+		t.Match.PageSize = *pageSize
+	}
+	t.Match.Anchor = *anchor
+	if *scale < 0 {
+		fmt.Fprintln(os.Stderr, "Error: scale must be positive")
+		return exitUsage
+	}
+	for key, value := range fieldPos {
+		var pos Position
+		if _, err := fmt.Sscanf(value, "%g,%g", &pos.X, &pos.Y); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -field-pos %s: expected x,y, got %q\n", key, value)
+			return exitUsage
+		}
+		if t.Fields == nil {
+			t.Fields = map[string]Position{}
+		}
+		t.Fields[key] = pos
+	}
+
+	if err := t.Match.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	cfg := LoadConfig()
+	if findTemplate(cfg.Layouts, t.Name) >= 0 {
+		return fail(*jsonOut, signError(KindConfig, ConfigPath(), fmt.Errorf("layout template %q already exists", t.Name)))
+	}
+	cfg.Layouts = append(cfg.Layouts, t)
+	if err := SaveConfig(cfg); err != nil {
+		return fail(*jsonOut, signError(KindConfig, ConfigPath(), err))
+	}
+	if *jsonOut {
+		writeJSON(struct {
+			OK       bool           `json:"ok"`
+			Template LayoutTemplate `json:"template"`
+		}{true, t})
+		return exitOK
+	}
+	fmt.Printf("✓ Added layout template %s\n", t.Name)
+	return exitOK
+}
+
+func layoutsRemove(args []string) int {
+	fs := newFlagSet("layouts remove", "<name>", "Remove a layout template.")
+	jsonOut := fs.Bool("json", false, "Print the result as JSON")
+	positional, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	name := positional[0]
+
+	cfg := LoadConfig()
+	i := findTemplate(cfg.Layouts, name)
+	if i < 0 {
+		return fail(*jsonOut, signError(KindConfig, ConfigPath(), fmt.Errorf("no layout template %q", name)))
+	}
+	cfg.Layouts = append(cfg.Layouts[:i], cfg.Layouts[i+1:]...)
+	if err := SaveConfig(cfg); err != nil {
+		return fail(*jsonOut, signError(KindConfig, ConfigPath(), err))
+	}
+	if *jsonOut {
+		writeJSON(struct {
+			OK      bool   `json:"ok"`
+			Removed string `json:"removed"`
+		}{true, name})
+		return exitOK
+	}
+	fmt.Printf("✓ Removed layout template %s\n", name)
+	return exitOK
+}
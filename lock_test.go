@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockOutputLeftoverLockFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	output := filepath.Join(t.TempDir(), "Urenstaat-2026-09-signed.pdf")
+
+	// A lock file left behind by a process that crashed while writing
+	lockFile, err := outputLockFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(lockFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(lockFile, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	unlock, err := lockOutput(output)
+	if err != nil {
+		t.Fatalf("lockOutput with a leftover lock file: %v", err)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("lockOutput waited %v for a lock nobody holds", waited)
+	}
+
+	// While held, the lock is taken
+	if _, err := tryLockFile(lockFile); !errors.Is(err, errLocked) {
+		t.Errorf("tryLockFile while locked: err = %v, want errLocked", err)
+	}
+
+	unlock()
+	if _, err := os.Stat(lockFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("lock file still there after unlock: %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLockFile takes a non-blocking exclusive flock on path. The lock file is
+// removed on unlock while the lock is still held, so a process that locked
+// the file just before its removal sees that path now names another file and
+// reports it as locked, to try again.
+func tryLockFile(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, errLocked
+		}
+		return nil, err
+	}
+	locked, errOpen := f.Stat()
+	current, errPath := os.Stat(path)
+	if errOpen != nil || errPath != nil || !os.SameFile(locked, current) {
+		f.Close()
+		return nil, errLocked
+	}
+	return func() {
+		os.Remove(path)
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
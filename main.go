@@ -3,8 +3,9 @@ package main
 import (
 	"bytes"
 	"encoding/json"
-	"flag"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -17,7 +18,6 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/pdfcpu/pdfcpu/pkg/api"
 	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
-	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
 )
 
 // Version info (set via ldflags at build time)
@@ -61,6 +61,59 @@ type Config struct {
 	SignaturePath string `json:"signature_path"`
 	EmployeeName  string `json:"employee_name"`
 	ManagerName   string `json:"manager_name"`
+	OutputMode    string `json:"output_mode,omitempty"`
+
+	// Layouts are the layout templates, tried in order
+	Layouts []LayoutTemplate `json:"layouts,omitempty"`
+
+	// Email is the message to the manager created after signing
+	Email *EmailConfig `json:"email,omitempty"`
+
+	// Language of the signature block labels; Fields are extra lines in it
+	Language string     `json:"language,omitempty"`
+	Fields   []FieldDef `json:"fields,omitempty"`
+
+	// TotalHours is how the total hours of a timesheet are handled: off,
+	// record or stamp
+	TotalHours string `json:"total_hours,omitempty"`
+
+	// AttachTable embeds the hours table in signed PDFs: csv or json
+	AttachTable string `json:"attach_table,omitempty"`
+
+	// CompanyName and LogoPath brand generated timesheets
+	CompanyName string `json:"company_name,omitempty"`
+	LogoPath    string `json:"logo_path,omitempty"`
+
+	// Rounding of imported hours, e.g. up:15m; ProjectClients maps projects
+	// of time trackers to clients, as "project=client" pairs
+	Rounding       string `json:"rounding,omitempty"`
+	ProjectClients string `json:"project_clients,omitempty"`
+
+	// ContractHours is the number of hours to work per week
+	ContractHours string `json:"contract_hours,omitempty"`
+
+	// HolidayCountry selects the public holidays (nl when empty); DaysOff
+	// are extra days off, as dates and date ranges
+	HolidayCountry string `json:"holiday_country,omitempty"`
+	DaysOff        string `json:"days_off,omitempty"`
+
+	// Validation of the hours before signing: the mode (off, warn or
+	// strict) and the rules
+	Validation   string `json:"validation,omitempty"`
+	MaxDayHours  string `json:"max_day_hours,omitempty"`
+	MaxDeviation string `json:"max_deviation,omitempty"`
+	WeekendHours string `json:"weekend_hours,omitempty"`
+	MissingDays  string `json:"missing_days,omitempty"`
+
+	// ContentChecks is "off" to sign PDFs without checking that they carry
+	// the employee name and a plausible period
+	ContentChecks string `json:"content_checks,omitempty"`
+
+	// Upload is the HTTP endpoint signed PDFs are uploaded to
+	Upload *UploadConfig `json:"upload,omitempty"`
+
+	// Invoice holds the rate, company and client details of invoices
+	Invoice *InvoiceConfig `json:"invoice,omitempty"`
 }
 
 func DefaultConfig() Config {
@@ -164,24 +217,24 @@ var (
 
 // pdfFile represents a PDF with its signed status
 type pdfFile struct {
-	name   string
-	signed bool
+	name  string
+	state signState
 }
 
-// isPDFSigned checks if a PDF has the HoursSigned metadata property
-func isPDFSigned(path string) bool {
+// readPDFProperties returns the custom document properties of a PDF
+func readPDFProperties(path string) (map[string]string, error) {
 	f, err := os.Open(path)
 	if err != nil {
-		return false
+		return nil, err
 	}
 	defer f.Close()
 
-	props, err := api.Properties(f, nil)
-	if err != nil {
-		return false
-	}
-	_, exists := props["HoursSigned"]
-	return exists
+	return api.Properties(f, nil)
+}
+
+// isPDFSigned checks if a PDF has been signed, approved or rejected
+func isPDFSigned(path string) bool {
+	return readSignState(path) != stateUnsigned
 }
 
 // scanPDFs returns a list of PDF files in the current directory with signed status
@@ -212,8 +265,8 @@ func scanPDFs() []pdfFile {
 	for _, name := range names {
 		fullPath := filepath.Join(cwd, name)
 		pdfs = append(pdfs, pdfFile{
-			name:   name,
-			signed: isPDFSigned(fullPath),
+			name:  name,
+			state: readSignState(fullPath),
 		})
 	}
 
@@ -234,8 +287,17 @@ const (
 	screenSetupConfirm
 	screenMain
 	screenFilePicker
+	screenPreview
+	screenPlacement
+	screenTotal
+	screenFields
+	screenConfirmSign
 	screenSigning
 	screenResult
+	screenHistory
+	screenGrid
+	screenValidation
+	screenMismatch
 )
 
 type model struct {
@@ -251,10 +313,65 @@ type model struct {
 	pdfFiles     []pdfFile
 	pdfCursor    int
 	selectedFile string
+	outputPath   string
+	inputState   signState
+	outputExists bool
+
+	// Total hours detected in the selected file, and the one confirmed or
+	// entered; nil leaves the total out
+	totalInput    textinput.Model
+	detectedTotal TotalHours
+	totalHours    *TotalHours
+	totalErr      error
+
+	// Extra field values asked before signing, by key
+	fieldInputs []textinput.Model
+	fieldFocus  int
+	fieldValues map[string]string
+	fieldErr    error
+
+	// Layout preview of the selected file
+	preview      *layoutPreview
+	previewErr   error
+	placementErr error
 
 	// Result
-	resultMsg string
-	resultErr error
+	resultMsg   string
+	resultErr   error
+	signResult  *SignResult
+	emailResult *EmailResult
+	emailErr    error
+	sending     bool
+
+	uploadResult *UploadResult
+	uploadErr    error
+	uploading    bool
+
+	// Hours grid: the draft of a month, the cell under the cursor and the
+	// hours being typed into it, and the name of a project being added
+	draft       *hoursDraft
+	gridDay     int
+	gridCol     int
+	gridEditing bool
+	gridEdit    string
+	gridAdding  bool
+	gridInput   textinput.Model
+	gridErr     error
+
+	// Period of the timesheet being signed, if known, and the screen to
+	// return to when signing is cancelled
+	signPeriod string
+	signFrom   screen
+
+	// What does not match in the selected file, and the issues found in
+	// its hours
+	mismatches []string
+	issues     []validationIssue
+
+	// Signing history
+	history       []HistoryEntry
+	historyCursor int
+	historyErr    error
 
 	// Version info
 	latestVersion  string
@@ -276,6 +393,34 @@ func checkVersionCmd() tea.Msg {
 	return versionCheckMsg{latest: latest, err: err}
 }
 
+// Message for an email sent in the background
+type emailSentMsg struct {
+	result *EmailResult
+	err    error
+}
+
+// sendEmailCmd sends the email for a signing over SMTP
+func sendEmailCmd(cfg EmailConfig, entry HistoryEntry, mode os.FileMode) tea.Cmd {
+	cfg.Deliver = deliverSMTP
+	return func() tea.Msg {
+		result, err := emailSignedPDF(cfg, entry, mode)
+		return emailSentMsg{result: result, err: err}
+	}
+}
+
+// Message for an upload done in the background
+type uploadDoneMsg struct {
+	result *UploadResult
+	err    error
+}
+
+func uploadCmd(cfg UploadConfig, entry HistoryEntry) tea.Cmd {
+	return func() tea.Msg {
+		result, err := uploadSignedPDF(cfg, entry)
+		return uploadDoneMsg{result: result, err: err}
+	}
+}
+
 func initialModel() model {
 	configExists := ConfigExists()
 	var cfg Config
@@ -340,6 +485,16 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case emailSentMsg:
+		m.sending = false
+		m.emailResult, m.emailErr = msg.result, msg.err
+		return m, nil
+
+	case uploadDoneMsg:
+		m.uploading = false
+		m.uploadResult, m.uploadErr = msg.result, msg.err
+		return m, nil
+
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
@@ -352,7 +507,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, tea.Quit
 			}
 		case "esc":
-			if m.screen == screenFilePicker {
+			if m.screen == screenFilePicker || m.screen == screenHistory {
 				m.screen = screenMain
 				return m, nil
 			}
@@ -374,8 +529,26 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.updateMain(msg)
 	case screenFilePicker:
 		return m.updateFilePicker(msg)
+	case screenPreview:
+		return m.updatePreview(msg)
+	case screenPlacement:
+		return m.updatePlacement(msg)
+	case screenConfirmSign:
+		return m.updateConfirmSign(msg)
 	case screenResult:
 		return m.updateResult(msg)
+	case screenHistory:
+		return m.updateHistory(msg)
+	case screenTotal:
+		return m.updateTotal(msg)
+	case screenFields:
+		return m.updateFields(msg)
+	case screenGrid:
+		return m.updateGrid(msg)
+	case screenValidation:
+		return m.updateValidation(msg)
+	case screenMismatch:
+		return m.updateMismatch(msg)
 	}
 
 	return m, nil
@@ -490,6 +663,19 @@ func (m model) updateMain(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.inputs[0].Focus()
 			m.screen = screenSetupSignature
 			return m, textinput.Blink
+		case "h", "3":
+			// Show most recent signings first
+			entries, err := LoadHistory()
+			for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+				entries[i], entries[j] = entries[j], entries[i]
+			}
+			m.history = entries
+			m.historyErr = err
+			m.historyCursor = 0
+			m.screen = screenHistory
+			return m, nil
+		case "e", "4":
+			return m.openGrid(currentPeriod())
 		}
 	}
 	return m, nil
@@ -510,40 +696,379 @@ func (m model) updateFilePicker(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if len(m.pdfFiles) == 0 {
 				return m, nil
 			}
-			cwd, _ := os.Getwd()
-			m.selectedFile = filepath.Join(cwd, m.pdfFiles[m.pdfCursor].name)
-			m.screen = screenSigning
+			m = m.selectFile()
+			return m.askTotal()
+		case "p":
+			if len(m.pdfFiles) == 0 {
+				return m, nil
+			}
+			m = m.selectFile()
+			m.preview, m.previewErr = previewSignPDF(m.selectedFile, signOptionsFromConfig(m.config))
+			m.screen = screenPreview
+			return m, nil
+		case "e":
+			if len(m.pdfFiles) == 0 {
+				return m, nil
+			}
+			m = m.selectFile()
+			m.preview, m.previewErr = previewSignPDF(m.selectedFile, signOptionsFromConfig(m.config))
+			if m.previewErr != nil {
+				m.screen = screenPreview
+				return m, nil
+			}
+			return m.editPlacement(), nil
+		case "esc":
+			m.screen = screenMain
+			return m, nil
+		}
+	}
+	return m, nil
+}
+
+// selectFile prepares signing the file under the cursor
+func (m model) selectFile() model {
+	cwd, _ := os.Getwd()
+	m.selectedFile = filepath.Join(cwd, m.pdfFiles[m.pdfCursor].name)
+	m.outputPath = defaultOutputName(m.selectedFile)
+	m.signPeriod, m.signFrom = "", screenFilePicker
+	m.inputState = m.pdfFiles[m.pdfCursor].state
+	m.outputExists = fileExists(m.outputPath)
+	return m
+}
 
-			// Sign the PDF
-			now := time.Now()
-			output := fmt.Sprintf("Urenstaat-%d-%02d-signed.pdf", now.Year(), now.Month())
+// askTotal shows the total hours read from the selected file for
+// confirmation, or goes on to the extra fields when the total is off
+func (m model) askTotal() (model, tea.Cmd) {
+	m.totalHours = nil
+	if m.config.totalMode() == totalOff {
+		return m.askFields()
+	}
+	m.detectedTotal, m.totalErr = TotalHours{}, nil
+	ctx, err := api.ReadContextFile(m.selectedFile)
+	if err == nil {
+		m.detectedTotal, err = detectTotalHours(ctx)
+	}
+	if err != nil {
+		m.detectedTotal.Reason = err.Error()
+	}
 
-			err := signPDF(m.selectedFile, output, m.config.EmployeeName, m.config.ManagerName, m.config.SignaturePath)
+	m.totalInput = textinput.New()
+	m.totalInput.Placeholder = "e.g. 160 or 152,5"
+	m.totalInput.CharLimit = 10
+	m.totalInput.Width = 20
+	if m.detectedTotal.Source != "" {
+		m.totalInput.SetValue(formatHours(m.detectedTotal.Hours, m.config.language()))
+	}
+	m.totalInput.Focus()
+	m.screen = screenTotal
+	return m, textinput.Blink
+}
+
+func (m model) updateTotal(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.String() {
+		case "enter":
+			value := strings.TrimSpace(m.totalInput.Value())
+			if value == "" {
+				// Sign without a total
+				m.totalHours = nil
+				return m.askFields()
+			}
+			total, err := manualTotal(value)
 			if err != nil {
-				m.resultErr = err
-				m.resultMsg = ""
-			} else {
-				m.resultErr = nil
-				m.resultMsg = output
+				m.totalErr = err
+				return m, nil
+			}
+			detected := m.detectedTotal
+			if detected.Source != "" && sameHours(total.Hours, detected.Hours) {
+				// Confirmed as detected; keep where it came from
+				detected.Confident, detected.Reason = true, ""
+				total = &detected
 			}
-			m.screen = screenResult
+			m.totalHours = total
+			return m.askFields()
+		case "esc":
+			m.screen = screenFilePicker
 			return m, nil
+		}
+	}
+	var cmd tea.Cmd
+	m.totalInput, cmd = m.totalInput.Update(msg)
+	return m, cmd
+}
+
+// askFields prompts for the values of the extra fields, or goes on to signing
+// right away when none are defined
+func (m model) askFields() (model, tea.Cmd) {
+	defs := m.config.Fields
+	if len(defs) == 0 {
+		return m.checkDocument(), nil
+	}
+	lang := m.config.language()
+	m.fieldInputs = make([]textinput.Model, len(defs))
+	for i, def := range defs {
+		input := textinput.New()
+		input.Placeholder = def.Default.in(lang)
+		input.CharLimit = 100
+		input.Width = 40
+		// Values entered for an earlier file are offered again
+		input.SetValue(m.fieldValues[def.Key])
+		m.fieldInputs[i] = input
+	}
+	m.fieldFocus = 0
+	m.fieldInputs[0].Focus()
+	m.fieldErr = nil
+	m.screen = screenFields
+	return m, textinput.Blink
+}
+
+func (m model) updateFields(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.String() {
+		case "tab", "down":
+			return m.focusField(m.fieldFocus + 1), textinput.Blink
+		case "shift+tab", "up":
+			return m.focusField(m.fieldFocus - 1), textinput.Blink
+		case "enter":
+			if m.fieldFocus < len(m.fieldInputs)-1 {
+				return m.focusField(m.fieldFocus + 1), textinput.Blink
+			}
+			// Empty inputs fall back to the default
+			values := map[string]string{}
+			for i, def := range m.config.Fields {
+				if v := strings.TrimSpace(m.fieldInputs[i].Value()); v != "" {
+					values[def.Key] = v
+				}
+			}
+			lang := m.config.language()
+			for i, def := range m.config.Fields {
+				if _, ok := values[def.Key]; !ok && def.Required && def.Default.in(lang) == "" {
+					m.fieldErr = fmt.Errorf("%s is required", def.Label.in(lang))
+					return m.focusField(i), textinput.Blink
+				}
+			}
+			if _, err := resolveFields(m.config.Fields, values, lang, false); err != nil {
+				m.fieldErr = err
+				return m, nil
+			}
+			m.fieldValues = values
+			return m.checkDocument(), nil
 		case "esc":
-			m.screen = screenMain
+			m.screen = m.signFrom
 			return m, nil
 		}
 	}
+	var cmd tea.Cmd
+	m.fieldInputs[m.fieldFocus], cmd = m.fieldInputs[m.fieldFocus].Update(msg)
+	return m, cmd
+}
+
+// focusField moves the focus to input i, wrapping around
+func (m model) focusField(i int) model {
+	n := len(m.fieldInputs)
+	m.fieldInputs[m.fieldFocus].Blur()
+	m.fieldFocus = (i%n + n) % n
+	m.fieldInputs[m.fieldFocus].Focus()
+	return m
+}
+
+// confirmOrSign asks before re-signing or overwriting anything, and signs
+// right away otherwise
+func (m model) confirmOrSign() model {
+	if m.inputState != stateUnsigned || m.outputExists {
+		m.screen = screenConfirmSign
+		return m
+	}
+	return m.sign()
+}
+
+func (m model) updatePreview(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.String() {
+		case "enter":
+			if m.previewErr != nil {
+				return m, nil
+			}
+			return m.askTotal()
+		case "e":
+			if m.previewErr != nil {
+				return m, nil
+			}
+			return m.editPlacement(), nil
+		case "esc":
+			m.screen = screenFilePicker
+			return m, nil
+		}
+	}
+	return m, nil
+}
+
+// Placement editor steps, in points and as a signature scale factor
+const (
+	placementStep      = 5
+	placementLargeStep = 25
+	placementScaleStep = .05
+)
+
+// editPlacement opens the placement editor on the previewed file
+func (m model) editPlacement() model {
+	m.placementErr = nil
+	m.screen = screenPlacement
+	return m
+}
+
+func (m model) updatePlacement(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	// place replaces the items, so the copy leaves the old preview intact
+	p := *m.preview
+	layout := p.Layout
+	switch key.String() {
+	case "left", "h":
+		layout.OffsetX -= placementStep
+	case "right", "l":
+		layout.OffsetX += placementStep
+	case "up", "k":
+		layout.OffsetY += placementStep
+	case "down", "j":
+		layout.OffsetY -= placementStep
+	case "shift+left", "H":
+		layout.OffsetX -= placementLargeStep
+	case "shift+right", "L":
+		layout.OffsetX += placementLargeStep
+	case "shift+up", "K":
+		layout.OffsetY += placementLargeStep
+	case "shift+down", "J":
+		layout.OffsetY -= placementLargeStep
+	case "+", "=":
+		layout.SignatureScale = layout.signatureScale() + placementScaleStep
+	case "-", "_":
+		layout.SignatureScale = max(layout.signatureScale()-placementScaleStep, placementScaleStep)
+	case "0":
+		layout = Layout{Fields: layout.Fields}
+	case "enter":
+		cfg := m.config
+		cfg.Layouts = saveTemplateLayout(cfg.Layouts, p.Template, p.Doc, p.Layout)
+		if err := SaveConfig(cfg); err != nil {
+			m.placementErr = err
+			return m, nil
+		}
+		m.config = cfg
+		m.preview, m.previewErr = previewSignPDF(m.selectedFile, signOptionsFromConfig(m.config))
+		m.screen = screenPreview
+		return m, nil
+	case "esc":
+		p.place(p.templateLayout())
+		m.preview = &p
+		m.screen = screenPreview
+		return m, nil
+	default:
+		return m, nil
+	}
+	layout.SignatureScale = math.Round(layout.SignatureScale*100) / 100
+	if layout.SignatureScale == defaultSignatureScale {
+		layout.SignatureScale = 0
+	}
+	p.place(layout)
+	m.preview = &p
 	return m, nil
 }
 
+func (m model) updateConfirmSign(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.String() {
+		case "y", "o":
+			return m.sign(), nil
+		case "r":
+			if m.outputExists {
+				m.outputPath = nonClobberingPath(m.outputPath)
+				return m.sign(), nil
+			}
+		case "n", "esc":
+			m.screen = m.signFrom
+			return m, nil
+		}
+	}
+	return m, nil
+}
+
+// sign signs the selected file after any confirmation has been given
+func (m model) sign() model {
+	m.screen = screenSigning
+	opts := signOptionsFromConfig(m.config)
+	opts.Force = true
+	opts.Fields = m.fieldValues
+	opts.TotalHours = m.totalHours
+	opts.Period = m.signPeriod
+	if m.totalHours == nil {
+		opts.Total = totalOff
+	}
+	result, err := signPDF(m.selectedFile, m.outputPath, opts)
+	m.signResult, m.emailResult, m.emailErr, m.sending = nil, nil, nil, false
+	m.uploadResult, m.uploadErr, m.uploading = nil, nil, false
+	if err != nil {
+		m.resultErr = err
+		m.resultMsg = ""
+	} else {
+		m.resultErr = nil
+		m.resultMsg = m.outputPath
+		m.signResult = result
+	}
+	m.screen = screenResult
+	return m
+}
+
 func (m model) updateResult(msg tea.Msg) (tea.Model, tea.Cmd) {
 	if key, ok := msg.(tea.KeyMsg); ok {
 		switch key.String() {
 		case "enter", " ":
+			if m.sending || m.uploading {
+				return m, nil
+			}
 			m.screen = screenMain
 			m.resultErr = nil
 			m.resultMsg = ""
 			return m, nil
+		case "m":
+			if m.canEmail() {
+				m.emailResult, m.emailErr = emailSignedPDF(*m.config.Email, m.signResult.HistoryEntry, signOptionsFromConfig(m.config).OutputMode)
+			}
+			return m, nil
+		case "s":
+			if m.canSend() {
+				m.sending = true
+				m.emailErr = nil
+				return m, sendEmailCmd(*m.config.Email, m.signResult.HistoryEntry, signOptionsFromConfig(m.config).OutputMode)
+			}
+			return m, nil
+		case "u":
+			if m.canUpload() {
+				m.uploading = true
+				m.uploadErr = nil
+				return m, uploadCmd(*m.config.Upload, m.signResult.HistoryEntry)
+			}
+			return m, nil
+		}
+	}
+	return m, nil
+}
+
+func (m model) updateHistory(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.String() {
+		case "up", "k":
+			if m.historyCursor > 0 {
+				m.historyCursor--
+			}
+		case "down", "j":
+			if m.historyCursor < len(m.history)-1 {
+				m.historyCursor++
+			}
+		case "enter":
+			m.screen = screenMain
+			return m, nil
 		}
 	}
 	return m, nil
@@ -565,10 +1090,28 @@ func (m model) View() string {
 		return m.viewMain()
 	case screenFilePicker:
 		return m.viewFilePicker()
+	case screenPreview:
+		return m.viewPreview()
+	case screenPlacement:
+		return m.viewPlacement()
+	case screenConfirmSign:
+		return m.viewConfirmSign()
 	case screenSigning:
 		return m.viewSigning()
 	case screenResult:
 		return m.viewResult()
+	case screenHistory:
+		return m.viewHistory()
+	case screenTotal:
+		return m.viewTotal()
+	case screenFields:
+		return m.viewFields()
+	case screenGrid:
+		return m.viewGrid()
+	case screenValidation:
+		return m.viewValidation()
+	case screenMismatch:
+		return m.viewMismatch()
 	}
 	return ""
 }
@@ -648,9 +1191,11 @@ func (m model) viewMain() string {
 
 	s += "What would you like to do?\n\n"
 	s += "  [s] Sign a PDF\n"
-	s += "  [c] Configure settings\n\n"
+	s += "  [c] Configure settings\n"
+	s += "  [h] View signing history\n"
+	s += "  [e] Enter hours\n\n"
 
-	s += helpStyle.Render("Press s to sign • c to configure • q to quit")
+	s += helpStyle.Render("Press s to sign • c to configure • h for history • e to enter hours • q to quit")
 	return s
 }
 
@@ -668,20 +1213,243 @@ func (m model) viewFilePicker() string {
 		if i == m.pdfCursor {
 			cursor = "> "
 			s += selectedItemStyle.Render(cursor+pdf.name)
-			if pdf.signed {
-				s += signedStyle.Render(" (signed)")
-			}
+			s += stateLabel(pdf.state)
 			s += "\n"
 		} else {
 			s += normalItemStyle.Render(cursor+pdf.name)
-			if pdf.signed {
-				s += signedStyle.Render(" (signed)")
-			}
+			s += stateLabel(pdf.state)
 			s += "\n"
 		}
 	}
 
-	s += "\n" + helpStyle.Render("↑/↓ to navigate • Enter to select • Esc to cancel")
+	s += "\n" + helpStyle.Render("↑/↓ to navigate • Enter to select • p to preview • e to adjust placement • Esc to cancel")
+	return s
+}
+
+func (m model) viewTotal() string {
+	s := titleStyle.Render("🧮 Total Hours") + "\n\n"
+	s += fmt.Sprintf("Total hours of %s.\n\n", filepath.Base(m.selectedFile))
+
+	t := m.detectedTotal
+	if t.Confident {
+		s += successStyle.Render(fmt.Sprintf("✓ Found %s in the %s", formatHours(t.Hours, m.config.language()), t.Source)) + "\n\n"
+	} else {
+		s += errorStyle.Render("⚠ Could not determine the total with confidence: "+t.Reason) + "\n"
+		s += "Check the total and correct it, or leave it empty to sign without one.\n\n"
+	}
+	s += m.totalInput.View() + "\n\n"
+	if m.totalErr != nil {
+		s += errorStyle.Render(m.totalErr.Error()) + "\n\n"
+	}
+	if m.config.totalMode() == totalStamp {
+		s += "The total is stamped into the signature block and recorded in the PDF metadata.\n\n"
+	} else {
+		s += "The total is recorded in the PDF metadata.\n\n"
+	}
+
+	s += helpStyle.Render("Enter to confirm • Esc to cancel")
+	return s
+}
+
+func (m model) viewFields() string {
+	s := titleStyle.Render("📝 Extra Fields") + "\n\n"
+	s += fmt.Sprintf("Values for the signature block of %s.\n\n", filepath.Base(m.selectedFile))
+
+	lang := m.config.language()
+	for i, def := range m.config.Fields {
+		label := def.Label.in(lang)
+		if def.Required {
+			label += " *"
+		}
+		if i == m.fieldFocus {
+			s += selectedItemStyle.Render(label) + "\n"
+		} else {
+			s += normalItemStyle.Render(label) + "\n"
+		}
+		s += m.fieldInputs[i].View() + "\n\n"
+	}
+	if m.fieldErr != nil {
+		s += errorStyle.Render(m.fieldErr.Error()) + "\n\n"
+	}
+
+	s += helpStyle.Render("Tab/↑/↓ to move • Enter to continue • empty fields use the default • Esc to cancel")
+	return s
+}
+
+// stateLabel marks signed, approved and rejected PDFs in the file picker
+func stateLabel(state signState) string {
+	switch state {
+	case stateSigned, stateApproved:
+		return signedStyle.Render(fmt.Sprintf(" (%s)", state))
+	case stateRejected:
+		return errorStyle.Render(fmt.Sprintf(" (%s)", state))
+	}
+	return ""
+}
+
+func (m model) viewHistory() string {
+	s := titleStyle.Render("📜 Signing History") + "\n\n"
+
+	if m.historyErr != nil {
+		s += errorStyle.Render(fmt.Sprintf("%v", m.historyErr)) + "\n\n"
+	}
+	if len(m.history) == 0 {
+		s += "No signings recorded yet.\n\n"
+		s += helpStyle.Render("Esc to go back")
+		return s
+	}
+
+	// Keep the cursor visible when the list is longer than the window
+	visible := 10
+	if m.height > 20 {
+		visible = m.height - 14
+	}
+	start := 0
+	if m.historyCursor >= visible {
+		start = m.historyCursor - visible + 1
+	}
+	end := min(start+visible, len(m.history))
+
+	for i := start; i < end; i++ {
+		e := m.history[i]
+		line := fmt.Sprintf("%s  %s  %s", e.Timestamp.Local().Format("02-01-2006 15:04"), e.Period, filepath.Base(e.OutputPath))
+		if i == m.historyCursor {
+			s += selectedItemStyle.Render("> "+line) + "\n"
+		} else {
+			s += normalItemStyle.Render("  "+line) + "\n"
+		}
+	}
+
+	e := m.history[m.historyCursor]
+	s += "\n" + subtitleStyle.Render(fmt.Sprintf("Entry %d of %d", m.historyCursor+1, len(m.history))) + "\n"
+	s += fmt.Sprintf("  Employee:  %s\n", e.Employee)
+	s += fmt.Sprintf("  Manager:   %s\n", e.Manager)
+	if e.TotalHours != nil {
+		s += fmt.Sprintf("  Total:     %s hours (%s)\n", formatHours(*e.TotalHours, m.config.language()), e.TotalSource)
+	}
+	if e.Action != "" {
+		s += fmt.Sprintf("  Review:    %s\n", e.review())
+	}
+	s += fmt.Sprintf("  Input:     %s\n", e.InputPath)
+	s += fmt.Sprintf("             %s\n", blurredStyle.Render("sha256 "+e.InputSHA256))
+	s += fmt.Sprintf("  Output:    %s\n", e.OutputPath)
+	s += fmt.Sprintf("             %s\n", blurredStyle.Render("sha256 "+e.OutputSHA256))
+	for _, d := range e.Deliveries {
+		s += fmt.Sprintf("  Sent:      %s\n", d)
+	}
+
+	s += "\n" + helpStyle.Render("↑/↓ to navigate • Esc to go back")
+	return s
+}
+
+func (m model) viewPreview() string {
+	s := titleStyle.Render("🔍 Layout Preview") + "\n\n"
+
+	if m.previewErr != nil {
+		s += errorStyle.Render(fmt.Sprintf("%v", m.previewErr)) + "\n\n"
+		s += helpStyle.Render("Esc to go back")
+		return s
+	}
+	p := m.preview
+
+	s += subtitleStyle.Render(fmt.Sprintf("%s, page %d", filepath.Base(p.InputPath), p.Page.PageNr)) + "\n"
+	s += m.renderSchematic(p, 14) + "\n"
+
+	if p.Total != nil {
+		s += fmt.Sprintf("Total hours: %s\n", p.Total)
+	}
+	if n := p.Collisions(); n > 0 {
+		s += errorStyle.Render(fmt.Sprintf("%d of %d elements collide with existing content:", n, len(p.Items))) + "\n"
+		for _, item := range p.Items {
+			if item.OffPage {
+				s += fmt.Sprintf("  %s extends beyond the page\n", item.Element.Name)
+			}
+			if len(item.Collisions) > 0 {
+				s += fmt.Sprintf("  %s overlaps %d item(s)\n", item.Element.Name, len(item.Collisions))
+			}
+		}
+	} else {
+		s += successStyle.Render("✓ No collisions: the signature block fits") + "\n"
+	}
+
+	s += "\n" + helpStyle.Render("Enter to sign • e to adjust placement • Esc to go back")
+	return s
+}
+
+// renderSchematic draws p as a framed grid that fits the window, leaving
+// reserved lines for the rest of the screen
+func (m model) renderSchematic(p *layoutPreview, reserved int) string {
+	// Characters are about twice as high as wide
+	cols := 48
+	if m.width > 0 && m.width-4 < cols {
+		cols = max(m.width-4, 20)
+	}
+	rows := int(float64(cols) * p.Page.Box.Height() / p.Page.Box.Width() / 2)
+	if m.height > 0 && rows > m.height-reserved {
+		rows = max(m.height-reserved, 10)
+	}
+
+	s := "┌" + strings.Repeat("─", cols) + "┐\n"
+	for _, line := range p.schematic(cols, rows) {
+		s += "│"
+		for _, r := range line {
+			switch r {
+			case '█':
+				s += signedStyle.Render(string(r))
+			case 'X':
+				s += errorStyle.Render(string(r))
+			case '░', '▒':
+				s += blurredStyle.Render(string(r))
+			default:
+				s += string(r)
+			}
+		}
+		s += "│\n"
+	}
+	s += "└" + strings.Repeat("─", cols) + "┘\n"
+	s += blurredStyle.Render("░ text  ▒ image  ") + signedStyle.Render("█ signature block  ") + errorStyle.Render("X collision") + "\n"
+	return s
+}
+
+func (m model) viewPlacement() string {
+	s := titleStyle.Render("📐 Adjust Placement") + "\n\n"
+	p := m.preview
+
+	s += subtitleStyle.Render(p.templateName()) + "\n"
+	s += m.renderSchematic(p, 16) + "\n"
+
+	s += fmt.Sprintf("  Offset: %+g, %+g pt   Signature scale: %.2f", p.Layout.OffsetX, p.Layout.OffsetY, p.Layout.signatureScale())
+	if n := p.Collisions(); n > 0 {
+		s += "   " + errorStyle.Render(fmt.Sprintf("%d collision(s)", n))
+	} else {
+		s += "   " + successStyle.Render("✓ fits")
+	}
+	s += "\n"
+	if m.placementErr != nil {
+		s += errorStyle.Render(fmt.Sprintf("%v", m.placementErr)) + "\n"
+	}
+
+	s += "\n" + helpStyle.Render("←/↑/↓/→ move (shift for larger steps) • +/- scale signature • 0 reset • Enter to save • Esc to cancel")
+	return s
+}
+
+func (m model) viewConfirmSign() string {
+	s := titleStyle.Render("⚠ Confirm Signing") + "\n\n"
+
+	if m.inputState != stateUnsigned {
+		s += errorStyle.Render(fmt.Sprintf("%s is already %s.", filepath.Base(m.selectedFile), m.inputState)) + "\n"
+		s += "Signing again stamps a second signature block over the first.\n\n"
+	}
+	if m.outputExists {
+		s += errorStyle.Render(fmt.Sprintf("%s already exists.", m.outputPath)) + "\n\n"
+		s += fmt.Sprintf("  [o] Overwrite %s\n", m.outputPath)
+		s += fmt.Sprintf("  [r] Save as %s\n", nonClobberingPath(m.outputPath))
+		s += "  [n] Cancel\n"
+		s += helpStyle.Render("Press o to overwrite • r to rename • n/Esc to cancel")
+		return s
+	}
+
+	s += helpStyle.Render("Press y to sign again • n/Esc to cancel")
 	return s
 }
 
@@ -700,251 +1468,418 @@ func (m model) viewResult() string {
 	}
 
 	s := successStyle.Render("✓ PDF Signed Successfully!") + "\n\n"
-	s += fmt.Sprintf("Output: %s\n\n", m.resultMsg)
+	s += fmt.Sprintf("Output: %s\n", m.resultMsg)
+	if r := m.signResult; r != nil && r.Date != "" {
+		s += fmt.Sprintf("Signed on: %s\n", r.Date)
+	}
+	if r := m.signResult; r != nil && r.TotalHours != nil {
+		s += fmt.Sprintf("Total hours: %s\n", formatHours(*r.TotalHours, m.config.language()))
+	}
+	s += "\n"
+
+	switch {
+	case m.sending:
+		s += fmt.Sprintf("Sending email to %s via %s...\n\n", m.config.Email.To, m.config.Email.SMTP.Host)
+	case m.emailErr != nil:
+		s += errorStyle.Render(fmt.Sprintf("Email failed: %v", m.emailErr)) + "\n\n"
+	case m.emailResult != nil && m.emailResult.Path != "":
+		s += successStyle.Render(fmt.Sprintf("✓ Email to %s saved as %s", m.emailResult.To, m.emailResult.Path)) + "\n\n"
+	case m.emailResult != nil:
+		s += successStyle.Render(fmt.Sprintf("✓ Email sent to %s", m.emailResult.To)) + "\n\n"
+	}
+
+	switch {
+	case m.uploading:
+		s += fmt.Sprintf("Uploading to %s...\n\n", m.config.Upload.URL)
+	case m.uploadErr != nil:
+		s += errorStyle.Render(fmt.Sprintf("Upload failed: %v", m.uploadErr)) + "\n\n"
+	case m.uploadResult != nil:
+		s += successStyle.Render(fmt.Sprintf("✓ Uploaded to %s (HTTP %d)", m.uploadResult.URL, m.uploadResult.Status)) + "\n\n"
+	}
+	if m.sending || m.uploading {
+		return s
+	}
+
+	var keys []string
+	if m.canEmail() && m.emailResult == nil {
+		s += fmt.Sprintf("  [m] Email to %s\n", m.config.Email.To)
+		keys = append(keys, "m to create the email")
+	}
+	if m.canSend() {
+		s += fmt.Sprintf("  [s] Send to %s via %s\n", m.config.Email.To, m.config.Email.SMTP.Host)
+		keys = append(keys, "s to send it")
+	}
+	if m.canUpload() {
+		s += fmt.Sprintf("  [u] Upload to %s\n", m.config.Upload.URL)
+		keys = append(keys, "u to upload")
+	}
+	if len(keys) > 0 {
+		s += "\n" + helpStyle.Render("Press "+strings.Join(keys, " • ")+" • Enter to continue")
+		return s
+	}
 	s += helpStyle.Render("Press Enter to continue")
 	return s
 }
 
+// canUpload reports whether the signed PDF can be uploaded and has not been
+// uploaded yet
+func (m model) canUpload() bool {
+	return m.signResult != nil && m.uploadResult == nil && m.config.Upload != nil && m.config.Upload.URL != ""
+}
+
+// canSend reports whether the signed PDF can be sent over SMTP and has not
+// been sent yet
+func (m model) canSend() bool {
+	sent := m.emailResult != nil && m.emailResult.Delivered == deliverSMTP
+	return m.canEmail() && !sent && m.config.Email.SMTP != nil && m.config.Email.SMTP.Host != ""
+}
+
+// canEmail reports whether the signed PDF can be emailed to the manager
+func (m model) canEmail() bool {
+	return m.signResult != nil && m.config.Email != nil && m.config.Email.To != ""
+}
+
 // ============================================================================
 // PDF Signing Logic
 // ============================================================================
 
 func getSignatureData(signaturePath string) ([]byte, error) {
 	if signaturePath == "" {
-		return nil, fmt.Errorf("signature path is required - please configure it first")
-	}
-
-	// Expand ~ to home directory
-	if signaturePath[0] == '~' {
-		home, err := os.UserHomeDir()
-		if err == nil {
-			signaturePath = filepath.Join(home, signaturePath[1:])
-		}
+		return nil, signError(KindConfig, ConfigPath(), fmt.Errorf("signature path is required - please configure it first"))
 	}
 
-	data, err := os.ReadFile(signaturePath)
+	data, err := os.ReadFile(expandHome(signaturePath))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read signature file: %w", err)
+		return nil, signError(KindSignatureImage, signaturePath, fmt.Errorf("failed to read signature file: %w", err))
 	}
 	return data, nil
 }
 
-func signPDF(inputPath, outputPath, employeeName, managerName, signaturePath string) error {
-	inputData, err := os.ReadFile(inputPath)
-	if err != nil {
-		return fmt.Errorf("failed to read input file: %w", err)
+// Errors returned by signPDF when signing is refused without force
+var (
+	ErrAlreadySigned = errors.New("input PDF is already signed")
+	ErrOutputExists  = errors.New("output file already exists")
+)
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// nonClobberingPath returns path, or path with a -1, -2, ... suffix before
+// the extension if path already exists
+func nonClobberingPath(path string) string {
+	if !fileExists(path) {
+		return path
+	}
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s-%d%s", base, i, ext)
+		if !fileExists(candidate) {
+			return candidate
+		}
 	}
+}
 
-	ctx, err := api.ReadContextFile(inputPath)
-	if err != nil {
-		return fmt.Errorf("failed to read PDF context: %w", err)
+// SignOptions holds everything signPDF needs besides the input and output
+type SignOptions struct {
+	EmployeeName  string
+	ManagerName   string
+	SignaturePath string
+
+	// Force signs already signed inputs and overwrites existing outputs
+	Force bool
+
+	// OutputMode is the permission for new output files (0 for the default);
+	// an existing output keeps its mode
+	OutputMode os.FileMode
+
+	// Layouts are the layout templates; the first one matching the input
+	// places the signature block
+	Layouts []LayoutTemplate
+
+	// Language of the signature block labels, and the extra fields with
+	// their values by key
+	Language  string
+	FieldDefs []FieldDef
+	Fields    map[string]string
+
+	// Total is how the total hours are handled: totalOff (or empty),
+	// totalRecord or totalStamp. TotalHours replaces the total detected in
+	// the input.
+	Total      string
+	TotalHours *TotalHours
+
+	// AttachTable embeds the hours table of the input in the signed PDF as
+	// tableCSV or tableJSON; empty for none
+	AttachTable string
+
+	// Period is the month the timesheet covers, as yyyy-mm, when given;
+	// DocumentPeriod is the month read from the input otherwise. Without
+	// either, the current month is used.
+	Period         string
+	DocumentPeriod string
+
+	// Date is the signing date as dd-mm-yyyy; empty for the last working day
+	// of the period in Calendar, or today if that is earlier
+	Date     string
+	Calendar workCalendar
+
+	// Validation are the checks on the hours in the input
+	Validation validationRules
+
+	// CheckContent refuses inputs without the employee name or for an
+	// implausible period, unless Force is set
+	CheckContent bool
+}
+
+func (opts SignOptions) period() string {
+	switch {
+	case opts.Period != "":
+		return opts.Period
+	case opts.DocumentPeriod != "":
+		return opts.DocumentPeriod
 	}
+	return currentPeriod()
+}
 
-	pageCount := ctx.PageCount
-	conf := pdfmodel.NewDefaultConfiguration()
+func (opts SignOptions) signingDate() string {
+	if opts.Date != "" {
+		return opts.Date
+	}
+	return opts.Calendar.signingDate(opts.period(), time.Now()).Format("02-01-2006")
+}
+
+// signOptionsFromConfig returns the sign options configured in cfg
+func signOptionsFromConfig(cfg Config) SignOptions {
+	opts := SignOptions{
+		EmployeeName:  cfg.EmployeeName,
+		ManagerName:   cfg.ManagerName,
+		SignaturePath: cfg.SignaturePath,
+		Layouts:       cfg.Layouts,
+		Language:      cfg.language(),
+		FieldDefs:     cfg.Fields,
+		Total:         cfg.totalMode(),
+		AttachTable:   cfg.AttachTable,
+		Calendar:      cfg.workCalendar(),
+		Validation:    cfg.validationRules(),
+		CheckContent:  cfg.ContentChecks != checkOff,
+	}
+	if cfg.OutputMode != "" {
+		// An invalid mode falls back to the default
+		opts.OutputMode, _ = parseFileMode(cfg.OutputMode)
+	}
+	return opts
+}
+
+// SignResult describes a completed signing
+type SignResult struct {
+	HistoryEntry
+	LayoutTemplate string            `json:"layout_template,omitempty"`
+	Attachment     string            `json:"attachment,omitempty"`
+	Warnings       []string          `json:"warnings,omitempty"`
+	Validation     []validationIssue `json:"validation,omitempty"` // issues in the hours, when signed anyway
+	Email          *EmailResult      `json:"email,omitempty"`
+	Upload         *UploadResult     `json:"upload,omitempty"`
+}
 
-	sigData, err := getSignatureData(signaturePath)
+// signPDF stamps the signature blocks onto the last page of inputPath and
+// writes the result to outputPath. Unless opts.Force is set it refuses inputs
+// that are already signed and outputs that already exist. Errors are
+// *SignError values telling which file or setting caused them.
+func signPDF(inputPath, outputPath string, opts SignOptions) (*SignResult, error) {
+	employeeName, managerName := opts.EmployeeName, opts.ManagerName
+	fields, err := resolveFields(opts.FieldDefs, opts.Fields, opts.Language, false)
 	if err != nil {
-		return err
+		return nil, signError(KindConfig, ConfigPath(), err)
 	}
 
-	sigFile, err := os.CreateTemp("", "signature-*.png")
+	// Hold the lock across the existence check and the write so concurrent
+	// runs cannot both decide the output is free
+	unlock, err := lockOutput(outputPath)
 	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
+		return nil, signError(KindOutputWrite, outputPath, err)
 	}
-	defer os.Remove(sigFile.Name())
+	defer unlock()
 
-	if _, err := sigFile.Write(sigData); err != nil {
-		return fmt.Errorf("failed to write signature: %w", err)
+	if !opts.Force {
+		if isPDFSigned(inputPath) {
+			return nil, signError(KindAlreadySigned, inputPath, fmt.Errorf("%w: %s (use -force to sign again)", ErrAlreadySigned, inputPath))
+		}
+		if fileExists(outputPath) {
+			return nil, signError(KindOutputExists, outputPath, fmt.Errorf("%w: %s (use -force to overwrite)", ErrOutputExists, outputPath))
+		}
 	}
-	sigFile.Close()
 
-	pageSelection := []string{fmt.Sprintf("%d", pageCount)}
-	currentDate := time.Now().Format("02-01-2006")
-
-	employeeLabel := fmt.Sprintf("Werknemer: %s", employeeName)
-	wmEmployeeLabel, err := api.TextWatermark(employeeLabel, "font:Helvetica, points:10, pos:bl, off:40 210, scale:1 abs, rot:0", true, false, types.POINTS)
+	inputData, err := os.ReadFile(inputPath)
 	if err != nil {
-		return fmt.Errorf("failed to create employee label watermark: %w", err)
+		return nil, signError(KindInputPDF, inputPath, fmt.Errorf("failed to read input file: %w", err))
 	}
 
-	employeeDateLabel := fmt.Sprintf("Datum: %s", currentDate)
-	wmEmployeeDate, err := api.TextWatermark(employeeDateLabel, "font:Helvetica, points:10, pos:bl, off:40 195, scale:1 abs, rot:0", true, false, types.POINTS)
+	ctx, err := api.ReadContextFile(inputPath)
 	if err != nil {
-		return fmt.Errorf("failed to create employee date watermark: %w", err)
+		return nil, signError(KindInputPDF, inputPath, fmt.Errorf("failed to read PDF context: %w", err))
 	}
 
-	wmHandtekeningLabel, err := api.TextWatermark("Handtekening:", "font:Helvetica, points:10, pos:bl, off:40 180, scale:1 abs, rot:0", true, false, types.POINTS)
-	if err != nil {
-		return fmt.Errorf("failed to create handtekening label watermark: %w", err)
+	if opts.Period == "" && opts.DocumentPeriod == "" {
+		opts.DocumentPeriod = contextPeriod(ctx)
 	}
 
-	wmSignature, err := api.ImageWatermark(sigFile.Name(), "pos:bl, off:120 90, scale:.35 abs, rot:0", true, false, types.POINTS)
+	pageCount := ctx.PageCount
+	page, err := analyzePageContext(ctx, pageCount)
 	if err != nil {
-		return fmt.Errorf("failed to create signature watermark: %w", err)
+		return nil, signError(KindInputPDF, inputPath, err)
 	}
-
-	managerLabel := fmt.Sprintf("Manager: %s", managerName)
-	wmManagerLabel, err := api.TextWatermark(managerLabel, "font:Helvetica, points:10, pos:bl, off:350 210, scale:1 abs, rot:0", true, false, types.POINTS)
+	var layout Layout
+	template, err := matchLayout(opts.Layouts, newDocumentInfo(ctx, page))
 	if err != nil {
-		return fmt.Errorf("failed to create manager label watermark: %w", err)
+		return nil, signError(KindConfig, ConfigPath(), err)
+	}
+	if template != nil {
+		layout = template.Layout
 	}
+	conf := pdfmodel.NewDefaultConfiguration()
 
-	wmManagerDate, err := api.TextWatermark("Datum:", "font:Helvetica, points:10, pos:bl, off:350 195, scale:1 abs, rot:0", true, false, types.POINTS)
+	result := &SignResult{}
+	if template != nil {
+		result.LayoutTemplate = template.Name
+	}
+	if opts.CheckContent {
+		mismatches, err := checkContent(ctx, opts, time.Now())
+		if err != nil {
+			return nil, signError(KindInputPDF, inputPath, err)
+		}
+		if len(mismatches) > 0 && !opts.Force {
+			return nil, signError(KindContent, inputPath, contentError(mismatches))
+		}
+		result.Warnings = append(result.Warnings, mismatches...)
+	}
+	total, err := signingTotal(ctx, opts)
 	if err != nil {
-		return fmt.Errorf("failed to create manager date watermark: %w", err)
+		return nil, signError(KindInputPDF, inputPath, err)
+	}
+	if total != nil && !total.Confident {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("total hours left out: %s (set it with -total)", total.Reason))
+		total = nil
 	}
+	issues, warning := validateTimesheet(ctx, opts)
+	if warning != "" {
+		result.Warnings = append(result.Warnings, warning)
+	}
+	if len(issues) > 0 && opts.Validation.Mode == validationStrict {
+		return nil, signError(KindValidation, inputPath, validationError(issues))
+	}
+	result.Validation = issues
 
-	wmManagerHandtekening, err := api.TextWatermark("Handtekening:", "font:Helvetica, points:10, pos:bl, off:350 180, scale:1 abs, rot:0", true, false, types.POINTS)
+	sigData, err := getSignatureData(opts.SignaturePath)
 	if err != nil {
-		return fmt.Errorf("failed to create manager handtekening label watermark: %w", err)
+		return nil, err
 	}
-
-	reader := bytes.NewReader(inputData)
-	var buf bytes.Buffer
-
-	if err := api.AddWatermarks(reader, &buf, pageSelection, wmEmployeeLabel, conf); err != nil {
-		return fmt.Errorf("failed to add employee label: %w", err)
+	imgW, imgH, err := imageSize(sigData)
+	if err != nil {
+		return nil, signError(KindSignatureImage, opts.SignaturePath, err)
 	}
 
-	reader = bytes.NewReader(buf.Bytes())
-	buf.Reset()
-	if err := api.AddWatermarks(reader, &buf, pageSelection, wmEmployeeDate, conf); err != nil {
-		return fmt.Errorf("failed to add employee date: %w", err)
+	sigFile, err := os.CreateTemp("", "signature-*.png")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
 	}
+	defer os.Remove(sigFile.Name())
 
-	reader = bytes.NewReader(buf.Bytes())
-	buf.Reset()
-	if err := api.AddWatermarks(reader, &buf, pageSelection, wmHandtekeningLabel, conf); err != nil {
-		return fmt.Errorf("failed to add handtekening label: %w", err)
+	if _, err := sigFile.Write(sigData); err != nil {
+		return nil, fmt.Errorf("failed to write signature: %w", err)
 	}
+	sigFile.Close()
 
-	reader = bytes.NewReader(buf.Bytes())
-	buf.Reset()
-	if err := api.AddWatermarks(reader, &buf, pageSelection, wmSignature, conf); err != nil {
-		return fmt.Errorf("failed to add signature: %w", err)
+	values := blockValues{
+		Employee: employeeName,
+		Manager:  managerName,
+		Date:     opts.signingDate(),
+		Language: opts.Language,
+		Fields:   fields,
 	}
+	if total != nil && opts.Total == totalStamp {
+		values.Total = formatHours(total.Hours, opts.Language)
+	}
+	elements := signatureBlock(values, layout)
 
-	reader = bytes.NewReader(buf.Bytes())
-	buf.Reset()
-	if err := api.AddWatermarks(reader, &buf, pageSelection, wmManagerLabel, conf); err != nil {
-		return fmt.Errorf("failed to add manager label: %w", err)
+	for _, e := range elements {
+		if item := placeElement(page, e, imgW, imgH); item.OffPage {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s extends beyond the page", e.Name))
+		} else if len(item.Collisions) > 0 {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s overlaps existing content", e.Name))
+		}
 	}
 
-	reader = bytes.NewReader(buf.Bytes())
-	buf.Reset()
-	if err := api.AddWatermarks(reader, &buf, pageSelection, wmManagerDate, conf); err != nil {
-		return fmt.Errorf("failed to add manager date: %w", err)
+	signed, err := stampElements(inputData, pageCount, elements, sigFile.Name(), conf)
+	if err != nil {
+		return nil, signError(KindInputPDF, inputPath, err)
 	}
 
-	reader = bytes.NewReader(buf.Bytes())
-	buf.Reset()
-	if err := api.AddWatermarks(reader, &buf, pageSelection, wmManagerHandtekening, conf); err != nil {
-		return fmt.Errorf("failed to add manager handtekening label: %w", err)
+	// The table is read from the input, before stamping, so the signature
+	// block does not end up in it
+	if opts.AttachTable != "" {
+		table, err := extractTable(ctx)
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("hours table not attached: %v", err))
+		} else {
+			data, err := table.encode(opts.AttachTable)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode hours table: %w", err)
+			}
+			name := tableAttachmentName(outputPath, opts.AttachTable)
+			if signed, err = attachFile(signed, name, "Hours table", data, conf); err != nil {
+				return nil, signError(KindInputPDF, inputPath, err)
+			}
+			result.Attachment = name
+		}
 	}
 
 	// Add metadata to mark the PDF as signed
 	timestamp := time.Now().Format(time.RFC3339)
 	properties := map[string]string{
-		"HoursSigned": timestamp,
+		propSigned: timestamp,
 	}
-	reader = bytes.NewReader(buf.Bytes())
-	buf.Reset()
-	if err := api.AddProperties(reader, &buf, properties, conf); err != nil {
-		return fmt.Errorf("failed to add signed metadata: %w", err)
+	if total != nil {
+		properties[propTotal] = formatHours(total.Hours, "en")
 	}
-
-	if err := os.WriteFile(outputPath, buf.Bytes(), 0644); err != nil {
-		return fmt.Errorf("failed to write output file: %w", err)
+	var buf bytes.Buffer
+	if err := api.AddProperties(bytes.NewReader(signed), &buf, properties, conf); err != nil {
+		return nil, signError(KindInputPDF, inputPath, fmt.Errorf("failed to add signed metadata: %w", err))
 	}
 
-	return nil
-}
-
-// ============================================================================
-// Main
-// ============================================================================
-
-func main() {
-	// Check if any flags are provided (CLI mode)
-	if len(os.Args) > 1 && (strings.HasPrefix(os.Args[1], "-") || strings.HasPrefix(os.Args[1], "--")) {
-		runCLI()
-		return
-	}
-
-	// No flags - run TUI
-	p := tea.NewProgram(initialModel())
-	if _, err := p.Run(); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
-	}
-}
-
-func runCLI() {
-	cfg := LoadConfig()
-
-	inputFile := flag.String("input", "", "Input PDF file (required)")
-	outputFile := flag.String("output", "", "Output PDF file (default: Urenstaat-<year>-<month>-signed.pdf)")
-	employeeName := flag.String("employee", cfg.EmployeeName, "Employee name")
-	managerName := flag.String("manager", cfg.ManagerName, "Manager name")
-	signaturePath := flag.String("signature", cfg.SignaturePath, "Path to signature image (PNG/JPG)")
-	initConfig := flag.Bool("init", false, "Initialize config file with defaults")
-	showConfig := flag.Bool("show-config", false, "Show current configuration")
-	showVersion := flag.Bool("version", false, "Show version information")
-	flag.Parse()
-
-	if *showVersion {
-		fmt.Printf("hours-signer v%s\n", version)
-		if latest, err := checkLatestVersion(); err == nil {
-			if latest != version {
-				fmt.Printf("Update available: v%s\n", latest)
-				fmt.Println("Run: brew upgrade hours-signer")
-			} else {
-				fmt.Println("You are running the latest version")
-			}
-		}
-		os.Exit(0)
+	if err := writeFileAtomic(outputPath, buf.Bytes(), outputFileMode(outputPath, opts.OutputMode)); err != nil {
+		return nil, signError(KindOutputWrite, outputPath, fmt.Errorf("failed to write output file: %w", err))
 	}
 
-	if *initConfig {
-		if err := SaveConfig(DefaultConfig()); err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
-		}
-		fmt.Printf("Config file created at: %s\n", ConfigPath())
-		os.Exit(0)
+	result.HistoryEntry = HistoryEntry{
+		Timestamp:    time.Now(),
+		Period:       opts.period(),
+		InputPath:    absPath(inputPath),
+		InputSHA256:  sha256Hex(inputData),
+		OutputPath:   absPath(outputPath),
+		OutputSHA256: sha256Hex(buf.Bytes()),
+		Employee:     employeeName,
+		Manager:      managerName,
+		Date:         values.Date,
+		Fields:       fieldMap(fields),
 	}
-
-	if *showConfig {
-		fmt.Printf("Config file: %s\n", ConfigPath())
-		fmt.Printf("Employee name: %s\n", cfg.EmployeeName)
-		fmt.Printf("Manager name: %s\n", cfg.ManagerName)
-		if cfg.SignaturePath != "" {
-			fmt.Printf("Signature path: %s\n", cfg.SignaturePath)
-		} else {
-			fmt.Println("Signature: (not configured)")
-		}
-		os.Exit(0)
+	if total != nil {
+		result.TotalHours = &total.Hours
+		result.TotalSource = total.Source
 	}
-
-	if *inputFile == "" {
-		fmt.Println("Error: -input is required")
-		flag.Usage()
-		os.Exit(1)
+	if err := AppendHistory(result.HistoryEntry); err != nil {
+		return result, fmt.Errorf("signed PDF written but not recorded in history: %w", err)
 	}
 
-	output := *outputFile
-	if output == "" {
-		now := time.Now()
-		output = fmt.Sprintf("Urenstaat-%d-%02d-signed.pdf", now.Year(), now.Month())
-	}
+	return result, nil
+}
 
-	if err := signPDF(*inputFile, output, *employeeName, *managerName, *signaturePath); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
-	}
+// ============================================================================
+// Main
+// ============================================================================
 
-	fmt.Printf("✓ Created signed PDF: %s\n", output)
-	fmt.Printf("  Employee: %s\n", *employeeName)
-	fmt.Printf("  Manager: %s\n", *managerName)
+func main() {
+	os.Exit(run(os.Args[1:]))
 }
@@ -0,0 +1,1094 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// ============================================================================
+// Page Content Analysis
+// ============================================================================
+
+// rect is an axis aligned rectangle in PDF user space (points, origin at the
+// bottom left)
+type rect struct {
+	X0, Y0, X1, Y1 float64
+}
+
+func (r rect) Width() float64  { return r.X1 - r.X0 }
+func (r rect) Height() float64 { return r.Y1 - r.Y0 }
+
+// Intersects reports whether r and o overlap with a non-zero area
+func (r rect) Intersects(o rect) bool {
+	return r.X0 < o.X1 && o.X0 < r.X1 && r.Y0 < o.Y1 && o.Y0 < r.Y1
+}
+
+// Contains reports whether o lies completely inside r
+func (r rect) Contains(o rect) bool {
+	return o.X0 >= r.X0 && o.X1 <= r.X1 && o.Y0 >= r.Y0 && o.Y1 <= r.Y1
+}
+
+func (r rect) Union(o rect) rect {
+	return rect{math.Min(r.X0, o.X0), math.Min(r.Y0, o.Y0), math.Max(r.X1, o.X1), math.Max(r.Y1, o.Y1)}
+}
+
+// MarshalJSON encodes r as [x0, y0, x1, y1], rounded to 0.01 pt
+func (r rect) MarshalJSON() ([]byte, error) {
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	return json.Marshal([4]float64{round(r.X0), round(r.Y0), round(r.X1), round(r.Y1)})
+}
+
+func (r rect) String() string {
+	return fmt.Sprintf("(%.0f,%.0f)-(%.0f,%.0f)", r.X0, r.Y0, r.X1, r.Y1)
+}
+
+// textRun is a piece of text drawn by a single text showing operator
+type textRun struct {
+	Text string
+	Box  rect
+	Size float64 // effective font size in points
+}
+
+// pageContent describes what is drawn on a page
+type pageContent struct {
+	PageNr int
+	Box    rect // visible region (crop box, or media box)
+	Texts  []textRun
+	Images []rect
+}
+
+// analyzePage extracts the text runs and image boxes of a page. A pageNr of
+// 0 selects the last page, which is where the signature block goes.
+func analyzePage(path string, pageNr int) (*pageContent, error) {
+	ctx, err := api.ReadContextFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF context: %w", err)
+	}
+	return analyzePageContext(ctx, pageNr)
+}
+
+// visibleBox returns the crop box of a page, or its media box if it has none
+func visibleBox(inh *pdfmodel.InheritedPageAttrs) rect {
+	visible := inh.MediaBox
+	if inh.CropBox != nil {
+		visible = inh.CropBox
+	}
+	if visible == nil {
+		return rect{}
+	}
+	return rect{visible.LL.X, visible.LL.Y, visible.UR.X, visible.UR.Y}
+}
+
+func analyzePageContext(ctx *pdfmodel.Context, pageNr int) (*pageContent, error) {
+	if pageNr == 0 {
+		pageNr = ctx.PageCount
+	}
+	pageDict, _, inh, err := ctx.PageDict(pageNr, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page %d: %w", pageNr, err)
+	}
+	if pageDict == nil || inh == nil {
+		return nil, fmt.Errorf("page %d not found", pageNr)
+	}
+
+	pc := &pageContent{PageNr: pageNr, Box: visibleBox(inh)}
+
+	content, err := ctx.PageContent(pageDict, pageNr)
+	if err == pdfmodel.ErrNoContent {
+		return pc, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page %d content: %w", pageNr, err)
+	}
+
+	ci := &contentInterpreter{xref: ctx.XRefTable, out: pc, fonts: map[string]*pdfFont{}}
+	ci.run(content, inh.Resources, identityMatrix, 0)
+	return pc, nil
+}
+
+// ----------------------------------------------------------------------------
+// Matrices
+// ----------------------------------------------------------------------------
+
+// matrix is a PDF transformation matrix [a b c d e f]
+type matrix [6]float64
+
+var identityMatrix = matrix{1, 0, 0, 1, 0, 0}
+
+// mul returns m × n, i.e. m applied first
+func (m matrix) mul(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func (m matrix) apply(x, y float64) (float64, float64) {
+	return x*m[0] + y*m[2] + m[4], x*m[1] + y*m[3] + m[5]
+}
+
+// bounds returns the bounding box of r transformed by m
+func (m matrix) bounds(r rect) rect {
+	x0, y0 := m.apply(r.X0, r.Y0)
+	out := rect{x0, y0, x0, y0}
+	for _, p := range [][2]float64{{r.X1, r.Y0}, {r.X0, r.Y1}, {r.X1, r.Y1}} {
+		x, y := m.apply(p[0], p[1])
+		out = out.Union(rect{x, y, x, y})
+	}
+	return out
+}
+
+// ----------------------------------------------------------------------------
+// Content stream tokens
+// ----------------------------------------------------------------------------
+
+// Operand values: float64, pdfString, pdfName, []any, map[string]any, bool, nil
+type pdfString []byte
+type pdfName string
+type pdfOperator string
+
+type contentLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFWhitespace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (l *contentLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isPDFWhitespace(c) {
+			l.pos++
+			continue
+		}
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		return
+	}
+}
+
+// next returns the next value or operator, and false at the end of data
+func (l *contentLexer) next() (any, bool) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, false
+	}
+	c := l.data[l.pos]
+	switch {
+	case c == '(':
+		return l.literalString(), true
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		return l.dict(), true
+	case c == '<':
+		return l.hexString(), true
+	case c == '[':
+		l.pos++
+		var arr []any
+		for {
+			l.skipSpace()
+			if l.pos >= len(l.data) {
+				return arr, true
+			}
+			if l.data[l.pos] == ']' {
+				l.pos++
+				return arr, true
+			}
+			v, ok := l.next()
+			if !ok {
+				return arr, true
+			}
+			arr = append(arr, v)
+		}
+	case c == '/':
+		l.pos++
+		return pdfName(l.regular()), true
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		// Stray delimiter: skip it
+		l.pos++
+		return pdfOperator(string(c)), true
+	}
+
+	word := l.regular()
+	if word == "" {
+		l.pos++
+		return pdfOperator(string(c)), true
+	}
+	if (word[0] >= '0' && word[0] <= '9') || word[0] == '-' || word[0] == '+' || word[0] == '.' {
+		if f, err := strconv.ParseFloat(word, 64); err == nil {
+			return f, true
+		}
+	}
+	switch word {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	case "null":
+		return nil, true
+	}
+	return pdfOperator(word), true
+}
+
+func (l *contentLexer) regular() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFWhitespace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	word := string(l.data[start:l.pos])
+	if strings.Contains(word, "#") {
+		// Names may contain #xx escapes
+		var b strings.Builder
+		for i := 0; i < len(word); i++ {
+			if word[i] == '#' && i+2 < len(word) {
+				if v, err := strconv.ParseUint(word[i+1:i+3], 16, 8); err == nil {
+					b.WriteByte(byte(v))
+					i += 2
+					continue
+				}
+			}
+			b.WriteByte(word[i])
+		}
+		word = b.String()
+	}
+	return word
+}
+
+func (l *contentLexer) dict() map[string]any {
+	d := map[string]any{}
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.data) {
+			return d
+		}
+		if l.data[l.pos] == '>' {
+			l.pos += 2
+			return d
+		}
+		k, ok := l.next()
+		if !ok {
+			return d
+		}
+		v, ok := l.next()
+		if !ok {
+			return d
+		}
+		if name, isName := k.(pdfName); isName {
+			d[string(name)] = v
+		}
+	}
+}
+
+func (l *contentLexer) literalString() pdfString {
+	l.pos++ // (
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+			out = append(out, c)
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+			out = append(out, c)
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r':
+				// Line continuation
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					out = append(out, byte(v))
+				} else {
+					out = append(out, e)
+				}
+			}
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (l *contentLexer) hexString() pdfString {
+	l.pos++ // <
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		c := l.data[l.pos]
+		if !isPDFWhitespace(c) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++ // >
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, 0, len(digits)/2)
+	for i := 0; i+1 < len(digits); i += 2 {
+		v, err := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		if err != nil {
+			continue
+		}
+		out = append(out, byte(v))
+	}
+	return out
+}
+
+// skipInlineImage moves past the binary data of an inline image (after ID)
+func (l *contentLexer) skipInlineImage() {
+	l.pos++ // single whitespace after ID
+	for l.pos+2 <= len(l.data) {
+		if l.data[l.pos] == 'E' && l.data[l.pos+1] == 'I' &&
+			(l.pos == 0 || isPDFWhitespace(l.data[l.pos-1])) &&
+			(l.pos+2 == len(l.data) || isPDFWhitespace(l.data[l.pos+2])) {
+			l.pos += 2
+			return
+		}
+		l.pos++
+	}
+	l.pos = len(l.data)
+}
+
+// ----------------------------------------------------------------------------
+// Fonts
+// ----------------------------------------------------------------------------
+
+// pdfFont holds what is needed to measure and decode text in a font
+type pdfFont struct {
+	twoByte    bool
+	firstChar  int
+	widths     []float64
+	cidWidths  map[int]float64
+	defaultW   float64
+	coreFont   string
+	toUnicode  map[int]string
+	difference map[int]string
+	macRoman   bool
+}
+
+func (f *pdfFont) codes(s []byte) []int {
+	var codes []int
+	if f.twoByte {
+		for i := 0; i+1 < len(s); i += 2 {
+			codes = append(codes, int(binary.BigEndian.Uint16(s[i:i+2])))
+		}
+		return codes
+	}
+	for _, b := range s {
+		codes = append(codes, int(b))
+	}
+	return codes
+}
+
+// width returns the glyph width of code in thousandths of the font size
+func (f *pdfFont) width(code int) float64 {
+	if f.twoByte {
+		if w, ok := f.cidWidths[code]; ok {
+			return w
+		}
+		return f.defaultW
+	}
+	if i := code - f.firstChar; i >= 0 && i < len(f.widths) {
+		return f.widths[i]
+	}
+	if f.coreFont != "" {
+		return float64(font.CharWidth(f.coreFont, rune(code)))
+	}
+	return f.defaultW
+}
+
+// text returns the unicode text for code
+func (f *pdfFont) text(code int) string {
+	if s, ok := f.toUnicode[code]; ok {
+		return s
+	}
+	if f.twoByte {
+		return "�"
+	}
+	if name, ok := f.difference[code]; ok {
+		if s := glyphNameText(name); s != "" {
+			return s
+		}
+	}
+	if f.macRoman {
+		if r, ok := macRomanHigh[byte(code)]; ok {
+			return string(r)
+		}
+	}
+	return winAnsiText(byte(code))
+}
+
+// cp1252 characters in the 0x80-0x9F range; everything else maps to Latin-1
+var winAnsiHigh = map[byte]rune{
+	0x80: '€', 0x82: '‚', 0x83: 'ƒ', 0x84: '„', 0x85: '…', 0x86: '†', 0x87: '‡',
+	0x88: 'ˆ', 0x89: '‰', 0x8A: 'Š', 0x8B: '‹', 0x8C: 'Œ', 0x8E: 'Ž', 0x91: '‘',
+	0x92: '’', 0x93: '“', 0x94: '”', 0x95: '•', 0x96: '–', 0x97: '—', 0x98: '˜',
+	0x99: '™', 0x9A: 'š', 0x9B: '›', 0x9C: 'œ', 0x9E: 'ž', 0x9F: 'Ÿ',
+}
+
+// MacRoman characters in the 0x80-0xFF range
+var macRomanHigh = map[byte]rune{
+	0x80: 'Ä', 0x81: 'Å', 0x82: 'Ç', 0x83: 'É', 0x84: 'Ñ', 0x85: 'Ö', 0x86: 'Ü', 0x87: 'á',
+	0x88: 'à', 0x89: 'â', 0x8A: 'ä', 0x8B: 'ã', 0x8C: 'å', 0x8D: 'ç', 0x8E: 'é', 0x8F: 'è',
+	0x90: 'ê', 0x91: 'ë', 0x92: 'í', 0x93: 'ì', 0x94: 'î', 0x95: 'ï', 0x96: 'ñ', 0x97: 'ó',
+	0x98: 'ò', 0x99: 'ô', 0x9A: 'ö', 0x9B: 'õ', 0x9C: 'ú', 0x9D: 'ù', 0x9E: 'û', 0x9F: 'ü',
+	0xA0: '†', 0xA1: '°', 0xA2: '¢', 0xA3: '£', 0xA4: '§', 0xA5: '•', 0xA6: '¶', 0xA7: 'ß',
+	0xA8: '®', 0xA9: '©', 0xAA: '™', 0xAB: '´', 0xAC: '¨', 0xAD: '≠', 0xAE: 'Æ', 0xAF: 'Ø',
+	0xB0: '∞', 0xB1: '±', 0xB2: '≤', 0xB3: '≥', 0xB4: '¥', 0xB5: 'µ', 0xB6: '∂', 0xB7: '∑',
+	0xB8: '∏', 0xB9: 'π', 0xBA: '∫', 0xBB: 'ª', 0xBC: 'º', 0xBD: 'Ω', 0xBE: 'æ', 0xBF: 'ø',
+	0xC0: '¿', 0xC1: '¡', 0xC2: '¬', 0xC3: '√', 0xC4: 'ƒ', 0xC5: '≈', 0xC6: '∆', 0xC7: '«',
+	0xC8: '»', 0xC9: '…', 0xCA: ' ', 0xCB: 'À', 0xCC: 'Ã', 0xCD: 'Õ', 0xCE: 'Œ', 0xCF: 'œ',
+	0xD0: '–', 0xD1: '—', 0xD2: '“', 0xD3: '”', 0xD4: '‘', 0xD5: '’', 0xD6: '÷', 0xD7: '◊',
+	0xD8: 'ÿ', 0xD9: 'Ÿ', 0xDA: '⁄', 0xDB: '€', 0xDC: '‹', 0xDD: '›', 0xDE: 'ﬁ', 0xDF: 'ﬂ',
+	0xE0: '‡', 0xE1: '·', 0xE2: '‚', 0xE3: '„', 0xE4: '‰', 0xE5: 'Â', 0xE6: 'Ê', 0xE7: 'Á',
+	0xE8: 'Ë', 0xE9: 'È', 0xEA: 'Í', 0xEB: 'Î', 0xEC: 'Ï', 0xED: 'Ì', 0xEE: 'Ó', 0xEF: 'Ô',
+	0xF0: '', 0xF1: 'Ò', 0xF2: 'Ú', 0xF3: 'Û', 0xF4: 'Ù', 0xF5: 'ı', 0xF6: 'ˆ', 0xF7: '˜',
+	0xF8: '¯', 0xF9: '˘', 0xFA: '˙', 0xFB: '˚', 0xFC: '¸', 0xFD: '˝', 0xFE: '˛', 0xFF: 'ˇ',
+}
+
+func winAnsiText(b byte) string {
+	if r, ok := winAnsiHigh[b]; ok {
+		return string(r)
+	}
+	if b < 0x20 {
+		return " "
+	}
+	return string(rune(b))
+}
+
+var glyphNames = map[string]string{
+	"space": " ", "period": ".", "comma": ",", "colon": ":", "semicolon": ";",
+	"hyphen": "-", "minus": "-", "slash": "/", "parenleft": "(", "parenright": ")",
+	"percent": "%", "plus": "+", "equal": "=", "underscore": "_", "quotesingle": "'",
+	"quotedbl": "\"", "numbersign": "#", "ampersand": "&", "at": "@", "endash": "–",
+	"emdash": "—", "euro": "€", "zero": "0", "one": "1", "two": "2", "three": "3",
+	"four": "4", "five": "5", "six": "6", "seven": "7", "eight": "8", "nine": "9",
+	"eacute": "é", "egrave": "è", "edieresis": "ë", "ecircumflex": "ê", "idieresis": "ï",
+	"odieresis": "ö", "udieresis": "ü", "adieresis": "ä", "aacute": "á", "agrave": "à",
+	"ccedilla": "ç", "oacute": "ó", "uacute": "ú", "iacute": "í",
+}
+
+func glyphNameText(name string) string {
+	if s, ok := glyphNames[name]; ok {
+		return s
+	}
+	if len(name) == 1 {
+		return name
+	}
+	if strings.HasPrefix(name, "uni") && len(name) == 7 {
+		if v, err := strconv.ParseUint(name[3:], 16, 32); err == nil {
+			return string(rune(v))
+		}
+	}
+	return ""
+}
+
+func (ci *contentInterpreter) number(o types.Object) float64 {
+	f, err := ci.xref.DereferenceNumber(o)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+// loadFont reads the width and encoding information of a font dict
+func (ci *contentInterpreter) loadFont(d types.Dict) *pdfFont {
+	f := &pdfFont{defaultW: 500}
+
+	subtype := ""
+	if st := d.Subtype(); st != nil {
+		subtype = *st
+	}
+
+	if subtype == "Type0" {
+		f.twoByte = true
+		f.defaultW = 1000
+		f.cidWidths = map[int]float64{}
+		if arr, err := ci.xref.DereferenceArray(d["DescendantFonts"]); err == nil && len(arr) > 0 {
+			if desc, err := ci.xref.DereferenceDict(arr[0]); err == nil && desc != nil {
+				if dw, ok := desc["DW"]; ok {
+					f.defaultW = ci.number(dw)
+				}
+				ci.loadCIDWidths(f, desc["W"])
+			}
+		}
+	} else {
+		if fc, ok := d["FirstChar"]; ok {
+			f.firstChar = int(ci.number(fc))
+		}
+		if arr, err := ci.xref.DereferenceArray(d["Widths"]); err == nil {
+			for _, w := range arr {
+				f.widths = append(f.widths, ci.number(w))
+			}
+		}
+		if base := d.NameEntry("BaseFont"); base != nil && len(f.widths) == 0 {
+			name := *base
+			if i := strings.IndexByte(name, '+'); i >= 0 {
+				name = name[i+1:]
+			}
+			if font.IsCoreFont(name) {
+				f.coreFont = name
+			}
+		}
+		if name, err := ci.xref.DereferenceName(d["Encoding"], pdfmodel.V10, nil); err == nil {
+			f.macRoman = name.Value() == "MacRomanEncoding"
+		}
+		if enc, err := ci.xref.DereferenceDict(d["Encoding"]); err == nil && enc != nil {
+			if base := enc.NameEntry("BaseEncoding"); base != nil {
+				f.macRoman = *base == "MacRomanEncoding"
+			}
+			f.difference = map[int]string{}
+			if diffs, err := ci.xref.DereferenceArray(enc["Differences"]); err == nil {
+				code := 0
+				for _, o := range diffs {
+					switch v := o.(type) {
+					case types.Integer:
+						code = v.Value()
+					case types.Float:
+						code = int(v.Value())
+					case types.Name:
+						f.difference[code] = v.Value()
+						code++
+					}
+				}
+			}
+		}
+	}
+
+	if sd, _, err := ci.xref.DereferenceStreamDict(d["ToUnicode"]); err == nil && sd != nil {
+		if err := sd.Decode(); err == nil {
+			f.toUnicode = parseToUnicode(sd.Content)
+		}
+	}
+	return f
+}
+
+func (ci *contentInterpreter) loadCIDWidths(f *pdfFont, o types.Object) {
+	arr, err := ci.xref.DereferenceArray(o)
+	if err != nil {
+		return
+	}
+	for i := 0; i < len(arr); {
+		first := int(ci.number(arr[i]))
+		if i+1 >= len(arr) {
+			return
+		}
+		if ws, err := ci.xref.DereferenceArray(arr[i+1]); err == nil && ws != nil {
+			for j, w := range ws {
+				f.cidWidths[first+j] = ci.number(w)
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= len(arr) {
+			return
+		}
+		last := int(ci.number(arr[i+1]))
+		w := ci.number(arr[i+2])
+		for c := first; c <= last && c-first < 65536; c++ {
+			f.cidWidths[c] = w
+		}
+		i += 3
+	}
+}
+
+// parseToUnicode reads the bfchar and bfrange sections of a ToUnicode CMap
+func parseToUnicode(data []byte) map[int]string {
+	m := map[int]string{}
+	l := &contentLexer{data: data}
+	var operands []any
+	mode := ""
+	for {
+		v, ok := l.next()
+		if !ok {
+			return m
+		}
+		op, isOp := v.(pdfOperator)
+		if !isOp {
+			if mode != "" {
+				operands = append(operands, v)
+			}
+			continue
+		}
+		switch op {
+		case "beginbfchar", "beginbfrange":
+			mode = string(op)
+			operands = nil
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					m[bytesToCode(src)] = utf16BEText(dst)
+				}
+			}
+			mode = ""
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 {
+					continue
+				}
+				start, end := bytesToCode(lo), bytesToCode(hi)
+				switch dst := operands[i+2].(type) {
+				case pdfString:
+					base := []rune(utf16BEText(dst))
+					if len(base) == 0 {
+						continue
+					}
+					for c := start; c <= end && c-start < 65536; c++ {
+						r := append([]rune{}, base...)
+						r[len(r)-1] += rune(c - start)
+						m[c] = string(r)
+					}
+				case []any:
+					for j, o := range dst {
+						if s, ok := o.(pdfString); ok {
+							m[start+j] = utf16BEText(s)
+						}
+					}
+				}
+			}
+			mode = ""
+		}
+	}
+}
+
+func bytesToCode(b []byte) int {
+	code := 0
+	for _, c := range b {
+		code = code<<8 | int(c)
+	}
+	return code
+}
+
+func utf16BEText(b []byte) string {
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		u = append(u, binary.BigEndian.Uint16(b[i:i+2]))
+	}
+	return string(utf16.Decode(u))
+}
+
+// ----------------------------------------------------------------------------
+// Interpreter
+// ----------------------------------------------------------------------------
+
+// maxFormDepth limits recursion into nested form XObjects
+const maxFormDepth = 8
+
+type graphicsState struct {
+	ctm         matrix
+	font        *pdfFont
+	fontSize    float64
+	charSpacing float64
+	wordSpacing float64
+	hScale      float64
+	leading     float64
+	rise        float64
+}
+
+type contentInterpreter struct {
+	xref  *pdfmodel.XRefTable
+	out   *pageContent
+	fonts map[string]*pdfFont
+}
+
+func (ci *contentInterpreter) font(resources types.Dict, name string) *pdfFont {
+	fonts, err := ci.xref.DereferenceDict(resources["Font"])
+	if err != nil || fonts == nil {
+		return &pdfFont{defaultW: 500}
+	}
+	o, ok := fonts[name]
+	if !ok {
+		return &pdfFont{defaultW: 500}
+	}
+	key := name
+	if ref, ok := o.(types.IndirectRef); ok {
+		key = ref.String()
+	}
+	if f, ok := ci.fonts[key]; ok {
+		return f
+	}
+	d, err := ci.xref.DereferenceDict(o)
+	if err != nil || d == nil {
+		return &pdfFont{defaultW: 500}
+	}
+	f := ci.loadFont(d)
+	ci.fonts[key] = f
+	return f
+}
+
+func operandFloats(operands []any, n int) ([]float64, bool) {
+	if len(operands) < n {
+		return nil, false
+	}
+	out := make([]float64, n)
+	for i, o := range operands[len(operands)-n:] {
+		f, ok := o.(float64)
+		if !ok {
+			return nil, false
+		}
+		out[i] = f
+	}
+	return out, true
+}
+
+func (ci *contentInterpreter) run(content []byte, resources types.Dict, ctm matrix, depth int) {
+	resources, _ = ci.xref.DereferenceDict(resources)
+	gs := graphicsState{ctm: ctm, hScale: 1, font: &pdfFont{defaultW: 500}}
+	var stack []graphicsState
+	var tm, tlm matrix
+	var operands []any
+
+	// show advances the text matrix over s and records the drawn text
+	show := func(s pdfString, adjustments []float64) {
+		if gs.font == nil {
+			return
+		}
+		var text strings.Builder
+		advance := 0.0
+		codes := gs.font.codes(s)
+		for i, code := range codes {
+			w := gs.font.width(code) / 1000
+			tx := w*gs.fontSize + gs.charSpacing
+			if !gs.font.twoByte && code == 32 {
+				tx += gs.wordSpacing
+			}
+			advance += tx * gs.hScale
+			if i < len(adjustments) {
+				advance -= adjustments[i] / 1000 * gs.fontSize * gs.hScale
+			}
+			text.WriteString(gs.font.text(code))
+		}
+		if advance != 0 || text.Len() > 0 {
+			trm := tm.mul(gs.ctm)
+			box := trm.bounds(rect{0, gs.rise - 0.2*gs.fontSize, advance, gs.rise + 0.8*gs.fontSize})
+			size := gs.fontSize * math.Hypot(trm[2], trm[3])
+			if strings.TrimSpace(text.String()) != "" {
+				ci.out.Texts = append(ci.out.Texts, textRun{Text: text.String(), Box: box, Size: size})
+			}
+		}
+		tm = matrix{1, 0, 0, 1, advance, 0}.mul(tm)
+	}
+
+	nextLine := func(tx, ty float64) {
+		tlm = matrix{1, 0, 0, 1, tx, ty}.mul(tlm)
+		tm = tlm
+	}
+
+	l := &contentLexer{data: content}
+	for {
+		v, ok := l.next()
+		if !ok {
+			return
+		}
+		op, isOp := v.(pdfOperator)
+		if !isOp {
+			operands = append(operands, v)
+			continue
+		}
+
+		switch op {
+		case "q":
+			stack = append(stack, gs)
+		case "Q":
+			if len(stack) > 0 {
+				gs = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case "cm":
+			if f, ok := operandFloats(operands, 6); ok {
+				gs.ctm = matrix{f[0], f[1], f[2], f[3], f[4], f[5]}.mul(gs.ctm)
+			}
+		case "BT":
+			tm, tlm = identityMatrix, identityMatrix
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[len(operands)-2].(pdfName); ok {
+					gs.font = ci.font(resources, string(name))
+				}
+				if size, ok := operands[len(operands)-1].(float64); ok {
+					gs.fontSize = size
+				}
+			}
+		case "Tc":
+			if f, ok := operandFloats(operands, 1); ok {
+				gs.charSpacing = f[0]
+			}
+		case "Tw":
+			if f, ok := operandFloats(operands, 1); ok {
+				gs.wordSpacing = f[0]
+			}
+		case "Tz":
+			if f, ok := operandFloats(operands, 1); ok {
+				gs.hScale = f[0] / 100
+			}
+		case "TL":
+			if f, ok := operandFloats(operands, 1); ok {
+				gs.leading = f[0]
+			}
+		case "Ts":
+			if f, ok := operandFloats(operands, 1); ok {
+				gs.rise = f[0]
+			}
+		case "Td":
+			if f, ok := operandFloats(operands, 2); ok {
+				nextLine(f[0], f[1])
+			}
+		case "TD":
+			if f, ok := operandFloats(operands, 2); ok {
+				gs.leading = -f[1]
+				nextLine(f[0], f[1])
+			}
+		case "Tm":
+			if f, ok := operandFloats(operands, 6); ok {
+				tlm = matrix{f[0], f[1], f[2], f[3], f[4], f[5]}
+				tm = tlm
+			}
+		case "T*":
+			nextLine(0, -gs.leading)
+		case "Tj":
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].(pdfString); ok {
+					show(s, nil)
+				}
+			}
+		case "'":
+			nextLine(0, -gs.leading)
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].(pdfString); ok {
+					show(s, nil)
+				}
+			}
+		case "\"":
+			if len(operands) >= 3 {
+				if f, ok := operandFloats(operands[:len(operands)-1], 2); ok {
+					gs.wordSpacing, gs.charSpacing = f[0], f[1]
+				}
+				nextLine(0, -gs.leading)
+				if s, ok := operands[len(operands)-1].(pdfString); ok {
+					show(s, nil)
+				}
+			}
+		case "TJ":
+			if len(operands) == 0 {
+				break
+			}
+			arr, ok := operands[len(operands)-1].([]any)
+			if !ok {
+				break
+			}
+			// Join the array into one string with per-glyph adjustments so a
+			// kerned word becomes a single run
+			var s pdfString
+			var adjustments []float64
+			bytesPerCode := 1
+			if gs.font.twoByte {
+				bytesPerCode = 2
+			}
+			pending := 0.0
+			for _, el := range arr {
+				switch e := el.(type) {
+				case pdfString:
+					for i := 0; i+bytesPerCode <= len(e); i += bytesPerCode {
+						// Adjustments apply after the glyph they follow, so add
+						// any pending one to the previous glyph
+						if pending != 0 && len(adjustments) > 0 {
+							adjustments[len(adjustments)-1] += pending
+							pending = 0
+						}
+						s = append(s, e[i:i+bytesPerCode]...)
+						adjustments = append(adjustments, 0)
+					}
+				case float64:
+					// Large gaps separate words or columns: flush the run
+					if math.Abs(e) > 2000 && len(s) > 0 {
+						show(s, adjustments)
+						s, adjustments = nil, nil
+						tm = matrix{1, 0, 0, 1, -e / 1000 * gs.fontSize * gs.hScale, 0}.mul(tm)
+						continue
+					}
+					if len(adjustments) > 0 {
+						adjustments[len(adjustments)-1] += e
+					} else {
+						tm = matrix{1, 0, 0, 1, -e / 1000 * gs.fontSize * gs.hScale, 0}.mul(tm)
+					}
+				}
+			}
+			if len(s) > 0 {
+				show(s, adjustments)
+			}
+		case "Do":
+			if len(operands) > 0 {
+				if name, ok := operands[len(operands)-1].(pdfName); ok {
+					ci.xobject(resources, string(name), gs.ctm, depth)
+				}
+			}
+		case "BI":
+			// Inline image: skip its dictionary and data
+			for {
+				v, ok := l.next()
+				if !ok {
+					return
+				}
+				if op, isOp := v.(pdfOperator); isOp && op == "ID" {
+					break
+				}
+			}
+			l.skipInlineImage()
+			ci.out.Images = append(ci.out.Images, gs.ctm.bounds(rect{0, 0, 1, 1}))
+		}
+		operands = operands[:0]
+	}
+}
+
+func (ci *contentInterpreter) xobject(resources types.Dict, name string, ctm matrix, depth int) {
+	xobjects, err := ci.xref.DereferenceDict(resources["XObject"])
+	if err != nil || xobjects == nil {
+		return
+	}
+	sd, _, err := ci.xref.DereferenceStreamDict(xobjects[name])
+	if err != nil || sd == nil {
+		return
+	}
+	subtype := sd.Dict.Subtype()
+	if subtype == nil {
+		return
+	}
+	switch *subtype {
+	case "Image":
+		ci.out.Images = append(ci.out.Images, ctm.bounds(rect{0, 0, 1, 1}))
+	case "Form":
+		if depth >= maxFormDepth {
+			return
+		}
+		m := identityMatrix
+		if arr, err := ci.xref.DereferenceArray(sd.Dict["Matrix"]); err == nil && len(arr) == 6 {
+			for i := range m {
+				m[i] = ci.number(arr[i])
+			}
+		}
+		if err := sd.Decode(); err != nil {
+			return
+		}
+		formResources, _ := ci.xref.DereferenceDict(sd.Dict["Resources"])
+		if formResources == nil {
+			formResources = resources
+		}
+		ci.run(sd.Content, formResources, m.mul(ctm), depth+1)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Text lines
+// ----------------------------------------------------------------------------
+
+// textLine is a sequence of runs that share a baseline, ordered left to right
+type textLine struct {
+	Text string
+	Box  rect
+	Runs []textRun
+}
+
+// textLines groups the text runs of a page into lines, top to bottom
+func (pc *pageContent) textLines() []textLine {
+	runs := append([]textRun{}, pc.Texts...)
+	sort.SliceStable(runs, func(i, j int) bool {
+		ci, cj := centerY(runs[i].Box), centerY(runs[j].Box)
+		if math.Abs(ci-cj) > 0.01 {
+			return ci > cj
+		}
+		return runs[i].Box.X0 < runs[j].Box.X0
+	})
+
+	var lines []textLine
+	for _, r := range runs {
+		if n := len(lines); n > 0 {
+			last := &lines[n-1]
+			tolerance := math.Max(2, r.Box.Height()/3)
+			if math.Abs(centerY(last.Box)-centerY(r.Box)) <= tolerance {
+				last.Runs = append(last.Runs, r)
+				last.Box = last.Box.Union(r.Box)
+				continue
+			}
+		}
+		lines = append(lines, textLine{Box: r.Box, Runs: []textRun{r}})
+	}
+
+	for i := range lines {
+		sort.SliceStable(lines[i].Runs, func(a, b int) bool { return lines[i].Runs[a].Box.X0 < lines[i].Runs[b].Box.X0 })
+		var b bytes.Buffer
+		prev := 0.0
+		for j, r := range lines[i].Runs {
+			// Separate runs with a space when there is a visible gap
+			if j > 0 && r.Box.X0-prev > r.Size*0.15 && !strings.HasSuffix(b.String(), " ") {
+				b.WriteByte(' ')
+			}
+			b.WriteString(r.Text)
+			prev = r.Box.X1
+		}
+		lines[i].Text = strings.TrimSpace(b.String())
+	}
+	return lines
+}
+
+func centerY(r rect) float64 {
+	return (r.Y0 + r.Y1) / 2
+}
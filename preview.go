@@ -0,0 +1,384 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// ============================================================================
+// Layout Preview
+// ============================================================================
+
+// collision is existing page content overlapped by a stamp element
+type collision struct {
+	Kind string // "text" or "image"
+	Text string
+	Box  rect
+}
+
+type previewItem struct {
+	Element    stampElement
+	Box        rect
+	OffPage    bool
+	Collisions []collision
+}
+
+// layoutPreview is what signPDF would place on a page, checked against the
+// content already there
+type layoutPreview struct {
+	InputPath string
+	Page      *pageContent
+	Doc       documentInfo
+	Template  *LayoutTemplate // nil when no template matches
+	Layout    Layout
+	Items     []previewItem
+	Total     *TotalHours // nil when the total hours are off
+
+	values     blockValues
+	imgW, imgH int
+}
+
+// Collisions returns the number of elements that overlap content or leave the page
+func (p *layoutPreview) Collisions() int {
+	n := 0
+	for _, item := range p.Items {
+		if item.OffPage || len(item.Collisions) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// previewSignPDF computes where signPDF would stamp the signature block on
+// inputPath and which existing text and images it would cover. Nothing is
+// written.
+func previewSignPDF(inputPath string, opts SignOptions) (*layoutPreview, error) {
+	ctx, err := api.ReadContextFile(inputPath)
+	if err != nil {
+		return nil, signError(KindInputPDF, inputPath, fmt.Errorf("failed to read PDF context: %w", err))
+	}
+	page, err := analyzePageContext(ctx, 0)
+	if err != nil {
+		return nil, signError(KindInputPDF, inputPath, err)
+	}
+	if opts.Period == "" && opts.DocumentPeriod == "" {
+		opts.DocumentPeriod = contextPeriod(ctx)
+	}
+
+	sigData, err := getSignatureData(opts.SignaturePath)
+	if err != nil {
+		return nil, err
+	}
+	imgW, imgH, err := imageSize(sigData)
+	if err != nil {
+		return nil, signError(KindSignatureImage, opts.SignaturePath, err)
+	}
+
+	// Required fields without a value get a placeholder, so the preview
+	// shows where they go
+	fields, _ := resolveFields(opts.FieldDefs, opts.Fields, opts.Language, true)
+	p := &layoutPreview{
+		InputPath: inputPath,
+		Page:      page,
+		Doc:       newDocumentInfo(ctx, page),
+		values: blockValues{
+			Employee: opts.EmployeeName,
+			Manager:  opts.ManagerName,
+			Date:     opts.signingDate(),
+			Language: opts.Language,
+			Fields:   fields,
+		},
+		imgW: imgW,
+		imgH: imgH,
+	}
+	if p.Template, err = matchLayout(opts.Layouts, p.Doc); err != nil {
+		return nil, signError(KindConfig, ConfigPath(), err)
+	}
+	if p.Total, err = signingTotal(ctx, opts); err != nil {
+		return nil, signError(KindInputPDF, inputPath, err)
+	}
+	if p.Total != nil && p.Total.Confident && opts.Total == totalStamp {
+		p.values.Total = formatHours(p.Total.Hours, opts.Language)
+	}
+	p.place(p.templateLayout())
+	return p, nil
+}
+
+// templateLayout returns the layout of the matched template, or the default
+func (p *layoutPreview) templateLayout() Layout {
+	if p.Template == nil {
+		return Layout{}
+	}
+	return p.Template.Layout
+}
+
+// templateName describes the matched template, or the document when none matches
+func (p *layoutPreview) templateName() string {
+	if p.Template == nil {
+		return fmt.Sprintf("No layout template for %s", p.Doc)
+	}
+	return "Layout template: " + p.Template.Name
+}
+
+// place positions the signature block according to layout and recomputes
+// the collisions
+func (p *layoutPreview) place(layout Layout) {
+	p.Layout = layout
+	p.Items = nil
+	for _, e := range signatureBlock(p.values, layout) {
+		p.Items = append(p.Items, placeElement(p.Page, e, p.imgW, p.imgH))
+	}
+}
+
+// placeElement computes the box of e on page and what it covers
+func placeElement(page *pageContent, e stampElement, imgW, imgH int) previewItem {
+	item := previewItem{Element: e, Box: e.bounds(page.Box, imgW, imgH)}
+	item.OffPage = !page.Box.Contains(item.Box)
+	for _, t := range page.Texts {
+		if item.Box.Intersects(t.Box) {
+			item.Collisions = append(item.Collisions, collision{Kind: "text", Text: t.Text, Box: t.Box})
+		}
+	}
+	for _, img := range page.Images {
+		if item.Box.Intersects(img) {
+			item.Collisions = append(item.Collisions, collision{Kind: "image", Box: img})
+		}
+	}
+	return item
+}
+
+// writeReport prints the element boxes and collisions of p
+func (p *layoutPreview) writeReport(w io.Writer) {
+	fmt.Fprintf(w, "Page %d of %s: %.0f x %.0f pt, %d text runs, %d images\n",
+		p.Page.PageNr, p.InputPath, p.Page.Box.Width(), p.Page.Box.Height(), len(p.Page.Texts), len(p.Page.Images))
+	fmt.Fprintln(w, p.templateName())
+	if p.Template != nil {
+		fmt.Fprintf(w, "  offset %+g %+g pt, signature scale %g\n", p.Layout.OffsetX, p.Layout.OffsetY, p.Layout.signatureScale())
+	}
+	if p.Total != nil {
+		fmt.Fprintf(w, "Total hours: %s\n", p.Total)
+	}
+	fmt.Fprintln(w)
+
+	for _, item := range p.Items {
+		status := "✓"
+		if item.OffPage || len(item.Collisions) > 0 {
+			status = "✗"
+		}
+		fmt.Fprintf(w, "%s %-28s %s\n", status, item.Element.Name, item.Box)
+		if item.OffPage {
+			fmt.Fprintln(w, "    extends beyond the page")
+		}
+		for _, c := range item.Collisions {
+			if c.Kind == "image" {
+				fmt.Fprintf(w, "    overlaps image %s\n", c.Box)
+			} else {
+				fmt.Fprintf(w, "    overlaps text %s %q\n", c.Box, truncate(c.Text, 40))
+			}
+		}
+	}
+
+	fmt.Fprintln(w)
+	if n := p.Collisions(); n > 0 {
+		fmt.Fprintf(w, "%d of %d elements collide with existing content\n", n, len(p.Items))
+	} else {
+		fmt.Fprintln(w, "No collisions: the signature block fits")
+	}
+}
+
+// writeJSON prints the report of p as JSON
+func (p *layoutPreview) writeJSON() {
+	type jsonCollision struct {
+		Kind string `json:"kind"`
+		Text string `json:"text,omitempty"`
+		Box  rect   `json:"box"`
+	}
+	type jsonElement struct {
+		Name       string          `json:"name"`
+		Box        rect            `json:"box"`
+		OffPage    bool            `json:"off_page"`
+		Collisions []jsonCollision `json:"collisions"`
+	}
+	out := struct {
+		OK             bool          `json:"ok"`
+		Input          string        `json:"input"`
+		Page           int           `json:"page"`
+		PageBox        rect          `json:"page_box"`
+		LayoutTemplate string        `json:"layout_template,omitempty"`
+		Layout         Layout        `json:"layout"`
+		Total          *TotalHours   `json:"total,omitempty"`
+		Elements       []jsonElement `json:"elements"`
+		Warnings       []string      `json:"warnings,omitempty"`
+	}{
+		OK:       p.Collisions() == 0,
+		Input:    p.InputPath,
+		Page:     p.Page.PageNr,
+		PageBox:  p.Page.Box,
+		Layout:   p.Layout,
+		Total:    p.Total,
+		Elements: []jsonElement{},
+	}
+	if p.Template != nil {
+		out.LayoutTemplate = p.Template.Name
+	}
+	if p.Total != nil && !p.Total.Confident {
+		out.Warnings = append(out.Warnings, "total hours uncertain: "+p.Total.Reason)
+	}
+	for _, item := range p.Items {
+		e := jsonElement{Name: item.Element.Name, Box: item.Box, OffPage: item.OffPage, Collisions: []jsonCollision{}}
+		for _, c := range item.Collisions {
+			e.Collisions = append(e.Collisions, jsonCollision(c))
+		}
+		out.Elements = append(out.Elements, e)
+		if item.OffPage {
+			out.Warnings = append(out.Warnings, fmt.Sprintf("%s extends beyond the page", item.Element.Name))
+		} else if len(item.Collisions) > 0 {
+			out.Warnings = append(out.Warnings, fmt.Sprintf("%s overlaps existing content", item.Element.Name))
+		}
+	}
+	writeJSON(out)
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// schematic renders the page as a cols × rows character grid: existing text
+// lines as '░', images as '▒', stamp elements as '█' and collisions as 'X'
+func (p *layoutPreview) schematic(cols, rows int) []string {
+	grid := make([][]rune, rows)
+	for i := range grid {
+		grid[i] = []rune(strings.Repeat(" ", cols))
+	}
+	page := p.Page.Box
+	fill := func(r rect, paint func(old rune) rune) {
+		c0, r0, c1, r1 := gridCells(page, r, cols, rows)
+		for y := r0; y <= r1; y++ {
+			for x := c0; x <= c1; x++ {
+				grid[y][x] = paint(grid[y][x])
+			}
+		}
+	}
+
+	for _, img := range p.Page.Images {
+		fill(img, func(rune) rune { return '▒' })
+	}
+	for _, line := range p.Page.textLines() {
+		fill(line.Box, func(old rune) rune {
+			if old == '▒' {
+				return old
+			}
+			return '░'
+		})
+	}
+	for _, item := range p.Items {
+		fill(item.Box, func(old rune) rune {
+			if old == ' ' || old == '█' {
+				return '█'
+			}
+			return 'X'
+		})
+	}
+
+	lines := make([]string, rows)
+	for i, row := range grid {
+		lines[i] = string(row)
+	}
+	return lines
+}
+
+// gridCells maps r on page to inclusive grid cell ranges, clamped to the grid.
+// Rows count from the top.
+func gridCells(page, r rect, cols, rows int) (c0, r0, c1, r1 int) {
+	sx := float64(cols) / page.Width()
+	sy := float64(rows) / page.Height()
+	clamp := func(v, max int) int {
+		if v < 0 {
+			return 0
+		}
+		if v > max {
+			return max
+		}
+		return v
+	}
+	c0 = clamp(int((r.X0-page.X0)*sx), cols-1)
+	c1 = clamp(int((r.X1-page.X0)*sx), cols-1)
+	r0 = clamp(int((page.Y1-r.Y1)*sy), rows-1)
+	r1 = clamp(int((page.Y1-r.Y0)*sy), rows-1)
+	return
+}
+
+func runPreview(args []string) int {
+	cfg := LoadConfig()
+
+	fs := newFlagSet("preview", "[flags] <input.pdf>", "Show where the signature block would be placed and whether it covers existing content.\nNothing is written. Exits with 1 if any element collides with the page content.")
+	employeeName := fs.String("employee", cfg.EmployeeName, "Employee name")
+	managerName := fs.String("manager", cfg.ManagerName, "Manager name")
+	signaturePath := fs.String("signature", cfg.SignaturePath, "Path to signature image (PNG/JPG)")
+	fields := keyValueFlag{}
+	fs.Var(fields, "field", "Value of an extra field, as `key=value` (repeatable)")
+	lang := fs.String("lang", cfg.language(), "Language of the signature block labels: nl or en")
+	total := fs.String("total", "", "Total hours, instead of the total read from the PDF")
+	totalMode := fs.String("total-mode", cfg.totalMode(), "What to do with the total hours: off, record in the metadata, or stamp")
+	ascii := fs.Bool("ascii", false, "Also draw a schematic of the page")
+	jsonOut := fs.Bool("json", false, "Print the report as JSON")
+	positional, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: exactly one input PDF is required")
+		fs.Usage()
+		return exitUsage
+	}
+
+	opts := signOptionsFromConfig(cfg)
+	opts.EmployeeName = *employeeName
+	opts.ManagerName = *managerName
+	opts.SignaturePath = *signaturePath
+	opts.Fields = fields
+	if err := validateLanguage(*lang); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -lang %v\n", err)
+		return exitUsage
+	}
+	opts.Language = *lang
+	if code := applyTotal(&opts, *total, *totalMode); code != exitOK {
+		return code
+	}
+	return preview(positional[0], opts, *ascii, *jsonOut)
+}
+
+func preview(input string, opts SignOptions, ascii, jsonOut bool) int {
+	p, err := previewSignPDF(input, opts)
+	if err != nil {
+		return fail(jsonOut, err)
+	}
+	if jsonOut {
+		p.writeJSON()
+		if p.Collisions() > 0 {
+			return exitError
+		}
+		return exitOK
+	}
+	if ascii {
+		fmt.Println("+" + strings.Repeat("-", 60) + "+")
+		for _, line := range p.schematic(60, 39) {
+			fmt.Println("|" + line + "|")
+		}
+		fmt.Println("+" + strings.Repeat("-", 60) + "+")
+		fmt.Println()
+	}
+	p.writeReport(os.Stdout)
+	if p.Collisions() > 0 {
+		return exitError
+	}
+	return exitOK
+}
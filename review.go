@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// ============================================================================
+// Approval and Rejection
+// ============================================================================
+
+// Document properties set by signing and review
+const (
+	propSigned       = "HoursSigned"
+	propApproved     = "HoursApproved"
+	propRejected     = "HoursRejected"
+	propReviewer     = "HoursReviewer"
+	propRejectReason = "HoursRejectReason"
+	propTotal        = "HoursTotal"
+)
+
+// ErrAlreadyReviewed is returned when approving or rejecting a PDF that has
+// already been approved or rejected
+var ErrAlreadyReviewed = errors.New("PDF is already reviewed")
+
+// signState is how far a PDF has got through signing and review
+type signState int
+
+const (
+	stateUnsigned signState = iota
+	stateSigned
+	stateApproved
+	stateRejected
+)
+
+func (s signState) String() string {
+	switch s {
+	case stateSigned:
+		return "signed"
+	case stateApproved:
+		return decisionApproved
+	case stateRejected:
+		return decisionRejected
+	}
+	return "unsigned"
+}
+
+// stateFromProperties returns the state recorded in the custom properties of
+// a PDF. If a PDF was both approved and rejected the latest decision counts.
+func stateFromProperties(props map[string]string) signState {
+	approved, rejected := props[propApproved], props[propRejected]
+	switch {
+	case approved != "" && rejected != "":
+		a, _ := time.Parse(time.RFC3339, approved)
+		r, _ := time.Parse(time.RFC3339, rejected)
+		if a.After(r) {
+			return stateApproved
+		}
+		return stateRejected
+	case rejected != "":
+		return stateRejected
+	case approved != "":
+		return stateApproved
+	case props[propSigned] != "":
+		return stateSigned
+	}
+	return stateUnsigned
+}
+
+// readSignState returns the state of the PDF at path; unreadable files count
+// as unsigned
+func readSignState(path string) signState {
+	props, err := readPDFProperties(path)
+	if err != nil {
+		return stateUnsigned
+	}
+	return stateFromProperties(props)
+}
+
+// ReviewOptions configures approving or rejecting a PDF
+type ReviewOptions struct {
+	Decision    string // decisionApproved or decisionRejected
+	ManagerName string
+	Reason      string // required for rejections
+	Force       bool   // review again and overwrite an existing output file
+	OutputMode  os.FileMode
+	Layouts     []LayoutTemplate
+}
+
+// defaultReviewOutputName returns the output name for a reviewed PDF, e.g.
+// Urenstaat-2025-01-rejected.pdf for Urenstaat-2025-01-signed.pdf
+func defaultReviewOutputName(inputPath, decision string) string {
+	ext := filepath.Ext(inputPath)
+	base := strings.TrimSuffix(strings.TrimSuffix(inputPath, ext), "-signed")
+	return base + "-" + decision + ".pdf"
+}
+
+// reviewPDF stamps an approval or rejection block onto the last page of
+// inputPath and writes the result to outputPath. Like signPDF it returns
+// *SignError values and records the result in the history.
+func reviewPDF(inputPath, outputPath string, opts ReviewOptions) (*SignResult, error) {
+	if opts.Decision == decisionRejected && strings.TrimSpace(opts.Reason) == "" {
+		return nil, signError(KindUsage, "", fmt.Errorf("a reason is required to reject a PDF"))
+	}
+	if err := validateName(opts.ManagerName); err != nil {
+		return nil, signError(KindConfig, ConfigPath(), fmt.Errorf("manager name: %w", err))
+	}
+
+	unlock, err := lockOutput(outputPath)
+	if err != nil {
+		return nil, signError(KindOutputWrite, outputPath, err)
+	}
+	defer unlock()
+
+	if !opts.Force {
+		if state := readSignState(inputPath); state == stateApproved || state == stateRejected {
+			return nil, signError(KindAlreadySigned, inputPath, fmt.Errorf("%w: %s is %s (use -force to review again)", ErrAlreadyReviewed, inputPath, state))
+		}
+		if fileExists(outputPath) {
+			return nil, signError(KindOutputExists, outputPath, fmt.Errorf("%w: %s (use -force to overwrite)", ErrOutputExists, outputPath))
+		}
+	}
+
+	inputData, err := os.ReadFile(inputPath)
+	if err != nil {
+		return nil, signError(KindInputPDF, inputPath, fmt.Errorf("failed to read input file: %w", err))
+	}
+	ctx, err := api.ReadContextFile(inputPath)
+	if err != nil {
+		return nil, signError(KindInputPDF, inputPath, fmt.Errorf("failed to read PDF context: %w", err))
+	}
+	page, err := analyzePageContext(ctx, ctx.PageCount)
+	if err != nil {
+		return nil, signError(KindInputPDF, inputPath, err)
+	}
+	// The review block moves along with the signature block
+	var layout Layout
+	template, err := matchLayout(opts.Layouts, newDocumentInfo(ctx, page))
+	if err != nil {
+		return nil, signError(KindConfig, ConfigPath(), err)
+	}
+	result := &SignResult{}
+	if template != nil {
+		layout = template.Layout
+		result.LayoutTemplate = template.Name
+	}
+
+	now := time.Now()
+	elements := reviewBlock(opts.Decision, opts.ManagerName, now.Format("02-01-2006"), opts.Reason, layout)
+	for _, e := range elements {
+		if item := placeElement(page, e, 0, 0); item.OffPage {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s extends beyond the page", e.Name))
+		} else if len(item.Collisions) > 0 {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s overlaps existing content", e.Name))
+		}
+	}
+
+	conf := pdfmodel.NewDefaultConfiguration()
+	stamped, err := stampElements(inputData, ctx.PageCount, elements, "", conf)
+	if err != nil {
+		return nil, signError(KindInputPDF, inputPath, err)
+	}
+
+	properties := map[string]string{propReviewer: opts.ManagerName}
+	if opts.Decision == decisionRejected {
+		properties[propRejected] = now.Format(time.RFC3339)
+		properties[propRejectReason] = opts.Reason
+	} else {
+		properties[propApproved] = now.Format(time.RFC3339)
+	}
+	var buf bytes.Buffer
+	if err := api.AddProperties(bytes.NewReader(stamped), &buf, properties, conf); err != nil {
+		return nil, signError(KindInputPDF, inputPath, fmt.Errorf("failed to add review metadata: %w", err))
+	}
+
+	if err := writeFileAtomic(outputPath, buf.Bytes(), outputFileMode(outputPath, opts.OutputMode)); err != nil {
+		return nil, signError(KindOutputWrite, outputPath, fmt.Errorf("failed to write output file: %w", err))
+	}
+
+	// Keep the period and employee of the signing that produced the input
+	signing := entryForSignedPDF(inputPath, Config{})
+	result.HistoryEntry = HistoryEntry{
+		Timestamp:    now,
+		Period:       signing.Period,
+		InputPath:    absPath(inputPath),
+		InputSHA256:  sha256Hex(inputData),
+		OutputPath:   absPath(outputPath),
+		OutputSHA256: sha256Hex(buf.Bytes()),
+		Employee:     signing.Employee,
+		Manager:      opts.ManagerName,
+		Action:       opts.Decision,
+		Reason:       opts.Reason,
+	}
+	if err := AppendHistory(result.HistoryEntry); err != nil {
+		return result, fmt.Errorf("reviewed PDF written but not recorded in history: %w", err)
+	}
+	return result, nil
+}
+
+func runApprove(args []string) int {
+	return runReview(decisionApproved, args)
+}
+
+func runReject(args []string) int {
+	return runReview(decisionRejected, args)
+}
+
+// runReview implements the approve and reject commands
+func runReview(decision string, args []string) int {
+	cfg := LoadConfig()
+
+	name, summary := "approve", "Stamp a \"Goedgekeurd / Approved\" block with the manager's name and date on the\nlast page of a signed PDF and set the HoursApproved property."
+	if decision == decisionRejected {
+		name, summary = "reject", "Stamp an \"Afgekeurd / Rejected\" block with the manager's name, date and a reason\non the last page of a signed PDF and set the HoursRejected property."
+	}
+	fs := newFlagSet(name, "[flags] <signed.pdf>", summary)
+	outputFile := fs.String("output", "", fmt.Sprintf("Output PDF file (default: the input name ending in -%s.pdf)", decision))
+	managerName := fs.String("manager", cfg.ManagerName, "Manager name")
+	reason := fs.String("reason", "", "Reason for the rejection")
+	if decision == decisionApproved {
+		fs.Lookup("reason").Usage = "Optional remark stamped below the approval"
+	}
+	force := fs.Bool("force", false, "Review an already approved or rejected PDF and overwrite an existing output file")
+	noClobber := fs.Bool("no-clobber", false, "Add a numeric suffix to the output name instead of overwriting an existing file")
+	jsonOut := fs.Bool("json", false, "Print the result as JSON")
+	positional, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: exactly one PDF is required")
+		fs.Usage()
+		return exitUsage
+	}
+	if decision == decisionRejected && strings.TrimSpace(*reason) == "" {
+		fmt.Fprintln(os.Stderr, "Error: -reason is required")
+		return exitUsage
+	}
+
+	input := positional[0]
+	output := *outputFile
+	if output == "" {
+		output = defaultReviewOutputName(input, decision)
+	}
+	if *noClobber {
+		output = nonClobberingPath(output)
+	}
+	opts := ReviewOptions{
+		Decision:    decision,
+		ManagerName: *managerName,
+		Reason:      *reason,
+		Force:       *force,
+		OutputMode:  signOptionsFromConfig(cfg).OutputMode,
+		Layouts:     cfg.Layouts,
+	}
+
+	result, err := reviewPDF(input, output, opts)
+	if err != nil {
+		return fail(*jsonOut, err)
+	}
+	if *jsonOut {
+		writeJSON(struct {
+			OK bool `json:"ok"`
+			*SignResult
+		}{true, result})
+		return exitOK
+	}
+	fmt.Printf("✓ Created %s PDF: %s\n", decision, output)
+	fmt.Printf("  Manager: %s\n", opts.ManagerName)
+	if opts.Reason != "" {
+		fmt.Printf("  Reason: %s\n", opts.Reason)
+	}
+	for _, w := range result.Warnings {
+		fmt.Printf("⚠ %s\n", w)
+	}
+	return exitOK
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// SMTP Delivery
+// ============================================================================
+
+const (
+	defaultSMTPPasswordEnv = "HOURS_SIGNER_SMTP_PASSWORD"
+	smtpTimeout            = 60 * time.Second
+)
+
+// SMTP connection security
+const (
+	smtpStartTLS = "starttls"
+	smtpTLS      = "tls"
+	smtpNone     = "none"
+)
+
+// SMTPConfig is the mail server used for "smtp" delivery. The password is
+// never stored in the config: it is read from an environment variable or
+// printed by a command, e.g. "pass show mail/smtp" or
+// "security find-generic-password -s smtp -w".
+type SMTPConfig struct {
+	Host            string `json:"host,omitempty"`
+	Port            string `json:"port,omitempty"`     // default 587, or 465 for tls
+	Security        string `json:"security,omitempty"` // starttls (default), tls or none
+	Username        string `json:"username,omitempty"`
+	PasswordEnv     string `json:"password_env,omitempty"`
+	PasswordCommand string `json:"password_command,omitempty"`
+}
+
+// smtp returns the SMTP settings of e, creating them if necessary
+func (e *EmailConfig) smtp() *SMTPConfig {
+	if e.SMTP == nil {
+		e.SMTP = &SMTPConfig{}
+	}
+	return e.SMTP
+}
+
+func (s SMTPConfig) security() string {
+	if s.Security == "" {
+		return smtpStartTLS
+	}
+	return s.Security
+}
+
+func (s SMTPConfig) port() string {
+	if s.Port != "" {
+		return s.Port
+	}
+	if s.security() == smtpTLS {
+		return "465"
+	}
+	return "587"
+}
+
+func (s SMTPConfig) passwordEnv() string {
+	if s.PasswordEnv == "" {
+		return defaultSMTPPasswordEnv
+	}
+	return s.PasswordEnv
+}
+
+// password returns the SMTP password from the environment or the password command
+func (s SMTPConfig) password() (string, error) {
+	return readSecret("SMTP password", s.passwordEnv(), s.PasswordCommand, "smtp_password_command")
+}
+
+func validateSMTPSecurity(value string) error {
+	switch value {
+	case smtpStartTLS, smtpTLS, smtpNone:
+		return nil
+	}
+	return fmt.Errorf("must be %q, %q or %q", smtpStartTLS, smtpTLS, smtpNone)
+}
+
+func validateHost(value string) error {
+	if value == "" || strings.ContainsAny(value, " \t\r\n/") {
+		return fmt.Errorf("invalid host %q", value)
+	}
+	return nil
+}
+
+func validatePort(value string) error {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("invalid port %q", value)
+	}
+	return nil
+}
+
+// envelope returns the SMTP sender and recipients of the email in e
+func (e EmailConfig) envelope() (string, []string, error) {
+	if e.From == "" {
+		return "", nil, fmt.Errorf("email_from is required for SMTP delivery")
+	}
+	from, err := mail.ParseAddress(e.From)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid email_from: %w", err)
+	}
+	var rcpts []string
+	for _, list := range []string{e.To, e.Cc} {
+		if list == "" {
+			continue
+		}
+		addrs, err := mail.ParseAddressList(list)
+		if err != nil {
+			return "", nil, err
+		}
+		for _, a := range addrs {
+			rcpts = append(rcpts, a.Address)
+		}
+	}
+	return from.Address, rcpts, nil
+}
+
+// sendSMTP delivers msg from the sender to the recipients through the server in s
+func sendSMTP(s SMTPConfig, from string, rcpts []string, msg []byte) error {
+	if s.Host == "" {
+		return fmt.Errorf("no SMTP server configured (set smtp_host)")
+	}
+	addr := net.JoinHostPort(s.Host, s.port())
+	tlsConfig := &tls.Config{ServerName: s.Host}
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+
+	var conn net.Conn
+	var err error
+	if s.security() == smtpTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	c, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session with %s: %w", addr, err)
+	}
+	defer c.Close()
+
+	if s.security() == smtpStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s does not support STARTTLS (set smtp_security to tls or none)", addr)
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+
+	if s.Username != "" {
+		password, err := s.password()
+		if err != nil {
+			return err
+		}
+		// PlainAuth refuses to send the password over an unencrypted
+		// connection to anything but localhost
+		if err := c.Auth(smtp.PlainAuth("", s.Username, password, s.Host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return fmt.Errorf("server refused sender %s: %w", from, err)
+	}
+	for _, rcpt := range rcpts {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("server refused recipient %s: %w", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("server did not accept the message: %w", err)
+	}
+	return c.Quit()
+}
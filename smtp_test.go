@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/base64"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSMTP is a minimal SMTP server without STARTTLS that accepts one
+// session and records the commands it received
+type fakeSMTP struct {
+	ln         net.Listener
+	rejectRcpt string // recipient refused with 550
+
+	mu       sync.Mutex
+	commands []string
+	data     string
+	done     chan struct{}
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{ln: ln, done: make(chan struct{})}
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+// start serves one session in the background
+func (s *fakeSMTP) start() {
+	go func() {
+		defer close(s.done)
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		s.serve(textproto.NewConn(conn))
+	}()
+}
+
+func (s *fakeSMTP) serve(c *textproto.Conn) {
+	c.PrintfLine("220 fake ESMTP")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, line)
+		s.mu.Unlock()
+
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			c.PrintfLine("250-fake")
+			c.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			c.PrintfLine("235 2.7.0 Authentication successful")
+		case "MAIL":
+			c.PrintfLine("250 2.1.0 Ok")
+		case "RCPT":
+			if s.rejectRcpt != "" && strings.Contains(arg, s.rejectRcpt) {
+				c.PrintfLine("550 5.1.1 No such user")
+			} else {
+				c.PrintfLine("250 2.1.5 Ok")
+			}
+		case "DATA":
+			c.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := c.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.data = string(data)
+			s.mu.Unlock()
+			c.PrintfLine("250 2.0.0 Ok: queued")
+		case "RSET", "NOOP":
+			c.PrintfLine("250 2.0.0 Ok")
+		case "QUIT":
+			c.PrintfLine("221 2.0.0 Bye")
+			return
+		default:
+			c.PrintfLine("502 5.5.2 Command not recognized")
+		}
+	}
+}
+
+// session waits for the session to end and returns the commands received
+// and the message data
+func (s *fakeSMTP) session() ([]string, string) {
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands, s.data
+}
+
+func (s *fakeSMTP) config(security string) SMTPConfig {
+	_, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	return SMTPConfig{Host: "127.0.0.1", Port: port, Security: security, Username: "jan"}
+}
+
+// signedPDFEntry writes a signed PDF and records its signing in the ledger of
+// a temporary home directory
+func signedPDFEntry(t *testing.T) HistoryEntry {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "Urenstaat-2026-09-signed.pdf")
+	data := []byte("%PDF-1.7\n%%EOF\n")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	entry := HistoryEntry{
+		Period:       "2026-09",
+		OutputPath:   path,
+		OutputSHA256: sha256Hex(data),
+		Employee:     "Jan de Vries",
+		Manager:      "Piet",
+	}
+	if err := AppendHistory(entry); err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func TestSendSMTP(t *testing.T) {
+	entry := signedPDFEntry(t)
+	t.Setenv(defaultSMTPPasswordEnv, "geheim")
+
+	server := newFakeSMTP(t)
+	server.start()
+	smtpConfig := server.config(smtpNone)
+	cfg := EmailConfig{
+		From:    "Jan de Vries <jan@example.com>",
+		To:      "Piet <piet@example.com>, hr@example.com",
+		Cc:      "boekhouding@example.com",
+		Deliver: deliverSMTP,
+		SMTP:    &smtpConfig,
+	}
+
+	result, err := emailSignedPDF(cfg, entry, 0)
+	if err != nil {
+		t.Fatalf("emailSignedPDF: %v", err)
+	}
+	if result.Delivered != deliverSMTP || result.Path != "" {
+		t.Errorf("result = %+v, want smtp delivery without a file", result)
+	}
+
+	commands, data := server.session()
+	auth := "AUTH PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00jan\x00geheim"))
+	want := []string{
+		"EHLO localhost",
+		auth,
+		"MAIL FROM:<jan@example.com>",
+		"RCPT TO:<piet@example.com>",
+		"RCPT TO:<hr@example.com>",
+		"RCPT TO:<boekhouding@example.com>",
+		"DATA",
+		"QUIT",
+	}
+	if len(commands) != len(want) {
+		t.Fatalf("commands:\n got %q\nwant %q", commands, want)
+	}
+	for i := range want {
+		// net/smtp may add parameters such as BODY=8BITMIME to MAIL
+		if !strings.HasPrefix(commands[i], want[i]) {
+			t.Errorf("command %d = %q, want %q", i, commands[i], want[i])
+		}
+	}
+
+	for _, part := range []string{
+		"From: \"Jan de Vries\" <jan@example.com>",
+		"Cc: <boekhouding@example.com>",
+		"Subject: Urenstaat 2026-09 - Jan de Vries",
+		"filename=Urenstaat-2026-09-signed.pdf",
+		"JVBERi0xLjcKJSVFT0YK", // the signed PDF
+	} {
+		if !strings.Contains(data, part) {
+			t.Errorf("message does not contain %q:\n%s", part, data)
+		}
+	}
+
+	entries, err := LoadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || len(entries[0].Deliveries) != 1 {
+		t.Fatalf("history = %+v, want one signing with one delivery", entries)
+	}
+	d := entries[0].Deliveries[0]
+	if !d.OK || d.Method != deliverSMTP || d.Target != cfg.To || d.Error != "" {
+		t.Errorf("delivery = %+v, want a successful smtp delivery to %s", d, cfg.To)
+	}
+}
+
+func TestSendSMTPRefusedRecipient(t *testing.T) {
+	entry := signedPDFEntry(t)
+	t.Setenv(defaultSMTPPasswordEnv, "geheim")
+
+	server := newFakeSMTP(t)
+	server.rejectRcpt = "hr@example.com"
+	server.start()
+	smtpConfig := server.config(smtpNone)
+	cfg := EmailConfig{
+		From:    "jan@example.com",
+		To:      "piet@example.com, hr@example.com",
+		Deliver: deliverSMTP,
+		SMTP:    &smtpConfig,
+	}
+
+	_, err := emailSignedPDF(cfg, entry, 0)
+	if err == nil || !strings.Contains(err.Error(), "server refused recipient hr@example.com") {
+		t.Fatalf("err = %v, want the refused recipient", err)
+	}
+	if _, data := server.session(); data != "" {
+		t.Errorf("message sent despite the refused recipient:\n%s", data)
+	}
+
+	entries, err := LoadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || len(entries[0].Deliveries) != 1 {
+		t.Fatalf("history = %+v, want one signing with one delivery", entries)
+	}
+	if d := entries[0].Deliveries[0]; d.OK || !strings.Contains(d.Error, "hr@example.com") {
+		t.Errorf("delivery = %+v, want a failed delivery with the error", d)
+	}
+}
+
+func TestSendSMTPWithoutStartTLS(t *testing.T) {
+	server := newFakeSMTP(t)
+	server.start()
+
+	err := sendSMTP(server.config(""), "jan@example.com", []string{"piet@example.com"}, []byte("Subject: test\r\n\r\ntest\r\n"))
+	if err == nil || !strings.Contains(err.Error(), "does not support STARTTLS") {
+		t.Fatalf("err = %v, want the missing STARTTLS error", err)
+	}
+	if !strings.Contains(err.Error(), "smtp_security") {
+		t.Errorf("err = %v, want a hint about smtp_security", err)
+	}
+
+	// Nothing may be sent, not even the credentials, without encryption
+	commands, _ := server.session()
+	for _, c := range commands {
+		if !strings.HasPrefix(c, "EHLO") {
+			t.Errorf("unexpected command %q after the server lacked STARTTLS", c)
+		}
+	}
+}
+
+func TestSendSMTPWithoutHost(t *testing.T) {
+	err := sendSMTP(SMTPConfig{}, "jan@example.com", []string{"piet@example.com"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "smtp_host") {
+		t.Errorf("err = %v, want a hint about smtp_host", err)
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	from, rcpts, err := EmailConfig{
+		From: "Jan de Vries <jan@example.com>",
+		To:   "Piet <piet@example.com>, hr@example.com",
+		Cc:   "boekhouding@example.com",
+	}.envelope()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != "jan@example.com" {
+		t.Errorf("from = %q, want jan@example.com", from)
+	}
+	if got := strings.Join(rcpts, ","); got != "piet@example.com,hr@example.com,boekhouding@example.com" {
+		t.Errorf("recipients = %s, want To followed by Cc", got)
+	}
+
+	if _, _, err := (EmailConfig{To: "piet@example.com"}).envelope(); err == nil {
+		t.Error("envelope without email_from succeeded")
+	}
+}
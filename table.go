@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// ============================================================================
+// Timesheet Tables
+// ============================================================================
+
+// Column roles recognized by their header
+const (
+	roleDate        = "date"
+	roleProject     = "project"
+	roleDescription = "description"
+	roleHours       = "hours"
+)
+
+// Formats of extracted tables
+const (
+	tableCSV  = "csv"
+	tableJSON = "json"
+)
+
+var rolePatterns = []struct {
+	role    string
+	pattern *regexp.Regexp
+}{
+	{roleDate, regexp.MustCompile(`(?i)^(datum|date)$`)},
+	{roleProject, regexp.MustCompile(`(?i)^(project|projectcode|klant|client|customer|opdracht|opdrachtgever)$`)},
+	{roleDescription, regexp.MustCompile(`(?i)^(omschrijving|beschrijving|description|activiteit|activity|werkzaamheden|taak|task|toelichting)$`)},
+	{roleHours, hoursHeaderPattern},
+}
+
+// A "Dag" column is the date only when there is no "Datum" column
+var dayHeaderPattern = regexp.MustCompile(`(?i)^(dag|day)$`)
+
+// weekdayPattern matches an abbreviated weekday, such as ma or Tue.
+var weekdayPattern = regexp.MustCompile(`^\pL{2,3}\.?$`)
+
+// dateLayouts are the date notations found on timesheets
+var dateLayouts = []string{"2-1-2006", "2/1/2006", "2.1.2006", "2006-01-02", "2-1-06", "2/1/06"}
+
+// tableColumn is a column of a timesheet table, found by its header
+type tableColumn struct {
+	Header string
+	Role   string // empty for columns without a known role
+	Box    rect
+}
+
+// TableRow is a row of a timesheet table. Date is yyyy-mm-dd when the date
+// could be read, as written otherwise; Hours is nil for an empty cell.
+type TableRow struct {
+	Page        int               `json:"page"`
+	Date        string            `json:"date,omitempty"`
+	Project     string            `json:"project,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Hours       *float64          `json:"hours"`
+	Cells       map[string]string `json:"cells"` // all cells by header
+}
+
+// hoursTable is the table of a timesheet, possibly spanning several pages
+type hoursTable struct {
+	Columns []string
+	Rows    []TableRow
+}
+
+// TotalHours returns the sum of the hours of all rows
+func (t *hoursTable) TotalHours() float64 {
+	sum := 0.0
+	for _, r := range t.Rows {
+		if r.Hours != nil {
+			sum += *r.Hours
+		}
+	}
+	return math.Round(sum*100) / 100
+}
+
+// headerColumns returns the columns of line if it is a table header: at
+// least two recognized headers, one of them the date or the hours
+func headerColumns(line textLine) ([]tableColumn, bool) {
+	var columns []tableColumn
+	roles := map[string]bool{}
+	for _, r := range line.Runs {
+		text := strings.TrimSuffix(strings.TrimSpace(r.Text), ":")
+		col := tableColumn{Header: text, Box: r.Box}
+		for _, rp := range rolePatterns {
+			if !roles[rp.role] && rp.pattern.MatchString(text) {
+				col.Role = rp.role
+				roles[rp.role] = true
+				break
+			}
+		}
+		columns = append(columns, col)
+	}
+	if !roles[roleDate] {
+		for i, col := range columns {
+			if col.Role == "" && dayHeaderPattern.MatchString(col.Header) {
+				columns[i].Role = roleDate
+				roles[roleDate] = true
+				break
+			}
+		}
+	}
+	if len(roles) < 2 || !(roles[roleDate] || roles[roleHours]) {
+		return nil, false
+	}
+	return columns, true
+}
+
+// splitCells assigns the runs of line to columns. A run belongs to the
+// column whose span contains its center; spans are split halfway between
+// neighbouring headers.
+func splitCells(line textLine, columns []tableColumn) []string {
+	cells := make([]string, len(columns))
+	for _, r := range line.Runs {
+		center := (r.Box.X0 + r.Box.X1) / 2
+		i := 0
+		for i < len(columns)-1 && center >= (columns[i].Box.X1+columns[i+1].Box.X0)/2 {
+			i++
+		}
+		cells[i] = strings.TrimSpace(cells[i] + " " + strings.TrimSpace(r.Text))
+	}
+	return cells
+}
+
+// parseTableDate returns s as yyyy-mm-dd if it is a date
+func parseTableDate(s string) (string, bool) {
+	// Allow a leading weekday, as in "ma 01-10-2026"
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 || (len(fields) == 2 && !weekdayPattern.MatchString(fields[0])) {
+		return "", false
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, fields[len(fields)-1]); err == nil {
+			return t.Format("2006-01-02"), true
+		}
+	}
+	return "", false
+}
+
+// tableRow makes a row of the cells of a line, or reports false if the line
+// has neither a date nor hours, such as a remark below the table
+func tableRow(page int, columns []tableColumn, cells []string) (TableRow, bool) {
+	row := TableRow{Page: page, Cells: map[string]string{}}
+	hasDate := false
+	for i, col := range columns {
+		value := cells[i]
+		row.Cells[col.Header] = value
+		switch col.Role {
+		case roleDate:
+			row.Date = value
+			if iso, ok := parseTableDate(value); ok {
+				row.Date, hasDate = iso, true
+			}
+		case roleProject:
+			row.Project = value
+		case roleDescription:
+			row.Description = value
+		case roleHours:
+			if h, ok := parseHours(value); ok {
+				row.Hours = &h
+			}
+		}
+	}
+	return row, hasDate || row.Hours != nil
+}
+
+// extractTable finds the table of a timesheet by its header row and reads
+// the rows below it on every page, up to a total row. Weekly subtotals are
+// skipped.
+func extractTable(ctx *pdfmodel.Context) (*hoursTable, error) {
+	table := &hoursTable{}
+	for nr := 1; nr <= ctx.PageCount; nr++ {
+		page, err := analyzePageContext(ctx, nr)
+		if err != nil {
+			return nil, err
+		}
+		var columns []tableColumn
+		for _, line := range page.textLines() {
+			if cols, ok := headerColumns(line); ok {
+				columns = cols
+				if table.Columns == nil {
+					for _, c := range cols {
+						table.Columns = append(table.Columns, c.Header)
+					}
+				}
+				continue
+			}
+			if columns == nil {
+				continue
+			}
+			if subtotalRowPattern.MatchString(line.Text) {
+				continue
+			}
+			if totalRowPattern.MatchString(line.Text) {
+				// What follows, such as the signature block, is not part of the table
+				columns = nil
+				continue
+			}
+			if row, ok := tableRow(nr, columns, splitCells(line, columns)); ok {
+				table.Rows = append(table.Rows, row)
+			}
+		}
+	}
+	if table.Columns == nil {
+		return nil, fmt.Errorf("no table header found (expected columns such as Datum, Project and Uren)")
+	}
+	return table, nil
+}
+
+// encode writes the table as CSV, with the date, project, description and
+// hours of each row, or as JSON
+func (t *hoursTable) encode(format string) ([]byte, error) {
+	var buf bytes.Buffer
+	if format == tableJSON {
+		rows := t.Rows
+		if rows == nil {
+			rows = []TableRow{}
+		}
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		err := enc.Encode(struct {
+			Columns    []string   `json:"columns"`
+			Rows       []TableRow `json:"rows"`
+			TotalHours float64    `json:"total_hours"`
+		}{t.Columns, rows, t.TotalHours()})
+		return buf.Bytes(), err
+	}
+
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"date", "project", "description", "hours"})
+	for _, r := range t.Rows {
+		hours := ""
+		if r.Hours != nil {
+			hours = strconv.FormatFloat(*r.Hours, 'f', -1, 64)
+		}
+		w.Write([]string{r.Date, r.Project, r.Description, hours})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func validateTableFormat(value string) error {
+	if value != tableCSV && value != tableJSON {
+		return fmt.Errorf("must be %q or %q", tableCSV, tableJSON)
+	}
+	return nil
+}
+
+// tableAttachmentName names the attached table after the PDF, e.g.
+// Urenstaat-2025-01-hours.csv
+func tableAttachmentName(pdfPath, format string) string {
+	base := strings.TrimSuffix(filepath.Base(pdfPath), filepath.Ext(pdfPath))
+	base = strings.TrimSuffix(base, "-signed")
+	return base + "-hours." + format
+}
+
+// attachFile embeds data as a file attachment named name, with a
+// description, into the PDF in pdfData
+func attachFile(pdfData []byte, name, description string, data []byte, conf *pdfmodel.Configuration) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "hours-signer-attach-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write attachment: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := api.AddAttachments(bytes.NewReader(pdfData), &buf, []string{path + "," + description}, false, conf); err != nil {
+		return nil, fmt.Errorf("failed to attach %s: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func runExtract(args []string) int {
+	fs := newFlagSet("extract", "[flags] <timesheet.pdf>", "Read the hours table of a timesheet (date, project, description and hours) from\nthe positioned text of its pages and print it as CSV or JSON.")
+	format := fs.String("format", tableCSV, "Format of the table: csv or json")
+	outputFile := fs.String("output", "", "Write the table to this file instead of standard output")
+	attach := fs.Bool("attach", false, "Embed the table as a file attachment in the PDF itself")
+	jsonOut := fs.Bool("json", false, "Print the result as JSON")
+	positional, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: exactly one input PDF is required")
+		fs.Usage()
+		return exitUsage
+	}
+	if err := validateTableFormat(*format); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -format %v\n", err)
+		return exitUsage
+	}
+	input := positional[0]
+
+	ctx, err := api.ReadContextFile(input)
+	if err != nil {
+		return fail(*jsonOut, signError(KindInputPDF, input, fmt.Errorf("failed to read PDF context: %w", err)))
+	}
+	table, err := extractTable(ctx)
+	if err != nil {
+		return fail(*jsonOut, signError(KindInputPDF, input, err))
+	}
+	data, err := table.encode(*format)
+	if err != nil {
+		return fail(*jsonOut, fmt.Errorf("failed to encode table: %w", err))
+	}
+
+	if *outputFile != "" {
+		if err := writeFileAtomic(*outputFile, data, outputFileMode(*outputFile, 0)); err != nil {
+			return fail(*jsonOut, signError(KindOutputWrite, *outputFile, fmt.Errorf("failed to write table: %w", err)))
+		}
+	}
+	attachment := ""
+	if *attach {
+		attachment = tableAttachmentName(input, *format)
+		if err := attachToPDF(input, attachment, data); err != nil {
+			return fail(*jsonOut, err)
+		}
+	}
+
+	if *jsonOut {
+		rows := table.Rows
+		if rows == nil {
+			rows = []TableRow{}
+		}
+		writeJSON(struct {
+			OK         bool       `json:"ok"`
+			Input      string     `json:"input"`
+			Columns    []string   `json:"columns"`
+			Rows       []TableRow `json:"rows"`
+			TotalHours float64    `json:"total_hours"`
+			Output     string     `json:"output,omitempty"`
+			Attachment string     `json:"attachment,omitempty"`
+		}{true, input, table.Columns, rows, table.TotalHours(), *outputFile, attachment})
+		return exitOK
+	}
+	if *outputFile == "" && !*attach {
+		os.Stdout.Write(data)
+		return exitOK
+	}
+	if *outputFile != "" {
+		fmt.Printf("✓ Wrote %d rows (%s hours) to %s\n", len(table.Rows), formatHours(table.TotalHours(), "en"), *outputFile)
+	}
+	if *attach {
+		fmt.Printf("✓ Attached %s to %s\n", attachment, input)
+	}
+	return exitOK
+}
+
+// attachToPDF embeds data into the PDF at path, replacing the file
+func attachToPDF(path, name string, data []byte) error {
+	unlock, err := lockOutput(path)
+	if err != nil {
+		return signError(KindOutputWrite, path, err)
+	}
+	defer unlock()
+
+	pdfData, err := os.ReadFile(path)
+	if err != nil {
+		return signError(KindInputPDF, path, fmt.Errorf("failed to read input file: %w", err))
+	}
+	withAttachment, err := attachFile(pdfData, name, "Hours table", data, pdfmodel.NewDefaultConfiguration())
+	if err != nil {
+		return signError(KindInputPDF, path, err)
+	}
+	if err := writeFileAtomic(path, withAttachment, outputFileMode(path, 0)); err != nil {
+		return signError(KindOutputWrite, path, fmt.Errorf("failed to write PDF: %w", err))
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// ============================================================================
+// Total Hours
+// ============================================================================
+
+// How signing handles the total hours of a timesheet
+const (
+	totalOff    = "off"    // ignore the total
+	totalRecord = "record" // write it into the metadata and the history
+	totalStamp  = "stamp"  // also stamp it into the signature block
+)
+
+// totalFieldKey positions the total in Layout.Fields, like an extra field
+const totalFieldKey = "total"
+
+// Where a total came from
+const (
+	totalSourceRow    = "total row"
+	totalSourceColumn = "hours column"
+	totalSourceManual = "manual"
+	totalSourceGrid   = "hours grid"
+)
+
+// TotalHours is the total number of hours on a timesheet
+type TotalHours struct {
+	Hours     float64 `json:"hours"`
+	Source    string  `json:"source"`
+	Confident bool    `json:"confident"`
+	Reason    string  `json:"reason,omitempty"` // why the total is uncertain
+}
+
+func (t TotalHours) String() string {
+	s := fmt.Sprintf("%s (%s)", formatHours(t.Hours, "en"), t.Source)
+	if !t.Confident {
+		s += ", uncertain: " + t.Reason
+	}
+	return s
+}
+
+func validateTotalMode(value string) error {
+	switch value {
+	case totalOff, totalRecord, totalStamp:
+		return nil
+	}
+	return fmt.Errorf("must be %q, %q or %q", totalOff, totalRecord, totalStamp)
+}
+
+// totalMode returns how signing handles the total hours
+func (cfg Config) totalMode() string {
+	if cfg.TotalHours == "" {
+		return totalRecord
+	}
+	return cfg.TotalHours
+}
+
+var (
+	// hoursPattern matches 8, 7,5, 7.50 and 7:30, optionally followed by a unit
+	hoursPattern = regexp.MustCompile(`^(\d{1,4})(?:([.,])(\d{1,2})|:([0-5]\d))?\s*(?:u|h|uur|hrs?)?$`)
+
+	totalRowPattern    = regexp.MustCompile(`(?i)^(totaal|total)\b`)
+	subtotalRowPattern = regexp.MustCompile(`(?i)^(totaal|total)\s+(week|wk)\b`)
+	hoursHeaderPattern = regexp.MustCompile(`(?i)^(uren|hours|hrs|aantal uren|aantal)$`)
+)
+
+// parseHours parses a number of hours as written on a timesheet
+func parseHours(s string) (float64, bool) {
+	m := hoursPattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if m == nil {
+		return 0, false
+	}
+	hours, _ := strconv.ParseFloat(m[1], 64)
+	switch {
+	case m[3] != "":
+		frac, _ := strconv.ParseFloat("0."+m[3], 64)
+		hours += frac
+	case m[4] != "":
+		minutes, _ := strconv.Atoi(m[4])
+		hours += float64(minutes) / 60
+	}
+	return hours, true
+}
+
+// formatHours formats hours with at most two decimals, with a decimal comma
+// for Dutch
+func formatHours(hours float64, lang string) string {
+	s := strconv.FormatFloat(math.Round(hours*100)/100, 'f', -1, 64)
+	if lang == "nl" {
+		s = strings.Replace(s, ".", ",", 1)
+	}
+	return s
+}
+
+func sameHours(a, b float64) bool {
+	return math.Abs(a-b) < 0.005
+}
+
+// totalRowValue returns the rightmost number of a "Totaal" or "Total" line
+func totalRowValue(line textLine) (float64, bool) {
+	if !totalRowPattern.MatchString(line.Text) {
+		return 0, false
+	}
+	words := strings.Fields(line.Text)
+	for i := len(words) - 1; i > 0; i-- {
+		if v, ok := parseHours(words[i]); ok {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// hoursColumn finds the run holding the header of the hours column
+func hoursColumn(line textLine) (rect, bool) {
+	for _, r := range line.Runs {
+		if hoursHeaderPattern.MatchString(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(r.Text), ":"))) {
+			return r.Box, true
+		}
+	}
+	return rect{}, false
+}
+
+// columnValue returns the number in line that lines up with the column header
+func columnValue(line textLine, header rect) (float64, bool) {
+	const tolerance = 15
+	for _, r := range line.Runs {
+		if r.Box.X0 < header.X1+tolerance && r.Box.X1 > header.X0-tolerance {
+			if v, ok := parseHours(r.Text); ok {
+				return v, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// detectTotalHours reads the total hours from the text of a timesheet. It
+// prefers a "Totaal" or "Total" row and checks it against the sum of the
+// hours column; without a total row the column sum is used. The result is
+// marked uncertain when the two disagree or neither is found.
+func detectTotalHours(ctx *pdfmodel.Context) (TotalHours, error) {
+	var (
+		rows       []float64
+		columnSum  float64
+		columnRows int
+	)
+	for nr := 1; nr <= ctx.PageCount; nr++ {
+		page, err := analyzePageContext(ctx, nr)
+		if err != nil {
+			return TotalHours{}, err
+		}
+		// The header is looked for again on every page
+		var header rect
+		inColumn := false
+		for _, line := range page.textLines() {
+			if v, ok := totalRowValue(line); ok {
+				rows = append(rows, v)
+				continue
+			}
+			if box, ok := hoursColumn(line); ok {
+				header, inColumn = box, true
+				continue
+			}
+			if inColumn {
+				if v, ok := columnValue(line, header); ok {
+					columnSum += v
+					columnRows++
+				}
+			}
+		}
+	}
+	return judgeTotal(rows, columnSum, columnRows), nil
+}
+
+// judgeTotal picks the total from the values of the total rows and the sum
+// of columnRows values in the hours column, and decides whether it can be
+// trusted
+func judgeTotal(rows []float64, columnSum float64, columnRows int) TotalHours {
+	haveColumn := columnRows > 0
+	if len(rows) == 0 {
+		if !haveColumn {
+			return TotalHours{Reason: "no total row or hours column found"}
+		}
+		return TotalHours{Hours: columnSum, Source: totalSourceColumn, Confident: true}
+	}
+
+	// Weekly subtotals are smaller than the total of the month
+	total, sum := rows[0], 0.0
+	for _, v := range rows {
+		total = math.Max(total, v)
+		sum += v
+	}
+	t := TotalHours{Hours: total, Source: totalSourceRow, Confident: true}
+	// Each value in the column may be rounded to two decimals, as 2:40 is
+	// written as 2,67
+	if haveColumn && math.Abs(columnSum-total) >= 0.005*float64(columnRows+1) {
+		t.Confident = false
+		t.Reason = fmt.Sprintf("the total row says %s but the hours column adds up to %s", formatHours(total, "en"), formatHours(columnSum, "en"))
+		return t
+	}
+	allSame := true
+	for _, v := range rows {
+		allSame = allSame && sameHours(v, total)
+	}
+	if !haveColumn && !allSame && !sameHours(sum-total, total) {
+		values := make([]string, len(rows))
+		for i, v := range rows {
+			values[i] = formatHours(v, "en")
+		}
+		t.Confident = false
+		t.Reason = "found several different totals: " + strings.Join(values, ", ")
+	}
+	return t
+}
+
+// signingTotal returns the total hours signPDF records: the one given in
+// opts, or the one detected in the PDF. It returns nil when the total is off.
+func signingTotal(ctx *pdfmodel.Context, opts SignOptions) (*TotalHours, error) {
+	if opts.Total == "" || opts.Total == totalOff {
+		return nil, nil
+	}
+	if opts.TotalHours != nil {
+		return opts.TotalHours, nil
+	}
+	total, err := detectTotalHours(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &total, nil
+}
+
+// manualTotal returns a total entered by hand
+func manualTotal(s string) (*TotalHours, error) {
+	hours, ok := parseHours(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid number of hours %q (e.g. 160, 152,5 or 152:30)", s)
+	}
+	return &TotalHours{Hours: hours, Source: totalSourceManual, Confident: true}, nil
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// E-Invoices (UBL)
+// ============================================================================
+
+// UBL 2.1 namespaces and the identifiers of the NLCIUS (SI-UBL 2.0) profile
+const (
+	ublInvoiceNS      = "urn:oasis:names:specification:ubl:schema:xsd:Invoice-2"
+	ublAggregateNS    = "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2"
+	ublBasicNS        = "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2"
+	ublCustomization  = "urn:cen.eu:en16931:2017#compliant#urn:fdc:nen.nl:nlcius:v1.0"
+	ublProfile        = "urn:fdc:peppol.eu:2017:poacc:billing:01:1.0"
+	ublCommercialInv  = "380"
+	ublCreditTransfer = "30"
+	ublHour           = "HUR"  // UN/ECE rec 20 unit of an hour
+	ublKvKScheme      = "0106" // ISO 6523 code of KvK numbers
+	ublCurrency       = "EUR"
+)
+
+// validateUBL checks the value of the invoice_ubl config key
+func validateUBL(value string) error {
+	if value != checkOn && value != checkOff {
+		return fmt.Errorf("must be %q or %q", checkOn, checkOff)
+	}
+	return nil
+}
+
+// ublOutputName returns the name of the UBL invoice next to the invoice PDF
+func ublOutputName(pdfPath string) string {
+	return strings.TrimSuffix(pdfPath, filepath.Ext(pdfPath)) + ".xml"
+}
+
+type ublInvoice struct {
+	XMLName              xml.Name         `xml:"Invoice"`
+	NS                   string           `xml:"xmlns,attr"`
+	CAC                  string           `xml:"xmlns:cac,attr"`
+	CBC                  string           `xml:"xmlns:cbc,attr"`
+	CustomizationID      string           `xml:"cbc:CustomizationID"`
+	ProfileID            string           `xml:"cbc:ProfileID"`
+	ID                   string           `xml:"cbc:ID"`
+	IssueDate            string           `xml:"cbc:IssueDate"`
+	DueDate              string           `xml:"cbc:DueDate"`
+	InvoiceTypeCode      string           `xml:"cbc:InvoiceTypeCode"`
+	DocumentCurrencyCode string           `xml:"cbc:DocumentCurrencyCode"`
+	BuyerReference       string           `xml:"cbc:BuyerReference"`
+	InvoicePeriod        ublPeriod        `xml:"cac:InvoicePeriod"`
+	AdditionalDocument   ublDocument      `xml:"cac:AdditionalDocumentReference"`
+	Supplier             ublPartyRole     `xml:"cac:AccountingSupplierParty"`
+	Customer             ublPartyRole     `xml:"cac:AccountingCustomerParty"`
+	PaymentMeans         ublPaymentMeans  `xml:"cac:PaymentMeans"`
+	PaymentTerms         *ublNote         `xml:"cac:PaymentTerms,omitempty"`
+	TaxTotal             ublTaxTotal      `xml:"cac:TaxTotal"`
+	LegalMonetaryTotal   ublMonetaryTotal `xml:"cac:LegalMonetaryTotal"`
+	InvoiceLines         []ublInvoiceLine `xml:"cac:InvoiceLine"`
+}
+
+type ublPeriod struct {
+	StartDate string `xml:"cbc:StartDate"`
+	EndDate   string `xml:"cbc:EndDate"`
+}
+
+type ublDocument struct {
+	ID          string `xml:"cbc:ID"`
+	Description string `xml:"cbc:DocumentDescription"`
+	Attachment  struct {
+		Object ublBinaryObject `xml:"cbc:EmbeddedDocumentBinaryObject"`
+	} `xml:"cac:Attachment"`
+}
+
+type ublBinaryObject struct {
+	MimeCode string `xml:"mimeCode,attr"`
+	Filename string `xml:"filename,attr"`
+	Data     string `xml:",chardata"`
+}
+
+type ublPartyRole struct {
+	Party ublParty `xml:"cac:Party"`
+}
+
+type ublParty struct {
+	EndpointID  *ublID         `xml:"cbc:EndpointID,omitempty"`
+	Name        ublName        `xml:"cac:PartyName"`
+	Address     ublAddress     `xml:"cac:PostalAddress"`
+	TaxScheme   *ublPartyTax   `xml:"cac:PartyTaxScheme,omitempty"`
+	LegalEntity ublLegalEntity `xml:"cac:PartyLegalEntity"`
+}
+
+type ublID struct {
+	SchemeID string `xml:"schemeID,attr,omitempty"`
+	Value    string `xml:",chardata"`
+}
+
+type ublName struct {
+	Name string `xml:"cbc:Name"`
+}
+
+type ublAddress struct {
+	StreetName string `xml:"cbc:StreetName,omitempty"`
+	CityName   string `xml:"cbc:CityName,omitempty"`
+	PostalZone string `xml:"cbc:PostalZone,omitempty"`
+	Country    struct {
+		Code string `xml:"cbc:IdentificationCode"`
+	} `xml:"cac:Country"`
+}
+
+type ublTaxScheme struct {
+	ID string `xml:"cbc:ID"`
+}
+
+type ublPartyTax struct {
+	CompanyID string       `xml:"cbc:CompanyID"`
+	TaxScheme ublTaxScheme `xml:"cac:TaxScheme"`
+}
+
+type ublLegalEntity struct {
+	RegistrationName string `xml:"cbc:RegistrationName"`
+	CompanyID        *ublID `xml:"cbc:CompanyID,omitempty"`
+}
+
+type ublPaymentMeans struct {
+	Code      string `xml:"cbc:PaymentMeansCode"`
+	PaymentID string `xml:"cbc:PaymentID"`
+	Account   struct {
+		ID   string `xml:"cbc:ID"`
+		Name string `xml:"cbc:Name"`
+	} `xml:"cac:PayeeFinancialAccount"`
+}
+
+type ublNote struct {
+	Note string `xml:"cbc:Note"`
+}
+
+type ublAmount struct {
+	Currency string `xml:"currencyID,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// ublMoney is an amount in euros
+func ublMoney(m money) ublAmount {
+	return ublAmount{ublCurrency, m.decimal()}
+}
+
+type ublTaxTotal struct {
+	TaxAmount ublAmount `xml:"cbc:TaxAmount"`
+	Subtotal  struct {
+		TaxableAmount ublAmount      `xml:"cbc:TaxableAmount"`
+		TaxAmount     ublAmount      `xml:"cbc:TaxAmount"`
+		Category      ublTaxCategory `xml:"cac:TaxCategory"`
+	} `xml:"cac:TaxSubtotal"`
+}
+
+type ublTaxCategory struct {
+	ID              string       `xml:"cbc:ID"`
+	Percent         string       `xml:"cbc:Percent"`
+	ExemptionReason string       `xml:"cbc:TaxExemptionReason,omitempty"`
+	TaxScheme       ublTaxScheme `xml:"cac:TaxScheme"`
+}
+
+type ublMonetaryTotal struct {
+	LineExtension ublAmount `xml:"cbc:LineExtensionAmount"`
+	TaxExclusive  ublAmount `xml:"cbc:TaxExclusiveAmount"`
+	TaxInclusive  ublAmount `xml:"cbc:TaxInclusiveAmount"`
+	Payable       ublAmount `xml:"cbc:PayableAmount"`
+}
+
+type ublInvoiceLine struct {
+	ID       string `xml:"cbc:ID"`
+	Quantity struct {
+		UnitCode string `xml:"unitCode,attr"`
+		Value    string `xml:",chardata"`
+	} `xml:"cbc:InvoicedQuantity"`
+	LineExtension ublAmount `xml:"cbc:LineExtensionAmount"`
+	Item          struct {
+		Name        string         `xml:"cbc:Name"`
+		TaxCategory ublTaxCategory `xml:"cac:ClassifiedTaxCategory"`
+	} `xml:"cac:Item"`
+	Price struct {
+		Amount ublAmount `xml:"cbc:PriceAmount"`
+	} `xml:"cac:Price"`
+}
+
+// postalLinePattern matches a line with a postal code and city, as in
+// "1234 AB Utrecht" or "10115 Berlin"
+var postalLinePattern = regexp.MustCompile(`^(\d{4}\s?[A-Za-z]{2}|[A-Z]{1,2}-?\d{4,5}|\d{4,5})\s+(.+)$`)
+
+// ublPostalAddress splits address lines into the street, postal code and
+// city; lines after the city, such as the country, are left out
+func ublPostalAddress(lines []string, country string) ublAddress {
+	var a ublAddress
+	for _, line := range lines {
+		if m := postalLinePattern.FindStringSubmatch(line); m != nil && a.PostalZone == "" {
+			a.PostalZone, a.CityName = strings.ToUpper(m[1]), m[2]
+		} else if a.StreetName == "" && a.PostalZone == "" {
+			a.StreetName = line
+		}
+	}
+	a.Country.Code = country
+	return a
+}
+
+// partyCountry returns the country of a party: the country code of its VAT
+// number, or NL
+func partyCountry(p invoiceParty) string {
+	if len(p.BTW) >= 2 {
+		return p.BTW[:2]
+	}
+	return "NL"
+}
+
+// ubl converts a party of an invoice, identified by its KvK number
+func (p invoiceParty) ubl() ublParty {
+	country := partyCountry(p)
+	party := ublParty{
+		Name:        ublName{p.Name},
+		Address:     ublPostalAddress(p.Address, country),
+		LegalEntity: ublLegalEntity{RegistrationName: p.Name},
+	}
+	if p.KvK != "" {
+		party.EndpointID = &ublID{ublKvKScheme, p.KvK}
+		party.LegalEntity.CompanyID = &ublID{ublKvKScheme, p.KvK}
+	}
+	if p.BTW != "" {
+		party.TaxScheme = &ublPartyTax{p.BTW, ublTaxScheme{"VAT"}}
+	}
+	return party
+}
+
+// missingUBLData returns the config keys an NLCIUS invoice needs that are
+// not set: the KvK numbers of both parties, the seller's VAT number and
+// IBAN, the client's reference, and the street, postal code and city of
+// Dutch parties
+func missingUBLData(inv *Invoice) []string {
+	var missing []string
+	if inv.Seller.KvK == "" {
+		missing = append(missing, "invoice_kvk")
+	}
+	// The electronic address and legal registration of the buyer
+	// (PEPPOL-EN16931-R010, BR-NL-10)
+	if inv.Buyer.KvK == "" {
+		missing = append(missing, "invoice_client_kvk")
+	}
+	// Portals route the invoice on the buyer reference (BT-10), so it is
+	// not made up
+	if inv.Reference == "" {
+		missing = append(missing, "invoice_reference")
+	}
+	if inv.Seller.BTW == "" && inv.VATRate > 0 {
+		missing = append(missing, "invoice_btw")
+	}
+	if inv.Seller.IBAN == "" {
+		missing = append(missing, "invoice_iban")
+	}
+	for key, p := range map[string]invoiceParty{"invoice_address": inv.Seller, "invoice_client_address": inv.Buyer} {
+		a := ublPostalAddress(p.Address, partyCountry(p))
+		if a.Country.Code == "NL" && (a.StreetName == "" || a.PostalZone == "" || a.CityName == "") {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// taxCategory returns the VAT category of the invoice: standard rated, or
+// exempt at 0%
+func (inv *Invoice) taxCategory() ublTaxCategory {
+	c := ublTaxCategory{ID: "S", Percent: strconv.FormatFloat(inv.VATRate, 'f', -1, 64), TaxScheme: ublTaxScheme{"VAT"}}
+	if inv.VATRate == 0 {
+		c.ID, c.ExemptionReason = "E", invoiceLabels[inv.Lang]["exempt"]
+	}
+	return c
+}
+
+// ublXML builds the UBL 2.1 invoice following NLCIUS (SI-UBL 2.0), with the
+// signed timesheet embedded. The data checked by missingUBLData must be set.
+func ublXML(inv *Invoice, timesheet []byte, timesheetName string) ([]byte, error) {
+	if missing := missingUBLData(inv); len(missing) > 0 {
+		return nil, fmt.Errorf("a UBL invoice needs complete values for %s", strings.Join(missing, ", "))
+	}
+	first, last, err := periodDays(inv.Period)
+	if err != nil {
+		return nil, err
+	}
+	doc := ublInvoice{
+		NS:                   ublInvoiceNS,
+		CAC:                  ublAggregateNS,
+		CBC:                  ublBasicNS,
+		CustomizationID:      ublCustomization,
+		ProfileID:            ublProfile,
+		ID:                   inv.Number,
+		IssueDate:            inv.Date.Format("2006-01-02"),
+		DueDate:              inv.DueDate.Format("2006-01-02"),
+		InvoiceTypeCode:      ublCommercialInv,
+		DocumentCurrencyCode: ublCurrency,
+		BuyerReference:       inv.Reference,
+		InvoicePeriod:        ublPeriod{first.Format("2006-01-02"), last.Format("2006-01-02")},
+		Supplier:             ublPartyRole{inv.Seller.ubl()},
+		Customer:             ublPartyRole{inv.Buyer.ubl()},
+	}
+
+	doc.AdditionalDocument.ID = timesheetName
+	doc.AdditionalDocument.Description = fmt.Sprintf(invoiceLabels[inv.Lang]["timesheet"], inv.Period)
+	doc.AdditionalDocument.Attachment.Object = ublBinaryObject{"application/pdf", timesheetName, base64.StdEncoding.EncodeToString(timesheet)}
+
+	doc.PaymentMeans.Code = ublCreditTransfer
+	doc.PaymentMeans.PaymentID = inv.Number
+	doc.PaymentMeans.Account.ID = inv.Seller.IBAN
+	doc.PaymentMeans.Account.Name = inv.Seller.Name
+	if inv.Terms > 0 {
+		doc.PaymentTerms = &ublNote{fmt.Sprintf(invoiceLabels[inv.Lang]["terms"], inv.Terms)}
+	}
+
+	category := inv.taxCategory()
+	doc.TaxTotal.TaxAmount = ublMoney(inv.VAT)
+	doc.TaxTotal.Subtotal.TaxableAmount = ublMoney(inv.Subtotal)
+	doc.TaxTotal.Subtotal.TaxAmount = ublMoney(inv.VAT)
+	doc.TaxTotal.Subtotal.Category = category
+	doc.LegalMonetaryTotal = ublMonetaryTotal{ublMoney(inv.Subtotal), ublMoney(inv.Subtotal), ublMoney(inv.Total), ublMoney(inv.Total)}
+
+	lineCategory := category
+	lineCategory.ExemptionReason = ""
+	for i, l := range inv.Lines {
+		var line ublInvoiceLine
+		line.ID = strconv.Itoa(i + 1)
+		line.Quantity.UnitCode = ublHour
+		line.Quantity.Value = strconv.FormatFloat(l.Hours, 'f', -1, 64)
+		line.LineExtension = ublMoney(l.Amount)
+		line.Item.Name = l.Description
+		line.Item.TaxCategory = lineCategory
+		line.Price.Amount = ublMoney(l.Rate)
+		doc.InvoiceLines = append(doc.InvoiceLines, line)
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode UBL invoice: %w", err)
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
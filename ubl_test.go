@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ublSubset is a hand-written subset of the UBL 2.1 invoice schema with the
+// elements hours-signer writes
+const ublSubset = "testdata/ubl/maindoc/UBL-Invoice-2.1.xsd"
+
+// ublSchema returns the invoice schema to validate against: the official
+// UBL-Invoice-2.1.xsd named by UBL_SCHEMA, or else the subset
+func ublSchema() string {
+	if path := os.Getenv("UBL_SCHEMA"); path != "" {
+		return path
+	}
+	return ublSubset
+}
+
+// testTimesheet stands in for the signed timesheet embedded in the invoice
+var testTimesheet = []byte("%PDF-1.7\n1 0 obj\n<< /Type /Catalog >>\nendobj\n%%EOF\n")
+
+// testInvoice returns an invoice of two projects at the given VAT rate
+func testInvoice(vatRate float64) *Invoice {
+	rate := money(9250)
+	inv := &Invoice{
+		Number:    "2026-0007",
+		Date:      time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		DueDate:   time.Date(2026, 10, 31, 0, 0, 0, 0, time.UTC),
+		Period:    "2026-09",
+		Reference: "PO-4411",
+		VATRate:   vatRate,
+		Seller: invoiceParty{
+			Name:    "Jan de Vries",
+			Address: []string{"Hoofdstraat 1", "1234 ab Utrecht"},
+			KvK:     "12345678",
+			BTW:     "NL123456789B01",
+			IBAN:    "NL91ABNA0417164300",
+		},
+		Buyer: invoiceParty{
+			Name:    "Acme B.V.",
+			Address: []string{"Kade 10", "5678 CD Rotterdam", "Nederland"},
+			KvK:     "87654321",
+		},
+		Terms: 30,
+		Lang:  "nl",
+	}
+	for _, p := range []struct {
+		project string
+		hours   float64
+	}{{"Backend", 120.5}, {"Support", 16}} {
+		amount := rate.times(p.hours)
+		inv.Lines = append(inv.Lines, invoiceLine{p.project, p.hours, rate, amount})
+		inv.Subtotal += amount
+	}
+	inv.VAT = inv.Subtotal.percent(vatRate)
+	inv.Total = inv.Subtotal + inv.VAT
+	return inv
+}
+
+// xmlNode is a generic element of a decoded XML document
+type xmlNode struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Content  string     `xml:",chardata"`
+	Children []xmlNode  `xml:",any"`
+}
+
+func (n *xmlNode) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// find returns the first element at the path of local names below n
+func (n *xmlNode) find(path ...string) *xmlNode {
+	if len(path) == 0 {
+		return n
+	}
+	for i := range n.Children {
+		if n.Children[i].XMLName.Local == path[0] {
+			if found := n.Children[i].find(path[1:]...); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+// walk calls fn for n and every element below it
+func (n *xmlNode) walk(fn func(*xmlNode)) {
+	fn(n)
+	for i := range n.Children {
+		n.Children[i].walk(fn)
+	}
+}
+
+func decodeUBL(t *testing.T, data []byte) *xmlNode {
+	t.Helper()
+	var root xmlNode
+	if err := xml.Unmarshal(data, &root); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, data)
+	}
+	return &root
+}
+
+func TestUBLSchema(t *testing.T) {
+	xmllint, err := exec.LookPath("xmllint")
+	if err != nil {
+		t.Skip("xmllint not found; install libxml2 to validate against the UBL schema")
+	}
+
+	for _, vat := range []float64{21, 0} {
+		data, err := ublXML(testInvoice(vat), testTimesheet, "Urenstaat-2026-09-signed.pdf")
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(t.TempDir(), "invoice.xml")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		out, err := exec.Command(xmllint, "--noout", "--schema", ublSchema(), path).CombinedOutput()
+		if err != nil {
+			t.Errorf("VAT %v%%: invoice does not validate against the UBL schema: %v\n%s", vat, err, out)
+		}
+	}
+}
+
+func TestUBLSchemaRejectsOutOfOrder(t *testing.T) {
+	xmllint, err := exec.LookPath("xmllint")
+	if err != nil {
+		t.Skip("xmllint not found; install libxml2 to validate against the UBL schema")
+	}
+
+	// Guards the schema itself: moving the buyer reference after the
+	// invoice period must not validate
+	data, err := ublXML(testInvoice(21), testTimesheet, "timesheet.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := mustContain(t, data, "  <cbc:BuyerReference>PO-4411</cbc:BuyerReference>\n")
+	data = bytes.Replace(data, ref, nil, 1)
+	data = bytes.Replace(data, []byte("  </cac:InvoicePeriod>\n"), append([]byte("  </cac:InvoicePeriod>\n"), ref...), 1)
+
+	path := filepath.Join(t.TempDir(), "invoice.xml")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := exec.Command(xmllint, "--noout", "--schema", ublSchema(), path).Run(); err == nil {
+		t.Error("invoice with elements out of order validates against the UBL schema")
+	}
+}
+
+func mustContain(t *testing.T, data []byte, s string) []byte {
+	t.Helper()
+	if !bytes.Contains(data, []byte(s)) {
+		t.Fatalf("%q not found in\n%s", s, data)
+	}
+	return []byte(s)
+}
+
+func TestUBLElementOrder(t *testing.T) {
+	data, err := ublXML(testInvoice(21), testTimesheet, "timesheet.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := decodeUBL(t, data)
+	if root.XMLName.Space != ublInvoiceNS || root.XMLName.Local != "Invoice" {
+		t.Fatalf("root element = %v, want Invoice in %s", root.XMLName, ublInvoiceNS)
+	}
+
+	var got []string
+	for _, c := range root.Children {
+		got = append(got, c.XMLName.Local)
+	}
+	want := []string{
+		"CustomizationID", "ProfileID", "ID", "IssueDate", "DueDate", "InvoiceTypeCode",
+		"DocumentCurrencyCode", "BuyerReference", "InvoicePeriod", "AdditionalDocumentReference",
+		"AccountingSupplierParty", "AccountingCustomerParty", "PaymentMeans", "PaymentTerms",
+		"TaxTotal", "LegalMonetaryTotal", "InvoiceLine", "InvoiceLine",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("elements:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestUBLAmounts(t *testing.T) {
+	data, err := ublXML(testInvoice(21), testTimesheet, "timesheet.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := decodeUBL(t, data)
+
+	amounts := 0
+	root.walk(func(n *xmlNode) {
+		if strings.HasSuffix(n.XMLName.Local, "Amount") {
+			amounts++
+			if c := n.attr("currencyID"); c != "EUR" {
+				t.Errorf("%s %s: currencyID = %q, want EUR", n.XMLName.Local, n.Content, c)
+			}
+		}
+	})
+	// TaxTotal 3, LegalMonetaryTotal 4 and two per invoice line
+	if amounts != 11 {
+		t.Errorf("found %d amounts, want 11", amounts)
+	}
+
+	// 120.5 × 92.50 + 16 × 92.50 = 12626.25, plus 21% VAT
+	for path, want := range map[string]string{
+		"LegalMonetaryTotal/LineExtensionAmount": "12626.25",
+		"LegalMonetaryTotal/TaxInclusiveAmount":  "15277.76",
+		"LegalMonetaryTotal/PayableAmount":       "15277.76",
+		"TaxTotal/TaxAmount":                     "2651.51",
+		"TaxTotal/TaxSubtotal/TaxCategory/ID":    "S",
+		"InvoiceLine/InvoicedQuantity":           "120.5",
+		"InvoiceLine/LineExtensionAmount":        "11146.25",
+		"InvoiceLine/Price/PriceAmount":          "92.50",
+	} {
+		n := root.find(strings.Split(path, "/")...)
+		if n == nil {
+			t.Errorf("%s missing", path)
+		} else if n.Content != want {
+			t.Errorf("%s = %q, want %q", path, n.Content, want)
+		}
+	}
+}
+
+func TestUBLAttachment(t *testing.T) {
+	data, err := ublXML(testInvoice(21), testTimesheet, "Urenstaat-2026-09-signed.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := decodeUBL(t, data)
+
+	ref := root.find("AdditionalDocumentReference")
+	if ref == nil {
+		t.Fatal("AdditionalDocumentReference missing")
+	}
+	if id := ref.find("ID"); id == nil || id.Content != "Urenstaat-2026-09-signed.pdf" {
+		t.Errorf("document ID = %v, want the timesheet name", id)
+	}
+	obj := ref.find("Attachment", "EmbeddedDocumentBinaryObject")
+	if obj == nil {
+		t.Fatal("EmbeddedDocumentBinaryObject missing")
+	}
+	if got := obj.attr("mimeCode"); got != "application/pdf" {
+		t.Errorf("mimeCode = %q, want application/pdf", got)
+	}
+	if got := obj.attr("filename"); got != "Urenstaat-2026-09-signed.pdf" {
+		t.Errorf("filename = %q, want the timesheet name", got)
+	}
+	pdf, err := base64.StdEncoding.DecodeString(obj.Content)
+	if err != nil {
+		t.Fatalf("attachment is not base64: %v", err)
+	}
+	if !bytes.Equal(pdf, testTimesheet) {
+		t.Errorf("attachment decodes to %q, want the timesheet", pdf)
+	}
+}
+
+func TestUBLNLCIUS(t *testing.T) {
+	inv := testInvoice(21)
+	data, err := ublXML(inv, testTimesheet, "timesheet.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := decodeUBL(t, data)
+
+	if n := root.find("CustomizationID"); n == nil || n.Content != ublCustomization {
+		t.Errorf("CustomizationID = %v, want %s", n, ublCustomization)
+	}
+
+	// PEPPOL-EN16931-R003: a buyer reference is required, and only the
+	// client's own reference will do
+	if n := root.find("BuyerReference"); n == nil || n.Content != "PO-4411" {
+		t.Errorf("BuyerReference = %v, want the client's reference", n)
+	}
+	inv.Reference = ""
+	if _, err := ublXML(inv, testTimesheet, "timesheet.pdf"); err == nil || !strings.Contains(err.Error(), "invoice_reference") {
+		t.Errorf("err = %v, want the missing reference", err)
+	}
+
+	// BR-NL-1: a Dutch supplier is identified by its KvK number, scheme 0106
+	for _, role := range []string{"AccountingSupplierParty", "AccountingCustomerParty"} {
+		party := root.find(role, "Party")
+		if party == nil {
+			t.Fatalf("%s missing", role)
+		}
+		for _, path := range [][]string{{"PartyLegalEntity", "CompanyID"}, {"EndpointID"}} {
+			n := party.find(path...)
+			if n == nil {
+				t.Errorf("%s %s missing", role, strings.Join(path, "/"))
+				continue
+			}
+			if got := n.attr("schemeID"); got != ublKvKScheme {
+				t.Errorf("%s %s: schemeID = %q, want %s", role, strings.Join(path, "/"), got, ublKvKScheme)
+			}
+		}
+	}
+	if n := root.find("AccountingSupplierParty", "Party", "PartyLegalEntity", "CompanyID"); n != nil && n.Content != "12345678" {
+		t.Errorf("supplier CompanyID = %q, want the KvK number", n.Content)
+	}
+
+	// BR-NL-3/4: street, postal code and city of Dutch parties
+	addr := root.find("AccountingSupplierParty", "Party", "PostalAddress")
+	for name, want := range map[string]string{"StreetName": "Hoofdstraat 1", "PostalZone": "1234 AB", "CityName": "Utrecht", "Country/IdentificationCode": "NL"} {
+		if n := addr.find(strings.Split(name, "/")...); n == nil || n.Content != want {
+			t.Errorf("supplier %s = %v, want %q", name, n, want)
+		}
+	}
+}
+
+func TestUBLExemptFromVAT(t *testing.T) {
+	data, err := ublXML(testInvoice(0), testTimesheet, "timesheet.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := decodeUBL(t, data)
+
+	category := root.find("TaxTotal", "TaxSubtotal", "TaxCategory")
+	if category == nil {
+		t.Fatal("TaxCategory missing")
+	}
+	if n := category.find("ID"); n == nil || n.Content != "E" {
+		t.Errorf("tax category = %v, want E", n)
+	}
+	if n := category.find("TaxExemptionReason"); n == nil || n.Content == "" {
+		t.Error("exempt invoice without TaxExemptionReason")
+	}
+	if n := root.find("LegalMonetaryTotal", "PayableAmount"); n == nil || n.Content != "12626.25" {
+		t.Errorf("PayableAmount = %v, want 12626.25", n)
+	}
+}
+
+func TestMissingUBLData(t *testing.T) {
+	inv := testInvoice(21)
+	if missing := missingUBLData(inv); len(missing) != 0 {
+		t.Errorf("missingUBLData = %v, want none", missing)
+	}
+
+	inv.Seller.KvK = ""
+	inv.Seller.IBAN = ""
+	inv.Buyer.Address = []string{"Kade 10"}
+	want := "invoice_client_address invoice_iban invoice_kvk"
+	if got := strings.Join(missingUBLData(inv), " "); got != want {
+		t.Errorf("missingUBLData = %s, want %s", got, want)
+	}
+
+	// The buyer's KvK number and reference are required as well
+	inv = testInvoice(21)
+	inv.Buyer.KvK = ""
+	inv.Reference = ""
+	want = "invoice_client_kvk invoice_reference"
+	if got := strings.Join(missingUBLData(inv), " "); got != want {
+		t.Errorf("missingUBLData = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// Upload
+// ============================================================================
+
+const (
+	defaultUploadMethod   = http.MethodPost
+	defaultUploadField    = "file"
+	defaultUploadTokenEnv = "HOURS_SIGNER_UPLOAD_TOKEN"
+	defaultUploadRetries  = 2
+	maxUploadRetries      = 10
+	uploadTimeout         = 60 * time.Second
+)
+
+// Waiting between upload attempts; variables so tests need not wait
+var (
+	uploadBackoff = time.Second // wait before the first retry, doubled for every next one
+	uploadSleep   = time.Sleep
+)
+
+// Upload authentication
+const (
+	uploadAuthNone   = "none"
+	uploadAuthBearer = "bearer"
+	uploadAuthBasic  = "basic"
+)
+
+// UploadConfig describes the HTTP endpoint signed PDFs are uploaded to. The
+// PDF is sent as a multipart form. URL, header values and form fields may
+// contain {period}, {employee}, {manager} and {file}. Like the SMTP password,
+// the token is read from an environment variable or printed by a command.
+type UploadConfig struct {
+	URL          string            `json:"url,omitempty"`
+	Method       string            `json:"method,omitempty"`     // POST (default), PUT or PATCH
+	FileField    string            `json:"file_field,omitempty"` // form field of the PDF, default "file"
+	Headers      map[string]string `json:"headers,omitempty"`
+	Fields       map[string]string `json:"fields,omitempty"` // extra form fields
+	Auth         string            `json:"auth,omitempty"`   // none (default), bearer or basic
+	Username     string            `json:"username,omitempty"`
+	TokenEnv     string            `json:"token_env,omitempty"`
+	TokenCommand string            `json:"token_command,omitempty"`
+	Retries      string            `json:"retries,omitempty"` // default 2
+}
+
+// upload returns the upload settings of cfg, creating them if necessary
+func (cfg *Config) upload() *UploadConfig {
+	if cfg.Upload == nil {
+		cfg.Upload = &UploadConfig{}
+	}
+	return cfg.Upload
+}
+
+func (u UploadConfig) method() string {
+	if u.Method == "" {
+		return defaultUploadMethod
+	}
+	return strings.ToUpper(u.Method)
+}
+
+func (u UploadConfig) fileField() string {
+	if u.FileField == "" {
+		return defaultUploadField
+	}
+	return u.FileField
+}
+
+func (u UploadConfig) auth() string {
+	if u.Auth == "" {
+		return uploadAuthNone
+	}
+	return u.Auth
+}
+
+func (u UploadConfig) tokenEnv() string {
+	if u.TokenEnv == "" {
+		return defaultUploadTokenEnv
+	}
+	return u.TokenEnv
+}
+
+func (u UploadConfig) retries() int {
+	if n, err := strconv.Atoi(u.Retries); err == nil {
+		return n
+	}
+	return defaultUploadRetries
+}
+
+func validateUploadURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("URL must start with http:// or https:// and include a host")
+	}
+	return nil
+}
+
+func validateUploadMethod(value string) error {
+	switch strings.ToUpper(value) {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return nil
+	}
+	return fmt.Errorf("must be POST, PUT or PATCH")
+}
+
+func validateUploadAuth(value string) error {
+	switch value {
+	case uploadAuthNone, uploadAuthBearer, uploadAuthBasic:
+		return nil
+	}
+	return fmt.Errorf("must be %q, %q or %q", uploadAuthNone, uploadAuthBearer, uploadAuthBasic)
+}
+
+func validateRetries(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > maxUploadRetries {
+		return fmt.Errorf("must be a number from 0 to %d", maxUploadRetries)
+	}
+	return nil
+}
+
+// validateUpload checks the parts of the upload settings that cannot be set
+// with config set
+func validateUpload(u *UploadConfig) error {
+	if u == nil {
+		return nil
+	}
+	for name, value := range u.Headers {
+		if name == "" || strings.ContainsAny(name, " :\r\n") {
+			return fmt.Errorf("upload: invalid header name %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("upload: header %s must be a single line", name)
+		}
+	}
+	return nil
+}
+
+// UploadResult describes a successful upload
+type UploadResult struct {
+	URL      string `json:"url"`
+	Status   int    `json:"status"`
+	Attempts int    `json:"attempts"`
+	Response string `json:"response,omitempty"` // start of the response body
+}
+
+// uploadRequest is everything needed to (re)send an upload
+type uploadRequest struct {
+	method      string
+	url         string
+	header      http.Header
+	body        []byte
+	contentType string
+}
+
+// expandURL fills in the placeholders of a URL template, escaping the
+// values for the path or the query they end up in
+func expandURL(template string, vars emailVars) string {
+	escaped := func(escape func(string) string) emailVars {
+		return emailVars{escape(vars.Period), escape(vars.Employee), escape(vars.Manager), escape(vars.File)}
+	}
+	rest, fragment, hasFragment := strings.Cut(template, "#")
+	path, query, hasQuery := strings.Cut(rest, "?")
+	target := escaped(url.PathEscape).expand(path)
+	if hasQuery {
+		target += "?" + escaped(url.QueryEscape).expand(query)
+	}
+	if hasFragment {
+		target += "#" + escaped(url.PathEscape).expand(fragment)
+	}
+	return target
+}
+
+// buildUpload returns the request uploading the PDF for a signing
+func buildUpload(cfg UploadConfig, vars emailVars, pdfName string, pdfData []byte) (*uploadRequest, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("no upload URL configured (set upload_url)")
+	}
+	target := expandURL(cfg.URL, vars)
+	if err := validateUploadURL(target); err != nil {
+		return nil, fmt.Errorf("invalid upload_url: %w", err)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	// Sorted, so the request is the same every time
+	names := make([]string, 0, len(cfg.Fields))
+	for name := range cfg.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := mw.WriteField(name, vars.expand(cfg.Fields[name])); err != nil {
+			return nil, err
+		}
+	}
+	part, err := mw.CreateFormFile(cfg.fileField(), pdfName)
+	if err != nil {
+		return nil, err
+	}
+	part.Write(pdfData)
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	header.Set("User-Agent", "hours-signer/"+version)
+	for name, value := range cfg.Headers {
+		header.Set(name, vars.expand(value))
+	}
+	switch cfg.auth() {
+	case uploadAuthBearer, uploadAuthBasic:
+		token, err := readSecret("upload token", cfg.tokenEnv(), cfg.TokenCommand, "upload_token_command")
+		if err != nil {
+			return nil, err
+		}
+		if cfg.auth() == uploadAuthBearer {
+			header.Set("Authorization", "Bearer "+token)
+		} else {
+			header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(cfg.Username+":"+token)))
+		}
+	}
+
+	return &uploadRequest{
+		method:      cfg.method(),
+		url:         target,
+		header:      header,
+		body:        body.Bytes(),
+		contentType: mw.FormDataContentType(),
+	}, nil
+}
+
+// send performs the upload, retrying network errors, 429 and 5xx responses
+// up to retries times. Other responses are final.
+func (r *uploadRequest) send(client *http.Client, retries int) (*UploadResult, error) {
+	var failures []string
+	wait := uploadBackoff
+	for attempt := 1; ; attempt++ {
+		result, failure := r.attempt(client)
+		if failure == nil {
+			result.Attempts = attempt
+			return result, nil
+		}
+		failures = append(failures, failure.err.Error())
+		if !failure.retry || attempt > retries {
+			return nil, fmt.Errorf("upload to %s failed after %d attempt(s): %s", r.url, attempt, summarizeFailures(failures))
+		}
+		uploadSleep(max(wait, failure.after))
+		wait *= 2
+	}
+}
+
+// uploadFailure is a failed upload attempt
+type uploadFailure struct {
+	err   error
+	retry bool          // trying again may help
+	after time.Duration // minimum wait asked for by the server
+}
+
+// attempt sends the request once
+func (r *uploadRequest) attempt(client *http.Client) (*UploadResult, *uploadFailure) {
+	req, err := http.NewRequest(r.method, r.url, bytes.NewReader(r.body))
+	if err != nil {
+		return nil, &uploadFailure{err: err}
+	}
+	req.Header = r.header.Clone()
+	req.Header.Set("Content-Type", r.contentType)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// The URL is already in the final report
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, &uploadFailure{err: err, retry: true}
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 500))
+	response := strings.TrimSpace(string(body))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return &UploadResult{URL: r.url, Status: resp.StatusCode, Response: response}, nil
+	}
+	failure := &uploadFailure{
+		err:   fmt.Errorf("HTTP %s", resp.Status),
+		retry: resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500,
+		after: retryAfter(resp.Header.Get("Retry-After")),
+	}
+	if response != "" {
+		failure.err = fmt.Errorf("HTTP %s: %s", resp.Status, firstLine(response))
+	}
+	return nil, failure
+}
+
+// summarizeFailures lists the failed attempts, taking together consecutive
+// attempts that failed the same way
+func summarizeFailures(failures []string) string {
+	if len(failures) == 1 {
+		return failures[0]
+	}
+	var parts []string
+	for first := 0; first < len(failures); {
+		last := first
+		for last+1 < len(failures) && failures[last+1] == failures[first] {
+			last++
+		}
+		attempts := fmt.Sprintf("attempt %d", first+1)
+		if last > first {
+			attempts = fmt.Sprintf("attempts %d-%d", first+1, last+1)
+		}
+		parts = append(parts, attempts+": "+failures[first])
+		first = last + 1
+	}
+	return strings.Join(parts, "; ")
+}
+
+// retryAfter parses a Retry-After header in seconds, capped at half a minute
+func retryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return min(time.Duration(seconds)*time.Second, 30*time.Second)
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return strings.TrimSpace(line)
+}
+
+// uploadSignedPDF uploads a signed PDF as configured and records the attempt
+// in the history
+func uploadSignedPDF(cfg UploadConfig, entry HistoryEntry) (*UploadResult, error) {
+	pdfData, err := os.ReadFile(entry.OutputPath)
+	if err != nil {
+		return nil, signError(KindUpload, entry.OutputPath, fmt.Errorf("failed to read signed PDF: %w", err))
+	}
+	req, err := buildUpload(cfg, emailVarsFor(entry), filepath.Base(entry.OutputPath), pdfData)
+	if err != nil {
+		return nil, signError(KindUpload, ConfigPath(), err)
+	}
+
+	result, err := req.send(&http.Client{Timeout: uploadTimeout}, cfg.retries())
+	status := DeliveryStatus{Timestamp: time.Now(), Method: "upload", Target: req.url, OK: err == nil}
+	if err != nil {
+		status.Error = err.Error()
+		err = signError(KindUpload, "", err)
+	}
+	if histErr := AppendDelivery(sha256Hex(pdfData), status); histErr != nil && err == nil {
+		return result, fmt.Errorf("uploaded but not recorded in history: %w", histErr)
+	}
+	return result, err
+}
+
+func runUpload(args []string) int {
+	cfg := LoadConfig()
+	upload := *cfg.upload()
+
+	fs := newFlagSet("upload", "[flags] <signed.pdf>", "Upload a signed PDF to the HTTP endpoint from the upload settings in the config.")
+	target := fs.String("url", upload.URL, "URL to upload to")
+	headers := keyValueFlag{}
+	fs.Var(headers, "header", "Extra header `name=value` (repeatable)")
+	fields := keyValueFlag{}
+	fs.Var(fields, "field", "Extra form field `name=value` (repeatable)")
+	jsonOut := fs.Bool("json", false, "Print the result as JSON")
+	positional, code, ok := parseFlags(fs, args)
+	if !ok {
+		return code
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: exactly one signed PDF is required")
+		fs.Usage()
+		return exitUsage
+	}
+
+	// Flags add to the configured headers and fields
+	upload.URL = *target
+	upload.Headers = mergeMaps(upload.Headers, headers)
+	upload.Fields = mergeMaps(upload.Fields, fields)
+	if err := validateUpload(&upload); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+
+	result, err := uploadSignedPDF(upload, entryForSignedPDF(positional[0], cfg))
+	if err != nil {
+		return fail(*jsonOut, err)
+	}
+	if *jsonOut {
+		writeJSON(struct {
+			OK bool `json:"ok"`
+			*UploadResult
+		}{true, result})
+		return exitOK
+	}
+	printUploadResult(result)
+	return exitOK
+}
+
+// mergeMaps returns a copy of base with the entries of extra added
+func mergeMaps(base, extra map[string]string) map[string]string {
+	if len(base)+len(extra) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(base)+len(extra))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range extra {
+		merged[k] = v
+	}
+	return merged
+}
+
+func printUploadResult(r *UploadResult) {
+	fmt.Printf("✓ Uploaded to %s (HTTP %d", r.URL, r.Status)
+	if r.Attempts > 1 {
+		fmt.Printf(", %d attempts", r.Attempts)
+	}
+	fmt.Println(")")
+}
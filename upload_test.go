@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// noUploadWait makes upload retries immediate and returns the waits asked for
+func noUploadWait(t *testing.T) *[]time.Duration {
+	t.Helper()
+	backoff, sleep := uploadBackoff, uploadSleep
+	t.Cleanup(func() { uploadBackoff, uploadSleep = backoff, sleep })
+
+	var waits []time.Duration
+	uploadBackoff = time.Millisecond
+	uploadSleep = func(d time.Duration) { waits = append(waits, d) }
+	return &waits
+}
+
+// uploadServer answers requests with the given statuses in turn, repeating
+// the last one, and records the requests
+type uploadServer struct {
+	*httptest.Server
+	statuses   []int
+	retryAfter string
+
+	mu       sync.Mutex
+	requests []*http.Request
+	forms    []map[string]string // form fields and, as "name:filename", files
+}
+
+func newUploadServer(t *testing.T, statuses ...int) *uploadServer {
+	t.Helper()
+	s := &uploadServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *uploadServer) handle(w http.ResponseWriter, r *http.Request) {
+	form := map[string]string{}
+	if err := r.ParseMultipartForm(1 << 20); err == nil {
+		for name, values := range r.MultipartForm.Value {
+			form[name] = values[0]
+		}
+		for name, files := range r.MultipartForm.File {
+			f, err := files[0].Open()
+			if err == nil {
+				data, _ := io.ReadAll(f)
+				f.Close()
+				form[name+":"+files[0].Filename] = string(data)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, r)
+	s.forms = append(s.forms, form)
+	status := s.statuses[min(len(s.requests), len(s.statuses))-1]
+	s.mu.Unlock()
+
+	if status == http.StatusTooManyRequests && s.retryAfter != "" {
+		w.Header().Set("Retry-After", s.retryAfter)
+	}
+	w.WriteHeader(status)
+	if status >= 300 {
+		fmt.Fprintf(w, "attempt %d refused\nsecond line\n", len(s.requests))
+	} else {
+		fmt.Fprintln(w, `{"id": 42}`)
+	}
+}
+
+func TestUploadSignedPDF(t *testing.T) {
+	entry := signedPDFEntry(t)
+	noUploadWait(t)
+	server := newUploadServer(t, http.StatusCreated)
+
+	cfg := UploadConfig{
+		URL:       server.URL + "/timesheets/{period}",
+		Method:    "put",
+		FileField: "timesheet",
+		Headers:   map[string]string{"X-Employee": "{employee}"},
+		Fields:    map[string]string{"period": "{period}", "approver": "{manager}", "name": "{file}"},
+	}
+	result, err := uploadSignedPDF(cfg, entry)
+	if err != nil {
+		t.Fatalf("uploadSignedPDF: %v", err)
+	}
+	if result.Status != http.StatusCreated || result.Attempts != 1 || result.Response != `{"id": 42}` {
+		t.Errorf("result = %+v", result)
+	}
+	if result.URL != server.URL+"/timesheets/2026-09" {
+		t.Errorf("URL = %s, want the period filled in", result.URL)
+	}
+
+	if len(server.requests) != 1 {
+		t.Fatalf("%d requests, want 1", len(server.requests))
+	}
+	r := server.requests[0]
+	if r.Method != http.MethodPut || r.URL.Path != "/timesheets/2026-09" {
+		t.Errorf("request = %s %s, want PUT /timesheets/2026-09", r.Method, r.URL.Path)
+	}
+	if got := r.Header.Get("X-Employee"); got != "Jan de Vries" {
+		t.Errorf("X-Employee = %q, want the employee", got)
+	}
+	if got := r.Header.Get("Authorization"); got != "" {
+		t.Errorf("Authorization = %q without auth", got)
+	}
+	if got := r.Header.Get("User-Agent"); !strings.HasPrefix(got, "hours-signer/") {
+		t.Errorf("User-Agent = %q", got)
+	}
+
+	want := map[string]string{
+		"period":                                 "2026-09",
+		"approver":                               "Piet",
+		"name":                                   "Urenstaat-2026-09-signed.pdf",
+		"timesheet:Urenstaat-2026-09-signed.pdf": "%PDF-1.7\n%%EOF\n",
+	}
+	form := server.forms[0]
+	if fmt.Sprint(form) != fmt.Sprint(want) {
+		t.Errorf("form:\n got %q\nwant %q", form, want)
+	}
+
+	entries, err := LoadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || len(entries[0].Deliveries) != 1 {
+		t.Fatalf("history = %+v, want one signing with one delivery", entries)
+	}
+	if d := entries[0].Deliveries[0]; !d.OK || d.Method != "upload" || d.Target != result.URL {
+		t.Errorf("delivery = %+v, want a successful upload to %s", d, result.URL)
+	}
+}
+
+func TestUploadAuth(t *testing.T) {
+	t.Setenv(defaultUploadTokenEnv, "s3cret")
+	vars := emailVars{Period: "2026-09"}
+
+	for _, tc := range []struct {
+		cfg  UploadConfig
+		want string
+	}{
+		{UploadConfig{Auth: uploadAuthBearer}, "Bearer s3cret"},
+		{UploadConfig{Auth: uploadAuthBasic, Username: "jan"}, "Basic " + base64.StdEncoding.EncodeToString([]byte("jan:s3cret"))},
+	} {
+		server := newUploadServer(t, http.StatusOK)
+		tc.cfg.URL = server.URL
+		req, err := buildUpload(tc.cfg, vars, "sheet.pdf", []byte("%PDF"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := req.send(server.Client(), 0); err != nil {
+			t.Fatal(err)
+		}
+		if got := server.requests[0].Header.Get("Authorization"); got != tc.want {
+			t.Errorf("%s: Authorization = %q, want %q", tc.cfg.Auth, got, tc.want)
+		}
+	}
+
+	// Without a token nothing is sent
+	t.Setenv(defaultUploadTokenEnv, "")
+	if _, err := buildUpload(UploadConfig{URL: "https://example.com", Auth: uploadAuthBearer}, vars, "sheet.pdf", nil); err == nil || !strings.Contains(err.Error(), defaultUploadTokenEnv) {
+		t.Errorf("err = %v, want the missing token", err)
+	}
+}
+
+func TestUploadRetries(t *testing.T) {
+	waits := noUploadWait(t)
+	server := newUploadServer(t, http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK)
+	server.retryAfter = "3"
+
+	req, err := buildUpload(UploadConfig{URL: server.URL}, emailVars{}, "sheet.pdf", []byte("%PDF"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := req.send(server.Client(), 2)
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if result.Attempts != 3 || len(server.requests) != 3 {
+		t.Errorf("attempts = %d, requests = %d, want 3", result.Attempts, len(server.requests))
+	}
+	// The backoff, then the Retry-After of the 429 over the doubled backoff
+	if want := []time.Duration{time.Millisecond, 3 * time.Second}; fmt.Sprint(*waits) != fmt.Sprint(want) {
+		t.Errorf("waits = %v, want %v", *waits, want)
+	}
+	// Every attempt sends the whole PDF
+	for i, form := range server.forms {
+		if form["file:sheet.pdf"] != "%PDF" {
+			t.Errorf("attempt %d: form = %q, want the PDF", i+1, form)
+		}
+	}
+}
+
+func TestUploadRetriesExhausted(t *testing.T) {
+	waits := noUploadWait(t)
+	server := newUploadServer(t, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusBadGateway)
+
+	req, err := buildUpload(UploadConfig{URL: server.URL}, emailVars{}, "sheet.pdf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = req.send(server.Client(), 2)
+	want := fmt.Sprintf("upload to %s failed after 3 attempt(s): "+
+		"attempt 1: HTTP 500 Internal Server Error: attempt 1 refused; "+
+		"attempt 2: HTTP 500 Internal Server Error: attempt 2 refused; "+
+		"attempt 3: HTTP 502 Bad Gateway: attempt 3 refused", server.URL)
+	if err == nil || err.Error() != want {
+		t.Errorf("err = %v\nwant %s", err, want)
+	}
+	if want := []time.Duration{time.Millisecond, 2 * time.Millisecond}; fmt.Sprint(*waits) != fmt.Sprint(want) {
+		t.Errorf("waits = %v, want %v", *waits, want)
+	}
+}
+
+func TestUploadNoRetryOnClientError(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusRequestEntityTooLarge} {
+		waits := noUploadWait(t)
+		server := newUploadServer(t, status, http.StatusOK)
+
+		req, err := buildUpload(UploadConfig{URL: server.URL}, emailVars{}, "sheet.pdf", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = req.send(server.Client(), 5)
+		if err == nil || !strings.Contains(err.Error(), "failed after 1 attempt(s)") {
+			t.Errorf("HTTP %d: err = %v, want a failure after one attempt", status, err)
+		}
+		if len(server.requests) != 1 || len(*waits) != 0 {
+			t.Errorf("HTTP %d: %d requests and waits %v, want one request", status, len(server.requests), *waits)
+		}
+	}
+}
+
+func TestUploadEscapesURL(t *testing.T) {
+	server := newUploadServer(t, http.StatusOK)
+	vars := emailVars{Period: "2026-09", Employee: "Jan & Co", Manager: "Piet/Marie ?#", File: "Uren staat?.pdf"}
+	cfg := UploadConfig{URL: server.URL + "/sheets/{manager}/{file}?period={period}&who={employee}"}
+
+	req, err := buildUpload(cfg, vars, vars.File, []byte("%PDF"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := req.send(server.Client(), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	r := server.requests[0]
+	if got, want := r.URL.EscapedPath(), "/sheets/Piet%2FMarie%20%3F%23/Uren%20staat%3F.pdf"; got != want {
+		t.Errorf("path = %s, want %s", got, want)
+	}
+	if got := r.URL.Query(); got.Get("period") != "2026-09" || got.Get("who") != "Jan & Co" || len(got) != 2 {
+		t.Errorf("query = %v, want the period and the employee", got)
+	}
+	// The multipart file name is not part of the URL and stays as it is
+	if _, ok := server.forms[0]["file:Uren staat?.pdf"]; !ok {
+		t.Errorf("form = %q, want the file under its own name", server.forms[0])
+	}
+}
+
+func TestUploadNetworkError(t *testing.T) {
+	noUploadWait(t)
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	req, err := buildUpload(UploadConfig{URL: url}, emailVars{}, "sheet.pdf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = req.send(&http.Client{Timeout: time.Second}, 1)
+	if err == nil || !strings.Contains(err.Error(), "failed after 2 attempt(s): attempts 1-2: ") {
+		t.Errorf("err = %v, want both attempts taken together", err)
+	}
+}
+
+func TestSummarizeFailures(t *testing.T) {
+	for _, tc := range []struct {
+		failures []string
+		want     string
+	}{
+		{[]string{"HTTP 503"}, "HTTP 503"},
+		{[]string{"HTTP 503", "HTTP 503", "HTTP 503"}, "attempts 1-3: HTTP 503"},
+		{[]string{"timeout", "HTTP 503", "HTTP 503", "timeout"}, "attempt 1: timeout; attempts 2-3: HTTP 503; attempt 4: timeout"},
+	} {
+		if got := summarizeFailures(tc.failures); got != tc.want {
+			t.Errorf("summarizeFailures(%q) = %q, want %q", tc.failures, got, tc.want)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	for value, want := range map[string]time.Duration{
+		"":                              0,
+		"3":                             3 * time.Second,
+		"120":                           30 * time.Second,
+		"-1":                            0,
+		"Wed, 21 Oct 2026 07:28:00 GMT": 0,
+	} {
+		if got := retryAfter(value); got != want {
+			t.Errorf("retryAfter(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
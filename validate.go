@@ -0,0 +1,272 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// ============================================================================
+// Hours Validation
+// ============================================================================
+
+// Validation modes: what signing does with hours that break a rule
+const (
+	validationOff    = "off"
+	validationWarn   = "warn"   // sign, with warnings
+	validationStrict = "strict" // refuse to sign
+)
+
+// Whether a check is done, for the checks that are on or off
+const (
+	checkWarn  = "warn"
+	checkAllow = "allow"
+)
+
+// Default limits of the validation rules
+const (
+	defaultMaxDayHours  = 12
+	defaultMaxDeviation = 10 // percent
+)
+
+// Rules of the issues found by validateHours
+const (
+	ruleDayHours     = "max_day_hours"
+	ruleWeekendHours = "weekend_hours"
+	ruleDeviation    = "max_deviation"
+	ruleMissingDays  = "missing_days"
+)
+
+// ErrValidation is returned when strict validation finds issues
+var ErrValidation = errors.New("the hours do not pass validation")
+
+// validationIssue is a rule the hours of a timesheet break
+type validationIssue struct {
+	Rule    string `json:"rule"`
+	Date    string `json:"date,omitempty"` // yyyy-mm-dd, for the rules about a day
+	Message string `json:"message"`
+}
+
+// validationRules are the checks on the hours of a timesheet before signing
+type validationRules struct {
+	Mode          string
+	MaxDayHours   float64 // 0 for no limit
+	MaxDeviation  float64 // percent of the expected hours; 0 for no check
+	ContractHours float64 // per week; 0 for no deviation check
+	WeekendHours  bool    // allow hours on weekends, holidays and days off
+	MissingDays   bool    // allow working days without hours
+}
+
+func validateValidationMode(value string) error {
+	switch value {
+	case validationOff, validationWarn, validationStrict:
+		return nil
+	}
+	return fmt.Errorf("must be %q, %q or %q", validationOff, validationWarn, validationStrict)
+}
+
+func validateCheck(value string) error {
+	if value != checkWarn && value != checkAllow {
+		return fmt.Errorf("must be %q or %q", checkWarn, checkAllow)
+	}
+	return nil
+}
+
+func validateMaxDayHours(value string) error {
+	hours, ok := parseHours(value)
+	if !ok || hours <= 0 || hours > 24 {
+		return fmt.Errorf("invalid number of hours %q (at most 24, e.g. 10 or 12)", value)
+	}
+	return nil
+}
+
+func validateMaxDeviation(value string) error {
+	percent, ok := parsePercent(value)
+	if !ok {
+		return fmt.Errorf("invalid percentage %q (e.g. 10 or 10%%)", value)
+	}
+	if percent < 0 {
+		return fmt.Errorf("invalid percentage %q (must not be negative)", value)
+	}
+	return nil
+}
+
+// parsePercent parses a percentage written as 10, 7,5 or 10%
+func parsePercent(value string) (float64, bool) {
+	return parseHours(strings.TrimSuffix(strings.TrimSpace(value), "%"))
+}
+
+// validationRules returns the rules configured in cfg, with the defaults for
+// unset values. Invalid values were refused by config set and fall back to
+// the defaults.
+func (cfg Config) validationRules() validationRules {
+	rules := validationRules{
+		Mode:         cfg.Validation,
+		MaxDayHours:  defaultMaxDayHours,
+		MaxDeviation: defaultMaxDeviation,
+		WeekendHours: cfg.WeekendHours == checkAllow,
+		MissingDays:  cfg.MissingDays == checkAllow,
+	}
+	if validateValidationMode(rules.Mode) != nil {
+		rules.Mode = validationWarn
+	}
+	if hours, ok := parseHours(cfg.MaxDayHours); ok && hours > 0 {
+		rules.MaxDayHours = hours
+	}
+	if percent, ok := parsePercent(cfg.MaxDeviation); ok {
+		rules.MaxDeviation = percent
+	}
+	rules.ContractHours, _ = cfg.contractHours()
+	return rules
+}
+
+// validateHours checks the hours of the table of a timesheet for period
+// against the rules. Working days after today are not missing yet.
+func validateHours(table *hoursTable, period string, rules validationRules, cal workCalendar, now time.Time) []validationIssue {
+	first, last, err := periodDays(period)
+	if err != nil {
+		return nil
+	}
+	days := map[string]float64{}
+	for d, h := range tableDays(table) {
+		if strings.HasPrefix(d, period+"-") {
+			days[d] = h
+		}
+	}
+	dates := make([]string, 0, len(days))
+	for d := range days {
+		dates = append(dates, d)
+	}
+	sort.Strings(dates)
+
+	var issues []validationIssue
+	for _, d := range dates {
+		hours := days[d]
+		day, _ := time.Parse("2006-01-02", d)
+		if rules.MaxDayHours > 0 && hours > rules.MaxDayHours+0.005 {
+			issues = append(issues, validationIssue{ruleDayHours, d, fmt.Sprintf("%s: %s hours, more than %s in a day", d, formatHours(hours, "en"), formatHours(rules.MaxDayHours, "en"))})
+		}
+		if rules.WeekendHours || hours == 0 {
+			continue
+		}
+		if name, ok := cal.holiday(day); ok {
+			issues = append(issues, validationIssue{ruleWeekendHours, d, fmt.Sprintf("%s: %s hours on %s", d, formatHours(hours, "en"), name)})
+		} else if isWeekend(day) {
+			issues = append(issues, validationIssue{ruleWeekendHours, d, fmt.Sprintf("%s: %s hours on a %s", d, formatHours(hours, "en"), day.Weekday())})
+		} else if cal.isDayOff(day) {
+			issues = append(issues, validationIssue{ruleWeekendHours, d, fmt.Sprintf("%s: %s hours on a day off", d, formatHours(hours, "en"))})
+		}
+	}
+
+	if rules.ContractHours > 0 && rules.MaxDeviation > 0 {
+		expected := cal.expectedHours(first, last, rules.ContractHours)
+		total := sumHours(days, period)
+		if expected > 0 {
+			deviation := (total - expected) / expected * 100
+			if math.Abs(deviation) > rules.MaxDeviation+0.005 {
+				direction := "more"
+				if deviation < 0 {
+					direction = "less"
+				}
+				issues = append(issues, validationIssue{Rule: ruleDeviation, Message: fmt.Sprintf("%s hours in %s, %.0f%% %s than the %s expected by contract", formatHours(total, "en"), period, math.Abs(deviation), direction, formatHours(expected, "en"))})
+			}
+		}
+	}
+
+	if !rules.MissingDays && len(days) > 0 {
+		today := civilDate(now.Year(), now.Month(), now.Day())
+		for d := first; !d.After(last) && !d.After(today); d = d.AddDate(0, 0, 1) {
+			if date := d.Format("2006-01-02"); cal.isWorkingDay(d) && days[date] == 0 {
+				issues = append(issues, validationIssue{ruleMissingDays, date, fmt.Sprintf("%s: no hours on a working day", date)})
+			}
+		}
+	}
+	return issues
+}
+
+// validateTimesheet checks the hours table of a timesheet before signing. The
+// period is opts.Period, or else the month most rows fall in. A timesheet
+// without a readable table yields a warning instead of issues.
+func validateTimesheet(ctx *pdfmodel.Context, opts SignOptions) ([]validationIssue, string) {
+	if opts.Validation.Mode == validationOff {
+		return nil, ""
+	}
+	table, err := extractTable(ctx)
+	if err != nil {
+		return nil, fmt.Sprintf("hours not validated: %v", err)
+	}
+	period := opts.Period
+	if period == "" {
+		if period = tablePeriod(table); period == "" {
+			return nil, "hours not validated: no dated rows in the hours table"
+		}
+	}
+	return validateHours(table, period, opts.Validation, opts.Calendar, time.Now()), ""
+}
+
+// validationError is the error of strict validation, listing the issues
+func validationError(issues []validationIssue) error {
+	messages := make([]string, len(issues))
+	for i, issue := range issues {
+		messages[i] = issue.Message
+	}
+	return fmt.Errorf("%w (validation is strict): %s", ErrValidation, strings.Join(messages, "; "))
+}
+
+// checkHours validates the hours of the selected file and shows the issues
+// found, or goes on to signing
+func (m model) checkHours() model {
+	opts := signOptionsFromConfig(m.config)
+	opts.Period = m.signPeriod
+	m.issues = nil
+	if ctx, err := api.ReadContextFile(m.selectedFile); err == nil {
+		m.issues, _ = validateTimesheet(ctx, opts)
+	}
+	if len(m.issues) == 0 {
+		return m.confirmOrSign()
+	}
+	m.screen = screenValidation
+	return m
+}
+
+func (m model) updateValidation(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.String() {
+		case "y":
+			if m.config.validationRules().Mode != validationStrict {
+				return m.confirmOrSign(), nil
+			}
+		case "n", "esc":
+			m.screen = m.signFrom
+			return m, nil
+		}
+	}
+	return m, nil
+}
+
+func (m model) viewValidation() string {
+	s := titleStyle.Render("⚠ Check the Hours") + "\n\n"
+	s += fmt.Sprintf("The hours in %s break the validation rules:\n\n", filepath.Base(m.selectedFile))
+	for _, issue := range m.issues {
+		s += errorStyle.Render("  • "+issue.Message) + "\n"
+	}
+	s += "\n"
+	if m.config.validationRules().Mode == validationStrict {
+		s += "Signing is blocked because validation is strict; correct the hours or\n"
+		s += "set validation to warn.\n\n"
+		s += helpStyle.Render("Press Esc to go back")
+		return s
+	}
+	s += "  [y] Sign anyway\n"
+	s += "  [n] Cancel\n\n"
+	s += helpStyle.Render("Press y to sign anyway • n/Esc to cancel")
+	return s
+}